@@ -0,0 +1,149 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// apiRequest describes a call to one of the server's JSON endpoints.
+type apiRequest struct {
+	op     string // the client operation, for logging, instrumentation and errors
+	method string
+	path   string // the url path, see apiPath
+	query  url.Values
+	body   interface{} // encoded as JSON, if not nil
+	status []int       // the expected status codes, defaults to 200 OK
+	noAuth bool        // send the request without credentials
+}
+
+// apiPath returns the url path of an /api/v2 endpoint, escaping each element.
+func (c *Client) apiPath(elems ...string) string {
+	p := c.url.Path
+	for _, e := range elems {
+		p = path.Join(p, url.PathEscape(e))
+	}
+	return p
+}
+
+// doAPI sends r and decodes the JSON response into out, unless out is nil.
+// It sets the user agent, credentials and content negotiation headers, transparently decompresses
+// responses in the encodings registered with RegisterCompressor, and turns unexpected status codes into an *Error decoded from the response body.
+func (c *Client) doAPI(ctx context.Context, r apiRequest, out interface{}) error {
+	u := *c.url
+	u.Path = r.path
+	u.RawPath = ""
+	u.RawQuery = ""
+	if len(r.query) > 0 {
+		u.RawQuery = r.query.Encode()
+	}
+
+	var body io.Reader
+	if r.body != nil {
+		data, err := json.Marshal(r.body)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(r.method, u.String(), body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Accept-Encoding", acceptEncoding())
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	var resp *http.Response
+	if r.noAuth {
+		resp, err = c.roundTrip(r.op, req)
+	} else {
+		resp, err = c.do(r.op, req)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// discard body so connection can be reused
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if err := decompressResponse(resp); err != nil {
+		return err
+	}
+	reader := io.Reader(resp.Body)
+
+	if !expectedStatus(resp.StatusCode, r.status) {
+		return newResponseError(r.op, resp, reader)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(reader).Decode(out)
+}
+
+func expectedStatus(code int, want []int) bool {
+	if len(want) == 0 {
+		return code == http.StatusOK
+	}
+	for _, w := range want {
+		if code == w {
+			return true
+		}
+	}
+	return false
+}
+
+// newResponseError builds an *Error from an unsuccessful response, decoding the server's JSON error body if there is one.
+func newResponseError(op string, resp *http.Response, body io.Reader) *Error {
+	err := newErrorFromResponse(resp)
+
+	// only support errors that are 16kB long, more than that and something is probably wrong.
+	data, _ := ioutil.ReadAll(io.LimitReader(body, 1<<14))
+	typ, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if typ != "application/json" || json.Unmarshal(data, err) != nil {
+		err.Message = strings.TrimSpace(string(data))
+	}
+
+	if err.Code == "" {
+		err.Code = codeFromStatus(resp)
+	}
+	if err.Message == "" {
+		err.Message = resp.Status
+	}
+	if err.Op == "" {
+		err.Op = op
+	}
+	return err
+}
+
+// setIfNotEmpty sets the query parameter key, unless value is empty.
+func setIfNotEmpty(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+// labelMapping is the body for adding a label to a resource.
+type labelMapping struct {
+	LabelID string `json:"labelID"`
+}
+
+// resourceMember is the body for adding a member or owner to a resource.
+type resourceMember struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
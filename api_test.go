@@ -0,0 +1,201 @@
+package influxdb
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_doAPI(t *testing.T) {
+	var got *http.Request
+	var gotBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		switch r.URL.Path {
+		case "/api/v2/buckets":
+			if r.Method == http.MethodPost {
+				if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+					t.Error(err)
+				}
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"id":"b1","name":"my-bucket"}`))
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("Content-Encoding", "gzip")
+			gw := gzip.NewWriter(w)
+			gw.Write([]byte(`{"buckets":[{"id":"b1","name":"my-bucket"}]}`))
+			gw.Close()
+		case "/api/v2/buckets/missing":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"not found","message":"bucket not found"}`))
+		case "/api/v2/users/u1":
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("upstream unavailable\n"))
+		case "/health":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"name":"influxdb","status":"fail"}`))
+		default:
+			time.Sleep(time.Second)
+		}
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, "my-token", WithUserAgent("test-agent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	t.Run("list", func(t *testing.T) {
+		buckets, err := c.GetBucketsContext(context.Background(), 20, "", 0, "my org", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(buckets.Buckets) != 1 || buckets.Buckets[0].Name != "my-bucket" {
+			t.Errorf("unexpected buckets %+v", buckets)
+		}
+		if q := got.URL.RawQuery; q != "limit=20&offset=0&org=my+org" {
+			t.Errorf("expected only non-empty query parameters, got %q", q)
+		}
+		if a := got.Header.Get("Authorization"); a != "Token my-token" {
+			t.Errorf("expected Authorization %q, got %q", "Token my-token", a)
+		}
+		if ua := got.Header.Get("User-Agent"); ua != "test-agent" {
+			t.Errorf("expected User-Agent %q, got %q", "test-agent", ua)
+		}
+		if ct := got.Header.Get("Content-Type"); ct != "" {
+			t.Errorf("expected no Content-Type without a body, got %q", ct)
+		}
+	})
+
+	t.Run("create", func(t *testing.T) {
+		bucket, err := c.CreateBucketContext(context.Background(), "", "my-bucket", "o1", []RetentionRules{{Type: "expire", EverySeconds: 3600}}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bucket.Id != "b1" {
+			t.Errorf("expected bucket id %q, got %q", "b1", bucket.Id)
+		}
+		if ct := got.Header.Get("Content-Type"); ct != "application/json; charset=utf-8" {
+			t.Errorf("unexpected Content-Type %q", ct)
+		}
+		if gotBody["name"] != "my-bucket" || gotBody["orgID"] != "o1" {
+			t.Errorf("unexpected request body %v", gotBody)
+		}
+	})
+
+	t.Run("json error", func(t *testing.T) {
+		_, err := c.GetBucketByIDContext(context.Background(), "missing")
+		e, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("expected an *Error, got %T: %v", err, err)
+		}
+		if e.StatusCode != http.StatusNotFound || e.Code != "not found" || e.Message != "bucket not found" || e.Op != "GetBucketByID" {
+			t.Errorf("unexpected error %+v", e)
+		}
+	})
+
+	t.Run("text error", func(t *testing.T) {
+		_, err := c.GetUserByIdContext(context.Background(), "u1")
+		e, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("expected an *Error, got %T: %v", err, err)
+		}
+		if e.StatusCode != http.StatusBadGateway || e.Code != EInternal || e.Message != "upstream unavailable" {
+			t.Errorf("unexpected error %+v", e)
+		}
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		health, err := c.GetHealth(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if health.Status != "fail" {
+			t.Errorf("expected status %q, got %q", "fail", health.Status)
+		}
+		if a := got.Header.Get("Authorization"); a != "" {
+			t.Errorf("expected no credentials for /health, got %q", a)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := c.DeleteABucketContext(ctx, "slow"); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("connection error", func(t *testing.T) {
+		dead, err := New("http://127.0.0.1:1", "my-token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dead.GetAllUsers(); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestClient_Setup(t *testing.T) {
+	var (
+		got       *http.Request
+		responses = []func(w http.ResponseWriter){
+			func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte("upstream unavailable\n"))
+			},
+			func(w http.ResponseWriter) {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"code":"conflict","message":"onboarding has already been completed"}`))
+			},
+		}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		if r.URL.Path != "/api/v2/setup" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		respond := responses[0]
+		responses = responses[1:]
+		respond(w)
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, "", WithUserAndPass("my-user", "my-password"), WithUserAgent("test-agent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, err = c.Setup(context.Background(), "my-user", "my-password", "my-bucket", "my-org", 0)
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected an *Error, got %T: %v", err, err)
+	}
+	if e.StatusCode != http.StatusBadGateway || e.Op != "Setup" || e.Message != "upstream unavailable" {
+		t.Errorf("unexpected error %+v", e)
+	}
+	if ua := got.Header.Get("User-Agent"); ua != "test-agent" {
+		t.Errorf("expected User-Agent %q, got %q", "test-agent", ua)
+	}
+
+	res, err := c.Setup(context.Background(), "my-user", "my-password", "my-bucket", "my-org", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != EConflict || res.Message != "onboarding has already been completed" {
+		t.Errorf("expected a conflict, got %+v", res)
+	}
+}
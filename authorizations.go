@@ -1,175 +1,144 @@
 package influxdb
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
-	"log"
 	"net/http"
+	"net/url"
 )
 
+// GetAllAuthorizations is like GetAllAuthorizationsContext, with a background context.
 func (c *Client) GetAllAuthorizations(org string, orgID string, user string, userID string) (*AuthorizationsList, error) {
-	log.Printf("[DEBUG] Get all authorizations")
-
-	params := "?org=" + org + "&orgID=" + orgID + "&user=" + user + "&userID=" + userID
-	req, err := http.NewRequest(http.MethodGet, c.url.String()+"/authorizations"+params, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
+	return c.GetAllAuthorizationsContext(context.Background(), org, orgID, user, userID)
+}
 
-	defer resp.Body.Close()
+// GetAllAuthorizationsContext returns the authorizations. org, orgID, user and userID filter them when they aren't empty.
+func (c *Client) GetAllAuthorizationsContext(ctx context.Context, org string, orgID string, user string, userID string) (*AuthorizationsList, error) {
+	c.log(LevelDebug, "getting all authorizations", "op", "GetAllAuthorizations")
 
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	query := url.Values{}
+	setIfNotEmpty(query, "org", org)
+	setIfNotEmpty(query, "orgID", orgID)
+	setIfNotEmpty(query, "user", user)
+	setIfNotEmpty(query, "userID", userID)
 
 	authorizationsList := &AuthorizationsList{}
-	if err := json.NewDecoder(resp.Body).Decode(authorizationsList); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetAllAuthorizations",
+		method: http.MethodGet,
+		path:   c.apiPath("authorizations"),
+		query:  query,
+	}, authorizationsList)
+	if err != nil {
 		return nil, err
 	}
-
 	return authorizationsList, nil
 }
 
+// CreateAuthorization is like CreateAuthorizationContext, with a background context.
 func (c *Client) CreateAuthorization(description string, orgID string, permissions []Permissions, status string) (*AuthorizationCreated, error) {
+	return c.CreateAuthorizationContext(context.Background(), description, orgID, permissions, status)
+}
+
+// CreateAuthorizationContext creates an authorization with the given permissions in the organization with id orgID.
+func (c *Client) CreateAuthorizationContext(ctx context.Context, description string, orgID string, permissions []Permissions, status string) (*AuthorizationCreated, error) {
 	if orgID == "" {
 		return nil, errors.New("an org id is required")
 	}
 	if len(permissions) == 0 {
 		return nil, errors.New("a list of permissions is required")
 	}
-
-	log.Printf("[DEBUG] Posting a new permission")
-
-	inputData, err := json.Marshal(SetupNewAuthorization{
-		Description: description,
-		OrgID:       orgID,
-		Permissions: permissions,
-		Status:      status,
-	})
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodPost, c.url.String()+"/authorizations", bytes.NewBuffer(inputData))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 201 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "creating authorization", "op", "CreateAuthorization", "orgID", orgID)
 
 	authorizationCreated := &AuthorizationCreated{}
-	if err := json.NewDecoder(resp.Body).Decode(authorizationCreated); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "CreateAuthorization",
+		method: http.MethodPost,
+		path:   c.apiPath("authorizations"),
+		body: SetupNewAuthorization{
+			Description: description,
+			OrgID:       orgID,
+			Permissions: permissions,
+			Status:      status,
+		},
+		status: []int{http.StatusCreated},
+	}, authorizationCreated)
+	if err != nil {
 		return nil, err
 	}
-
 	return authorizationCreated, nil
 }
 
+// GetAuthorizationById is like GetAuthorizationByIdContext, with a background context.
 func (c *Client) GetAuthorizationById(authID string) (*AuthorizationDetails, error) {
+	return c.GetAuthorizationByIdContext(context.Background(), authID)
+}
+
+// GetAuthorizationByIdContext returns the authorization with the given id.
+func (c *Client) GetAuthorizationByIdContext(ctx context.Context, authID string) (*AuthorizationDetails, error) {
 	if authID == "" {
 		return nil, errors.New("a auth id is required")
 	}
-
-	log.Printf("[DEBUG] Getting the authorization with id %s", authID)
-
-	req, err := http.NewRequest(http.MethodGet, c.url.String()+"/authorizations/"+authID, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "getting authorization", "op", "GetAuthorizationById", "authID", authID)
 
 	authorizationDetails := &AuthorizationDetails{}
-	if err := json.NewDecoder(resp.Body).Decode(authorizationDetails); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetAuthorizationById",
+		method: http.MethodGet,
+		path:   c.apiPath("authorizations", authID),
+	}, authorizationDetails)
+	if err != nil {
 		return nil, err
 	}
-
 	return authorizationDetails, nil
 }
 
+// UpdateAnAuthorizationStatus is like UpdateAnAuthorizationStatusContext, with a background context.
 func (c *Client) UpdateAnAuthorizationStatus(authID string, description string, status string) (*AuthorizationDetails, error) {
+	return c.UpdateAnAuthorizationStatusContext(context.Background(), authID, description, status)
+}
+
+// UpdateAnAuthorizationStatusContext updates the description and status of the authorization with the given id.
+func (c *Client) UpdateAnAuthorizationStatusContext(ctx context.Context, authID string, description string, status string) (*AuthorizationDetails, error) {
 	if authID == "" {
 		return nil, errors.New("an auth id is required")
 	}
-
-	log.Printf("[DEBUG] Updating the status of the authorization with id %s", authID)
-
-	inputData, err := json.Marshal(Status{
-		Description: description,
-		Status:      status,
-	})
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodPatch, c.url.String()+"/authorizations/"+authID, bytes.NewBuffer(inputData))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "updating authorization status", "op", "UpdateAnAuthorizationStatus", "authID", authID)
 
 	authorizationDetails := &AuthorizationDetails{}
-	if err := json.NewDecoder(resp.Body).Decode(authorizationDetails); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "UpdateAnAuthorizationStatus",
+		method: http.MethodPatch,
+		path:   c.apiPath("authorizations", authID),
+		body: Status{
+			Description: description,
+			Status:      status,
+		},
+	}, authorizationDetails)
+	if err != nil {
 		return nil, err
 	}
-
 	return authorizationDetails, nil
 }
 
+// DeleteAnAuthorization is like DeleteAnAuthorizationContext, with a background context.
 func (c *Client) DeleteAnAuthorization(authID string) error {
+	return c.DeleteAnAuthorizationContext(context.Background(), authID)
+}
+
+// DeleteAnAuthorizationContext deletes the authorization with the given id.
+func (c *Client) DeleteAnAuthorizationContext(ctx context.Context, authID string) error {
 	if authID == "" {
 		return errors.New("an auth id is required")
 	}
-
-	log.Printf("[DEBUG] Delete the authorization with id %s", authID)
-
-	req, err := http.NewRequest(http.MethodDelete, c.url.String()+"/authorizations/"+authID, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 204 {
-		return errors.New(resp.Status)
-	}
-
-	return nil
+	c.log(LevelDebug, "deleting authorization", "op", "DeleteAnAuthorization", "authID", authID)
+
+	return c.doAPI(ctx, apiRequest{
+		op:     "DeleteAnAuthorization",
+		method: http.MethodDelete,
+		path:   c.apiPath("authorizations", authID),
+		status: []int{http.StatusNoContent},
+	}, nil)
 }
 
 type AuthorizationsList struct {
@@ -178,32 +147,35 @@ type AuthorizationsList struct {
 		Self string `json:"self"`
 		Prev string `json:"prev"`
 	} `json:"links"`
-	Authorizations []struct {
-		Status      string `json:"status"`
-		Description string `json:"description"`
-		CreatedAt   string `json:"createdAt"`
-		UpdatedAt   string `json:"updatedAt"`
-		OrgID       string `json:"orgID"`
-		Permissions []struct {
-			Action   string `json:"action"`
-			Resource struct {
-				Type  string `json:"type"`
-				Id    string `json:"id"`
-				Name  string `json:"name"`
-				OrgID string `json:"orgID"`
-				Org   string `json:"org"`
-			} `json:"resource"`
-		} `json:"permissions"`
-		Id     string `json:"id"`
-		Token  string `json:"token"`
-		UserID string `json:"userID"`
-		User   string `json:"user"`
-		Org    string `json:"org"`
-		Links  struct {
-			Self string `json:"self"`
-			User string `json:"user"`
-		} `json:"links"`
-	} `json:"authorizations"`
+	Authorizations []Authorization `json:"authorizations"`
+}
+
+// Authorization is an authorization, as listed by GetAllAuthorizations.
+type Authorization struct {
+	Status      string `json:"status"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+	OrgID       string `json:"orgID"`
+	Permissions []struct {
+		Action   string `json:"action"`
+		Resource struct {
+			Type  string `json:"type"`
+			Id    string `json:"id"`
+			Name  string `json:"name"`
+			OrgID string `json:"orgID"`
+			Org   string `json:"org"`
+		} `json:"resource"`
+	} `json:"permissions"`
+	Id     string `json:"id"`
+	Token  string `json:"token"`
+	UserID string `json:"userID"`
+	User   string `json:"user"`
+	Org    string `json:"org"`
+	Links  struct {
+		Self string `json:"self"`
+		User string `json:"user"`
+	} `json:"links"`
 }
 
 type Permissions struct {
@@ -244,7 +216,7 @@ type AuthorizationCreated struct {
 	Token  string `json:"token"`
 	UserID string `json:"userID"`
 	User   string `json:"user"`
-	Org    string `json:"user"`
+	Org    string `json:"org"`
 	Links  struct {
 		Self string `json:"self"`
 		User string `json:"user"`
@@ -1,482 +1,425 @@
 package influxdb
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
-	"fmt"
-	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 )
 
+// GetBucketsInSource is like GetBucketsInSourceContext, with a background context.
 func (c *Client) GetBucketsInSource(id string) (*BucketSource, error) {
-	log.Printf("[DEBUG] Get buckets from source with id %s ", id)
-
-	req, err := http.NewRequest(http.MethodGet, c.url.String()+"/sources/"+id+"/buckets", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Accept", "*/*")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
+	return c.GetBucketsInSourceContext(context.Background(), id)
+}
 
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
+// GetBucketsInSourceContext returns the buckets in the source with the given id.
+func (c *Client) GetBucketsInSourceContext(ctx context.Context, id string) (*BucketSource, error) {
+	if id == "" {
+		return nil, errors.New("a source id is required")
 	}
+	c.log(LevelDebug, "getting buckets in source", "op", "GetBucketsInSource", "sourceID", id)
 
 	bucketSource := &BucketSource{}
-	if err := json.NewDecoder(resp.Body).Decode(bucketSource); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetBucketsInSource",
+		method: http.MethodGet,
+		path:   c.apiPath("sources", id, "buckets"),
+	}, bucketSource)
+	if err != nil {
 		return nil, err
 	}
-
 	return bucketSource, nil
 }
 
+// GetBuckets is like GetBucketsContext, with a background context.
 func (c *Client) GetBuckets(limit int, name string, offset int, org string, orgID string) (*BucketSource, error) {
+	return c.GetBucketsContext(context.Background(), limit, name, offset, org, orgID)
+}
+
+// GetBucketsContext returns a page of at most limit buckets, starting at offset.
+// name, org and orgID filter the buckets when they aren't empty.
+func (c *Client) GetBucketsContext(ctx context.Context, limit int, name string, offset int, org string, orgID string) (*BucketSource, error) {
 	if limit == 0 || limit > 100 {
 		return nil, errors.New("limit needs to be between [ 1 ... 100 ]")
 	}
 	if offset < 0 {
 		return nil, errors.New("offset needs to be granter or equal to 0")
 	}
-	log.Printf("[DEBUG] Get buckets")
-
-	params := "/buckets?limit=" + strconv.Itoa(limit) + "&name=" + name + "&offset=" + strconv.Itoa(offset) + "&org=" + org + "&orgID=" + orgID + ""
-	req, err := http.NewRequest(http.MethodGet, c.url.String()+params, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
+	c.log(LevelDebug, "getting buckets", "op", "GetBuckets", "limit", limit, "offset", offset)
 
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	setIfNotEmpty(query, "name", name)
+	setIfNotEmpty(query, "org", org)
+	setIfNotEmpty(query, "orgID", orgID)
 
 	bucketSource := &BucketSource{}
-	if err := json.NewDecoder(resp.Body).Decode(bucketSource); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetBuckets",
+		method: http.MethodGet,
+		path:   c.apiPath("buckets"),
+		query:  query,
+	}, bucketSource)
+	if err != nil {
 		return nil, err
 	}
-
 	return bucketSource, nil
 }
 
+// CreateBucket is like CreateBucketContext, with a background context.
 func (c *Client) CreateBucket(description string, name string, orgID string, retentionRules []RetentionRules, rp string) (*BucketCreate, error) {
+	return c.CreateBucketContext(context.Background(), description, name, orgID, retentionRules, rp)
+}
+
+// CreateBucketContext creates a bucket in the organization with id orgID.
+func (c *Client) CreateBucketContext(ctx context.Context, description string, name string, orgID string, retentionRules []RetentionRules, rp string) (*BucketCreate, error) {
 	if name == "" {
 		return nil, errors.New("name is needed to create a new bucket")
 	}
 	if len(retentionRules) == 0 {
 		return nil, errors.New("retentions rules is/are needed to create a new bucket")
 	}
-	log.Printf("[DEBUG] Creation of a new bucket")
-
-	inputData, err := json.Marshal(SetupCreateBucket{
-		Description:    description,
-		Name:           name,
-		OrgID:          orgID,
-		RetentionRules: retentionRules,
-		Rp:             rp,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("POST", c.url.String()+"/buckets", bytes.NewBuffer(inputData))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 201 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "creating bucket", "op", "CreateBucket", "name", name)
 
 	bucketCreate := &BucketCreate{}
-	if err := json.NewDecoder(resp.Body).Decode(bucketCreate); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "CreateBucket",
+		method: http.MethodPost,
+		path:   c.apiPath("buckets"),
+		body: SetupCreateBucket{
+			Description:    description,
+			Name:           name,
+			OrgID:          orgID,
+			RetentionRules: retentionRules,
+			Rp:             rp,
+		},
+		status: []int{http.StatusCreated},
+	}, bucketCreate)
+	if err != nil {
 		return nil, err
 	}
-
 	return bucketCreate, nil
 }
 
+// GetBucketByID is like GetBucketByIDContext, with a background context.
 func (c *Client) GetBucketByID(bucketID string) (*SimpleBucket, error) {
+	return c.GetBucketByIDContext(context.Background(), bucketID)
+}
+
+// GetBucketByIDContext returns the bucket with the given id.
+func (c *Client) GetBucketByIDContext(ctx context.Context, bucketID string) (*SimpleBucket, error) {
 	if bucketID == "" {
 		return nil, errors.New("a bucket id is required")
 	}
-
-	log.Printf("[DEBUG] Get bucket with id: %s", bucketID)
-
-	req, err := http.NewRequest(http.MethodGet, c.url.String()+"/buckets/"+bucketID, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "getting bucket", "op", "GetBucketByID", "bucketID", bucketID)
 
 	simpleBucket := &SimpleBucket{}
-	if err := json.NewDecoder(resp.Body).Decode(simpleBucket); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetBucketByID",
+		method: http.MethodGet,
+		path:   c.apiPath("buckets", bucketID),
+	}, simpleBucket)
+	if err != nil {
 		return nil, err
 	}
-
 	return simpleBucket, nil
 }
 
+// UpdateABucket is like UpdateABucketContext, with a background context.
 func (c *Client) UpdateABucket(bucketID string, description string, labels []Labels, name string, orgID string, retentionRules []RetentionRules, rp string) (*SimpleBucket, error) {
+	return c.UpdateABucketContext(context.Background(), bucketID, description, labels, name, orgID, retentionRules, rp)
+}
+
+// UpdateABucketContext updates the bucket with the given id.
+func (c *Client) UpdateABucketContext(ctx context.Context, bucketID string, description string, labels []Labels, name string, orgID string, retentionRules []RetentionRules, rp string) (*SimpleBucket, error) {
+	if bucketID == "" {
+		return nil, errors.New("a bucket id is required")
+	}
 	if name == "" {
 		return nil, errors.New("name should be specified")
 	}
 	if len(retentionRules) == 0 {
 		return nil, errors.New("retention rules should be specified")
 	}
-
-	log.Printf("[DEBUG] Updating the bucket with id: %s", bucketID)
-
-	inputData, err := json.Marshal(SetupUpdateBucket{
-		Description:    description,
-		Labels:         labels,
-		Name:           name,
-		OrgID:          orgID,
-		RetentionRules: retentionRules,
-		Rp:             rp,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest(http.MethodPatch, c.url.String()+"/buckets/"+bucketID, bytes.NewBuffer(inputData))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "updating bucket", "op", "UpdateABucket", "bucketID", bucketID)
 
 	updateBucket := &SimpleBucket{}
-	if err := json.NewDecoder(resp.Body).Decode(updateBucket); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "UpdateABucket",
+		method: http.MethodPatch,
+		path:   c.apiPath("buckets", bucketID),
+		body: SetupUpdateBucket{
+			Description:    description,
+			Labels:         labels,
+			Name:           name,
+			OrgID:          orgID,
+			RetentionRules: retentionRules,
+			Rp:             rp,
+		},
+	}, updateBucket)
+	if err != nil {
 		return nil, err
 	}
-
 	return updateBucket, nil
 }
 
+// DeleteABucket is like DeleteABucketContext, with a background context.
 func (c *Client) DeleteABucket(bucketID string) error {
+	return c.DeleteABucketContext(context.Background(), bucketID)
+}
+
+// DeleteABucketContext deletes the bucket with the given id.
+func (c *Client) DeleteABucketContext(ctx context.Context, bucketID string) error {
 	if bucketID == "" {
 		return errors.New("a bucketID should be specified")
 	}
+	c.log(LevelDebug, "deleting bucket", "op", "DeleteABucket", "bucketID", bucketID)
 
-	log.Printf("[DEBUG] Deleting bucket with id: %s", bucketID)
-
-	req, err := http.NewRequest("DELETE", c.url.String()+"/buckets/"+bucketID, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 204 {
-		return errors.New(resp.Status)
-	}
-
-	return nil
+	return c.doAPI(ctx, apiRequest{
+		op:     "DeleteABucket",
+		method: http.MethodDelete,
+		path:   c.apiPath("buckets", bucketID),
+		status: []int{http.StatusNoContent},
+	}, nil)
 }
 
+// ListLabelsForABucket is like ListLabelsForABucketContext, with a background context.
 func (c *Client) ListLabelsForABucket(bucketID string) (*LabelsOfBucket, error) {
+	return c.ListLabelsForABucketContext(context.Background(), bucketID)
+}
+
+// ListLabelsForABucketContext returns the labels of the bucket with the given id.
+func (c *Client) ListLabelsForABucketContext(ctx context.Context, bucketID string) (*LabelsOfBucket, error) {
 	if bucketID == "" {
 		return nil, errors.New("a bucket id is required")
 	}
-
-	log.Printf("[DEBUG] Getting labels for bucket with id: %s", bucketID)
-
-	req, err := http.NewRequest("GET", c.url.String()+"/buckets/"+bucketID+"/labels", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "listing labels of bucket", "op", "ListLabelsForABucket", "bucketID", bucketID)
 
 	labelsOfBucket := &LabelsOfBucket{}
-	if err := json.NewDecoder(resp.Body).Decode(labelsOfBucket); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "ListLabelsForABucket",
+		method: http.MethodGet,
+		path:   c.apiPath("buckets", bucketID, "labels"),
+	}, labelsOfBucket)
+	if err != nil {
 		return nil, err
 	}
-
 	return labelsOfBucket, nil
 }
 
+// AddLabelToBucket is like AddLabelToBucketContext, with a background context.
 func (c *Client) AddLabelToBucket(bucketID string, labelID string) (*LabelsOfBucket, error) {
+	return c.AddLabelToBucketContext(context.Background(), bucketID, labelID)
+}
+
+// AddLabelToBucketContext adds the label with id labelID to the bucket with id bucketID.
+func (c *Client) AddLabelToBucketContext(ctx context.Context, bucketID string, labelID string) (*LabelsOfBucket, error) {
 	if bucketID == "" {
 		return nil, errors.New("a bucket id is required to add label to it")
 	}
 	if len(labelID) == 0 {
 		return nil, errors.New("an array of one label id is required")
 	}
-
-	log.Printf("[DEBUG] Adding label to the bucket with the id: %s", bucketID)
-
-	inputData := fmt.Sprintf("{\"labelID\": \"%s\"}", labelID)
-
-	req, err := http.NewRequest(http.MethodPost, c.url.String()+"/buckets/"+bucketID+"/labels", bytes.NewBuffer([]byte(inputData)))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 201 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "adding label to bucket", "op", "AddLabelToBucket", "bucketID", bucketID, "labelID", labelID)
 
 	labelsOfBucket := &LabelsOfBucket{}
-	if err := json.NewDecoder(resp.Body).Decode(labelsOfBucket); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "AddLabelToBucket",
+		method: http.MethodPost,
+		path:   c.apiPath("buckets", bucketID, "labels"),
+		body:   labelMapping{LabelID: labelID},
+		status: []int{http.StatusCreated},
+	}, labelsOfBucket)
+	if err != nil {
 		return nil, err
 	}
-
 	return labelsOfBucket, nil
 }
 
+// DeleteALabelFromBucket is like DeleteALabelFromBucketContext, with a background context.
 func (c *Client) DeleteALabelFromBucket(bucketID string, labelID string) error {
+	return c.DeleteALabelFromBucketContext(context.Background(), bucketID, labelID)
+}
+
+// DeleteALabelFromBucketContext removes the label with id labelID from the bucket with id bucketID.
+func (c *Client) DeleteALabelFromBucketContext(ctx context.Context, bucketID string, labelID string) error {
 	if bucketID == "" {
 		return errors.New("a bucket id is required")
 	}
 	if labelID == "" {
 		return errors.New("a label id is required")
 	}
+	c.log(LevelDebug, "deleting label from bucket", "op", "DeleteALabelFromBucket", "bucketID", bucketID, "labelID", labelID)
 
-	log.Printf("[DEBUG] Deleting label id %s of bucket id %s", labelID, bucketID)
-
-	req, err := http.NewRequest("DELETE", c.url.String()+"/buckets/"+bucketID+"/labels/"+labelID, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 204 {
-		return errors.New(resp.Status)
-	}
-
-	return nil
+	return c.doAPI(ctx, apiRequest{
+		op:     "DeleteALabelFromBucket",
+		method: http.MethodDelete,
+		path:   c.apiPath("buckets", bucketID, "labels", labelID),
+		status: []int{http.StatusNoContent},
+	}, nil)
 }
 
+// GetListUsersOfBucket is like GetListUsersOfBucketContext, with a background context.
 func (c *Client) GetListUsersOfBucket(bucketID string) (*BucketUsers, error) {
+	return c.GetListUsersOfBucketContext(context.Background(), bucketID)
+}
+
+// GetListUsersOfBucketContext returns the members of the bucket with the given id.
+func (c *Client) GetListUsersOfBucketContext(ctx context.Context, bucketID string) (*BucketUsers, error) {
 	if bucketID == "" {
 		return nil, errors.New("a bucket id is required")
 	}
-	log.Printf("[DEBUG] Listing members of bucket id: %s", bucketID)
-
-	req, err := http.NewRequest("GET", c.url.String()+"/buckets/"+bucketID+"/members", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "listing members of bucket", "op", "GetListUsersOfBucket", "bucketID", bucketID)
 
 	bucketUsers := &BucketUsers{}
-	if err := json.NewDecoder(resp.Body).Decode(bucketUsers); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetListUsersOfBucket",
+		method: http.MethodGet,
+		path:   c.apiPath("buckets", bucketID, "members"),
+	}, bucketUsers)
+	if err != nil {
 		return nil, err
 	}
-
 	return bucketUsers, nil
 }
 
+// AddMemberToBucket is like AddMemberToBucketContext, with a background context.
 func (c *Client) AddMemberToBucket(bucketID string, memberID string, memberName string) (*BucketMemberAdded, error) {
+	return c.AddMemberToBucketContext(context.Background(), bucketID, memberID, memberName)
+}
+
+// AddMemberToBucketContext adds the user with id memberID as a member of the bucket with id bucketID.
+func (c *Client) AddMemberToBucketContext(ctx context.Context, bucketID string, memberID string, memberName string) (*BucketMemberAdded, error) {
 	if bucketID == "" {
 		return nil, errors.New("a bucket id is required")
 	}
 	if memberID == "" {
 		return nil, errors.New("a member id is required")
 	}
-
-	log.Printf("[DEBUG] Adding the member with id %s to the bucket with id %s", memberID, bucketID)
-
-	inputData := fmt.Sprintf("{\"id\": \"%s\", \"name\": \"%s\"}", memberID, memberName)
-
-	req, err := http.NewRequest(http.MethodPost, c.url.String()+"/buckets/"+bucketID+"/members", bytes.NewBuffer([]byte(inputData)))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 201 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "adding member to bucket", "op", "AddMemberToBucket", "bucketID", bucketID, "memberID", memberID)
 
 	bucketMemberAdded := &BucketMemberAdded{}
-	if err := json.NewDecoder(resp.Body).Decode(bucketMemberAdded); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "AddMemberToBucket",
+		method: http.MethodPost,
+		path:   c.apiPath("buckets", bucketID, "members"),
+		body:   resourceMember{ID: memberID, Name: memberName},
+		status: []int{http.StatusCreated},
+	}, bucketMemberAdded)
+	if err != nil {
 		return nil, err
 	}
-
 	return bucketMemberAdded, nil
 }
 
+// RemoveMemberOfBucket is like RemoveMemberOfBucketContext, with a background context.
 func (c *Client) RemoveMemberOfBucket(bucketID string, userID string) error {
+	return c.RemoveMemberOfBucketContext(context.Background(), bucketID, userID)
+}
+
+// RemoveMemberOfBucketContext removes the user with id userID from the members of the bucket with id bucketID.
+func (c *Client) RemoveMemberOfBucketContext(ctx context.Context, bucketID string, userID string) error {
 	if bucketID == "" {
 		return errors.New("a bucket id is required")
 	}
 	if userID == "" {
 		return errors.New("a member id is required")
 	}
+	c.log(LevelDebug, "removing member from bucket", "op", "RemoveMemberOfBucket", "bucketID", bucketID, "userID", userID)
 
-	log.Printf("[DEBUG] Removing member with id %s to bucket with id %s", userID, bucketID)
-
-	req, err := http.NewRequest("DELETE", c.url.String()+"/buckets/"+bucketID+"/members/"+userID, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 204 {
-		return errors.New(resp.Status)
-	}
-
-	return nil
+	return c.doAPI(ctx, apiRequest{
+		op:     "RemoveMemberOfBucket",
+		method: http.MethodDelete,
+		path:   c.apiPath("buckets", bucketID, "members", userID),
+		status: []int{http.StatusNoContent},
+	}, nil)
 }
 
+// GetOwnersOfBucket is like GetOwnersOfBucketContext, with a background context.
 func (c *Client) GetOwnersOfBucket(bucketID string) (*BucketOwner, error) {
+	return c.GetOwnersOfBucketContext(context.Background(), bucketID)
+}
+
+// GetOwnersOfBucketContext returns the owners of the bucket with the given id.
+func (c *Client) GetOwnersOfBucketContext(ctx context.Context, bucketID string) (*BucketOwner, error) {
 	if bucketID == "" {
 		return nil, errors.New("a bucket id is required")
 	}
-
-	log.Printf("[DEBUG] Getting owners of bucket with id %s", bucketID)
-
-	req, err := http.NewRequest("GET", c.url.String()+"/buckets/"+bucketID+"/owners", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "getting owners of bucket", "op", "GetOwnersOfBucket", "bucketID", bucketID)
 
 	bucketOwner := &BucketOwner{}
-	if err := json.NewDecoder(resp.Body).Decode(bucketOwner); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetOwnersOfBucket",
+		method: http.MethodGet,
+		path:   c.apiPath("buckets", bucketID, "owners"),
+	}, bucketOwner)
+	if err != nil {
 		return nil, err
 	}
-
 	return bucketOwner, nil
 }
 
+// AddOwnerToBucket is like AddOwnerToBucketContext, with a background context.
 func (c *Client) AddOwnerToBucket(bucketID string, ownerID string, ownerName string) (*BucketOwnerAdded, error) {
+	return c.AddOwnerToBucketContext(context.Background(), bucketID, ownerID, ownerName)
+}
+
+// AddOwnerToBucketContext adds the user with id ownerID as an owner of the bucket with id bucketID.
+func (c *Client) AddOwnerToBucketContext(ctx context.Context, bucketID string, ownerID string, ownerName string) (*BucketOwnerAdded, error) {
 	if bucketID == "" {
 		return nil, errors.New("a bucket id is required")
 	}
 	if ownerID == "" {
 		return nil, errors.New("an owner id is required")
 	}
-
-	log.Printf("[DEBUG] Adding an owner with id %s to the bucket with id %s", ownerID, bucketID)
-
-	inputData := fmt.Sprintf("{\"id\": \"%s\", \"name\": \"%s\"}", ownerID, ownerName)
-
-	req, err := http.NewRequest(http.MethodPost, c.url.String()+"/buckets/"+bucketID+"/owners", bytes.NewBuffer([]byte(inputData)))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 201 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "adding owner to bucket", "op", "AddOwnerToBucket", "bucketID", bucketID, "ownerID", ownerID)
 
 	bucketOwnerAdded := &BucketOwnerAdded{}
-	if err := json.NewDecoder(resp.Body).Decode(bucketOwnerAdded); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "AddOwnerToBucket",
+		method: http.MethodPost,
+		path:   c.apiPath("buckets", bucketID, "owners"),
+		body:   resourceMember{ID: ownerID, Name: ownerName},
+		status: []int{http.StatusCreated},
+	}, bucketOwnerAdded)
+	if err != nil {
 		return nil, err
 	}
-
 	return bucketOwnerAdded, nil
 }
 
+// RemoveOwnerFromBucket is like RemoveOwnerFromBucketContext, with a background context.
 func (c *Client) RemoveOwnerFromBucket(bucketID string, ownerID string) error {
+	return c.RemoveOwnerFromBucketContext(context.Background(), bucketID, ownerID)
+}
+
+// RemoveOwnerFromBucketContext removes the user with id ownerID from the owners of the bucket with id bucketID.
+func (c *Client) RemoveOwnerFromBucketContext(ctx context.Context, bucketID string, ownerID string) error {
 	if bucketID == "" {
 		return errors.New("a bucket id is required")
 	}
 	if ownerID == "" {
 		return errors.New("an owner id is required")
 	}
+	c.log(LevelDebug, "removing owner from bucket", "op", "RemoveOwnerFromBucket", "bucketID", bucketID, "ownerID", ownerID)
 
-	log.Printf("[DEBUG] Removing the owner with id %s to the bucket with id %s", ownerID, bucketID)
-
-	req, err := http.NewRequest("DELETE", c.url.String()+"/buckets/"+bucketID+"/owners/"+ownerID, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 204 {
-		return errors.New(resp.Status)
-	}
-
-	return nil
+	return c.doAPI(ctx, apiRequest{
+		op:     "RemoveOwnerFromBucket",
+		method: http.MethodDelete,
+		path:   c.apiPath("buckets", bucketID, "owners", ownerID),
+		status: []int{http.StatusNoContent},
+	}, nil)
 }
 
+// GetLogsOfBucket is like GetLogsOfBucketContext, with a background context.
 func (c *Client) GetLogsOfBucket(bucketID string, limit int, offset int) (*BucketLogs, error) {
+	return c.GetLogsOfBucketContext(context.Background(), bucketID, limit, offset)
+}
+
+// GetLogsOfBucketContext returns a page of at most limit log entries of the bucket with the given id, starting at offset.
+func (c *Client) GetLogsOfBucketContext(ctx context.Context, bucketID string, limit int, offset int) (*BucketLogs, error) {
 	if bucketID == "" {
 		return nil, errors.New("a bucket id is required")
 	}
@@ -486,29 +429,22 @@ func (c *Client) GetLogsOfBucket(bucketID string, limit int, offset int) (*Bucke
 	if offset < 0 {
 		return nil, errors.New("offset needs to be granter or equal to 0")
 	}
+	c.log(LevelDebug, "getting logs of bucket", "op", "GetLogsOfBucket", "bucketID", bucketID)
 
-	log.Printf("[DEBUG] Get logs of bucket with id %s", bucketID)
-
-	params := "?limit=" + strconv.Itoa(limit) + "&offset=" + strconv.Itoa(offset)
-	req, err := http.NewRequest(http.MethodGet, c.url.String()+"/buckets/"+bucketID+"/logs"+params, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
 
 	bucketLogs := &BucketLogs{}
-	if err := json.NewDecoder(resp.Body).Decode(bucketLogs); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetLogsOfBucket",
+		method: http.MethodGet,
+		path:   c.apiPath("buckets", bucketID, "logs"),
+		query:  query,
+	}, bucketLogs)
+	if err != nil {
 		return nil, err
 	}
-
 	return bucketLogs, nil
 }
 
@@ -518,27 +454,8 @@ type BucketSource struct {
 		Self string `json:"self"`
 		Prev string `json:"prev"`
 	} `json:"links"`
-	Buckets []struct {
-		Links struct {
-			Labels  string `json:"labels"`
-			Logs    string `json:"logs"`
-			Members string `json:"members"`
-			Org     string `json:"org"`
-			Owners  string `json:"owners"`
-			Self    string `json:"self"`
-			Write   string `json:"write"`
-		} `json:"links"`
-		Id             string           `json:"id"`
-		Type           string           `json:"type"`
-		Name           string           `json:"name"`
-		Description    string           `json:"description"`
-		OrgId          string           `json:"orgId"`
-		Rp             string           `json:"rp"`
-		CreatedAt      string           `json:"createdAt"`
-		UpdatedAt      string           `json:"updatedAt"`
-		RetentionRules []RetentionRules `json:"retentionRules"`
-	} `json:"buckets"`
-	Labels []struct {
+	Buckets []Bucket `json:"buckets"`
+	Labels  []struct {
 		Id          string `json:"id"`
 		OrgId       string `json:"orgId"`
 		Name        string `json:"name"`
@@ -548,6 +465,28 @@ type BucketSource struct {
 	} `json:"labels"`
 }
 
+// Bucket is a bucket, as listed by GetBuckets.
+type Bucket struct {
+	Links struct {
+		Labels  string `json:"labels"`
+		Logs    string `json:"logs"`
+		Members string `json:"members"`
+		Org     string `json:"org"`
+		Owners  string `json:"owners"`
+		Self    string `json:"self"`
+		Write   string `json:"write"`
+	} `json:"links"`
+	Id             string           `json:"id"`
+	Type           string           `json:"type"`
+	Name           string           `json:"name"`
+	Description    string           `json:"description"`
+	OrgId          string           `json:"orgId"`
+	Rp             string           `json:"rp"`
+	CreatedAt      string           `json:"createdAt"`
+	UpdatedAt      string           `json:"updatedAt"`
+	RetentionRules []RetentionRules `json:"retentionRules"`
+}
+
 type BucketCreate struct {
 	Links struct {
 		Labels  string `json:"labels"`
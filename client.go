@@ -8,6 +8,10 @@ import (
 	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	lp "github.com/influxdata/line-protocol"
 )
 
 // TODO(docmerlin): change the generator so we don't have to hand edit the generated code
@@ -18,24 +22,49 @@ type Client struct {
 	httpClient       *http.Client
 	contentEncoding  string
 	compressionLevel int
+	customCompressor Compressor // set with WithCompressor, otherwise contentEncoding and compressionLevel pick one
 	url              *url.URL
+	socketPath       string // the unix domain socket to connect to, if any
 	password         string
 	username         string
 	l                sync.Mutex
 	errOnFieldErr    bool
 	userAgent        string
-	authorization    string // the Authorization header
+	authorization    string              // the Authorization header, guarded by l
+	credentials      CredentialsProvider // supplies tokens, when not using a fixed one
+	session          *http.Cookie        // the session cookie, when signed in with a username and password
+	signingIn        chan struct{}       // closed when the sign in in progress is done, guarded by l
 	maxLineBytes     int
+	precision        time.Duration // the write precision, nanoseconds if zero
+	middleware       []Middleware
+	logger           Logger
+	instrumentation  Instrumentation
+	failover         *failover    // set when there are several endpoints
+	serverVersion    atomic.Value // the serverVersion last reported by the server
+	versionLookup    atomic.Value // the failedVersionLookup of Supports, if asking for the version failed
+	defaultTags      []lp.Tag     // sorted by key
+	parallelAttempts int          // attempts at each chunk of WriteParallel, the defaults if zero
+	parallelBackoff  time.Duration
+	schema           *SchemaRegistry // checks the metrics of writes, if set
+	schemaPolicy     SchemaPolicy
+
+	// limits on the body of write requests, if not zero
+	maxBodyBytes           int // before compression
+	maxCompressedBodyBytes int // as sent
 }
 
 // New creates a new Client.
 // The client is concurrency safe, so feel free to use it and abuse it to your heart's content.
+// connection is the address of the server, like "http://127.0.0.1:9999",
+// or the path to a unix domain socket, like "unix:///var/run/influxdb.sock".
 func New(connection string, token string, options ...Option) (*Client, error) {
 	c := &Client{
 		contentEncoding:  "gzip",
 		compressionLevel: 4,
 		errOnFieldErr:    true,
-		authorization:    "Token " + token,
+	}
+	if token != "" {
+		c.authorization = "Token " + token
 	}
 
 	if connection == "" {
@@ -46,6 +75,11 @@ func New(connection string, token string, options ...Option) (*Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error: could not parse url: %v", err)
 	}
+	if u.Scheme == "unix" {
+		// requests are sent over the socket, so the host is just a placeholder.
+		c.socketPath = u.Path
+		u = &url.URL{Scheme: "http", Host: "localhost"}
+	}
 	u.Path = `/api/v2`
 
 	c.url = u
@@ -66,10 +100,16 @@ func New(connection string, token string, options ...Option) (*Client, error) {
 	}
 
 	if c.httpClient == nil {
-		c.httpClient = defaultHTTPClient()
+		c.httpClient = defaultHTTPClient(c.socketPath)
+	}
+	if c.authorization == "" && c.credentials == nil && !(c.username != "" || c.password != "") {
+		return nil, errors.New("a token or a username and password is required, pass a token to New(), or use WithUserAndPass(\"the_username\",\"the_password\") or WithCredentials")
 	}
-	if c.authorization == "" && !(c.username != "" || c.password != "") {
-		return nil, errors.New("a token or a username and password is required, pass a token to New(), or use WithUserAndPass(\"the_username\",\"the_password\")")
+	if c.failover != nil {
+		if c.socketPath != "" {
+			return nil, errors.New("WithEndpoints can't be used with a unix socket address")
+		}
+		c.failover.start(c)
 	}
 	return c, nil
 }
@@ -85,7 +125,7 @@ func (c *Client) Ping(ctx context.Context) error {
 	}
 
 	req = req.WithContext(ctx)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.roundTrip("Ping", req)
 	if err != nil {
 		return err
 	}
@@ -96,7 +136,7 @@ func (c *Client) Ping(ctx context.Context) error {
 	}
 
 	// we shouldn't see this
-	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+	if !isReady(resp.StatusCode) {
 		var err = errors.New(string(body))
 		return err
 	}
@@ -104,10 +144,20 @@ func (c *Client) Ping(ctx context.Context) error {
 	return nil
 }
 
-// Close closes any idle connections on the Client.
+// isReady reports whether a response from /ready with status code means the server is ready.
+func isReady(code int) bool {
+	return code == http.StatusOK || code == http.StatusNoContent
+}
+
+// Close signs out of the current session, if there is one, stops health checking endpoints,
+// and closes any idle connections on the Client.
 func (c *Client) Close() error {
+	err := c.signout(context.Background())
+	if c.failover != nil {
+		c.failover.close()
+	}
 	c.httpClient.CloseIdleConnections()
-	return nil // we do this, so it qualifies as a closer.
+	return err
 }
 
 func (c *Client) GetUrl() (string, error) {
@@ -2,14 +2,20 @@ package influxdb
 
 import (
 	"context"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	cmp "github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestNew(t *testing.T) {
@@ -123,8 +129,8 @@ func TestNew(t *testing.T) {
 				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !cmp.Equal(got, tt.want, cmp.AllowUnexported(Client{}), cmp.AllowUnexported(sync.Mutex{})) {
-				t.Errorf("Diff: %s", cmp.Diff(got, tt.want, cmp.AllowUnexported(Client{}), cmp.AllowUnexported(sync.Mutex{})))
+			if !cmp.Equal(got, tt.want, cmp.AllowUnexported(Client{}), cmpopts.IgnoreTypes(sync.Mutex{}, atomic.Value{})) {
+				t.Errorf("Diff: %s", cmp.Diff(got, tt.want, cmp.AllowUnexported(Client{}), cmpopts.IgnoreTypes(sync.Mutex{}, atomic.Value{})))
 			}
 		})
 	}
@@ -193,3 +199,206 @@ func TestClient_Ping(t *testing.T) {
 		})
 	}
 }
+
+func TestClient_Session(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		signins  int
+		signouts int
+		session  = "session-1"
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v2/signin":
+			if user, pass, ok := r.BasicAuth(); !ok || user != "my-user" || pass != "my-password" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			signins++
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: session})
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v2/signout":
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != session {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			signouts++
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v2/users/1":
+			if r.Header.Get("Authorization") != "" {
+				t.Errorf("expected no Authorization header, got %q", r.Header.Get("Authorization"))
+			}
+			if cookie, err := r.Cookie("session"); err != nil || cookie.Value != session {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id":"1","name":"my-user"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, "", WithUserAndPass("my-user", "my-password"), WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetUserById("1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUserById("1"); err != nil {
+		t.Fatal(err)
+	}
+	if signins != 1 {
+		t.Fatalf("expected 1 signin, got %d", signins)
+	}
+
+	// expire the session on the server, the client should sign in again
+	mu.Lock()
+	session = "session-2"
+	mu.Unlock()
+	if _, err := c.GetUserById("1"); err != nil {
+		t.Fatal(err)
+	}
+	if signins != 2 {
+		t.Fatalf("expected 2 signins, got %d", signins)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if signouts != 1 {
+		t.Fatalf("expected 1 signout, got %d", signouts)
+	}
+}
+
+func TestClient_Session_concurrent(t *testing.T) {
+	var (
+		signins int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/signin":
+			if atomic.AddInt32(&signins, 1) == 1 {
+				close(started)
+			}
+			<-release
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "session-1"})
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v2/users/1":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id":"1","name":"my-user"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, "", WithUserAndPass("my-user", "my-password"), WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetUserById("1")
+			errs <- err
+		}()
+	}
+
+	// the client isn't locked while signing in
+	<-started
+	c.l.Lock()
+	c.l.Unlock()
+	close(release)
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := atomic.LoadInt32(&signins); n != 1 {
+		t.Errorf("expected 1 signin, got %d", n)
+	}
+}
+
+func TestNew_unixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "influxdb-client-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "influxdb.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/api/v2/query":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write([]byte("#datatype,string,long\n#group,false,false\n#default,_result,\n,result,table\n,,0\n"))
+		case "/health":
+			w.Write([]byte(`{"name":"influxdb","status":"pass"}`))
+		case "/api/v2/users/1":
+			w.Write([]byte(`{"id":"1"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	server.Listener = l
+	server.Start()
+	defer server.Close()
+
+	c, err := New("unix://"+socket, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got, want := c.Endpoint(), "unix://"+socket; got != want {
+		t.Errorf("expected endpoint %s, got %s", want, got)
+	}
+
+	ctx := context.Background()
+	if err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Write(ctx, "bucket", "org", createTestRowMetrics(t, 1)...); err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.QueryCSV(ctx, `from(bucket:"bucket") |> range(start: -1h)`, "org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for res.Next() {
+	}
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if health, err := c.GetHealth(ctx); err != nil || health.Status != "pass" {
+		t.Fatalf("unexpected health %v, %v", health, err)
+	}
+	if _, err := c.GetUserById("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/ready", "/api/v2/write", "/api/v2/query", "/health", "/api/v2/users/1"}
+	if !cmp.Equal(paths, want) {
+		t.Errorf("unexpected requests: %s", cmp.Diff(want, paths))
+	}
+}
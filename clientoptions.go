@@ -2,6 +2,8 @@ package influxdb
 
 import (
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"time"
@@ -35,19 +37,17 @@ type HTTPConfig struct {
 
 	// InsecureSkipVerify gets passed to the http client, if true, it will
 	// skip https certificate verification. Defaults to false.
-	// this currently isn't supported, set on the http client.
 	InsecureSkipVerify bool
 
 	// TLSConfig allows the user to set their own TLS config for the HTTP
 	// Client. If set, this option overrides InsecureSkipVerify.
-	// this currently isn't supported, set on the http client.
 	TLSConfig *tls.Config
 
 	// Proxy configures the Proxy function on the HTTP client.
-	// this currently isn't supported
 	Proxy func(req *http.Request) (*url.URL, error)
 
 	// If HTTPClient is nil, the New Client function will use an http client with sane defaults.
+	// If it is set, it is used as is, and Timeout, InsecureSkipVerify, TLSConfig and Proxy are ignored.
 	HTTPClient *http.Client
 }
 
@@ -66,21 +66,24 @@ func WithV1Config(conf *HTTPConfig) Option {
 					return err
 				}
 			}
-			if conf.UserAgent != "" {
-				if err := WithUserAgent(conf.UserAgent).f(c); err != nil {
-					return err
-				}
+			if conf.HTTPClient != nil {
+				return WithHTTPClient(conf.HTTPClient).f(c)
 			}
-			if conf.Timeout == 0 {
-				c.httpClient.Timeout = conf.Timeout
+
+			timeout := conf.Timeout
+			if timeout == 0 {
+				timeout = defaultTimeout
 			}
-			if conf.InsecureSkipVerify || conf.TLSConfig != nil || conf.Proxy != nil {
-				panic("Unimplemented")
+			transport := newTransport(c.socketPath)
+			transport.Proxy = conf.Proxy
+			if conf.TLSConfig != nil {
+				transport.TLSClientConfig = conf.TLSConfig
+			} else if conf.InsecureSkipVerify {
+				transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 			}
-			if conf.HTTPClient != nil {
-				if err := WithHTTPClient(conf.HTTPClient).f(c); err != nil {
-					return err
-				}
+			c.httpClient = &http.Client{
+				Timeout:   timeout,
+				Transport: transport,
 			}
 			return nil
 		},
@@ -93,6 +96,7 @@ func WithNoCompression() Option {
 		name: "WithNoCompression",
 		f: func(c *Client) error {
 			c.contentEncoding = ""
+			c.customCompressor = nil
 			return nil
 		},
 	}
@@ -106,13 +110,32 @@ func WithGZIP(n int) Option {
 		f: func(c *Client) error {
 			c.contentEncoding = "gzip"
 			c.compressionLevel = n
+			c.customCompressor = nil
+			return nil
+		},
+	}
+}
+
+// WithCompressor returns an option for compressing writes with a Compressor, like DeflateCompressor(zlib.BestSpeed),
+// instead of gzip. The server must accept the encoding: InfluxDB itself only accepts gzip.
+// Responses are decompressed with the compressors registered with RegisterCompressor.
+func WithCompressor(compressor Compressor) Option {
+	return Option{
+		name: "WithCompressor",
+		f: func(c *Client) error {
+			if compressor == nil {
+				return errors.New("the compressor must not be nil, use WithNoCompression to send writes uncompressed")
+			}
+			c.contentEncoding = compressor.Encoding()
+			c.customCompressor = compressor
 			return nil
 		},
 	}
 }
 
 // WithUserAndPass returns an option for setting a username and password, which generates a session for use.
-// TODO(docmerlin): session logic.
+// If no token is passed to New, the client signs in on its first request, keeps the session cookie,
+// signs in again when the session expires, and signs out on Close.
 func WithUserAndPass(username, password string) Option {
 	return Option{
 		name: "WithUserAndPass",
@@ -136,6 +159,7 @@ func WithUserAgent(ua string) Option {
 }
 
 // WithMaxLineBytes returns an option for setting the max length of a line of influx line-protocol in bytes.
+// Write splits the fields of a metric into several lines to fit, and fails if a single field doesn't fit in a line.
 func WithMaxLineBytes(n int) Option {
 	return Option{
 		name: "WithMaxLineBytes",
@@ -146,6 +170,60 @@ func WithMaxLineBytes(n int) Option {
 	}
 }
 
+// WithMaxBodyBytes returns an option for limiting the body of a write request to n bytes of line protocol, before compression.
+// Write splits larger writes into several requests, and fails if a single metric is larger than n.
+func WithMaxBodyBytes(n int) Option {
+	return Option{
+		name: "WithMaxBodyBytes",
+		f: func(c *Client) error {
+			c.maxBodyBytes = n
+			return nil
+		},
+	}
+}
+
+// WithMaxCompressedBodyBytes returns an option for limiting the body of a write request to n bytes as it is sent, after compression.
+// Write compresses each request's body in memory rather than as it is sent, to check its size,
+// splits it in half until the halves fit, and fails if a single metric doesn't fit.
+func WithMaxCompressedBodyBytes(n int) Option {
+	return Option{
+		name: "WithMaxCompressedBodyBytes",
+		f: func(c *Client) error {
+			c.maxCompressedBodyBytes = n
+			return nil
+		},
+	}
+}
+
+// WithPrecision returns an option for writing timestamps in units of precision, instead of nanoseconds.
+// It must be time.Nanosecond, time.Microsecond, time.Millisecond or time.Second.
+// Timestamps of metrics are truncated to the precision, and line protocol passed to WriteLineProtocol must use it.
+func WithPrecision(precision time.Duration) Option {
+	return Option{
+		name: "WithPrecision",
+		f: func(c *Client) error {
+			if _, ok := precisions[precision]; !ok {
+				return fmt.Errorf("unsupported write precision %s", precision)
+			}
+			c.precision = precision
+			return nil
+		},
+	}
+}
+
+// WithDefaultTags returns an option adding tags to each metric written which doesn't have them,
+// like the site or host the client runs on. Tags of the metric take precedence, even those with an empty value,
+// which aren't written, and the metric itself isn't changed. They aren't added to line protocol passed to WriteLineProtocol.
+func WithDefaultTags(tags map[string]string) Option {
+	return Option{
+		name: "WithDefaultTags",
+		f: func(c *Client) error {
+			c.defaultTags = sortedTags(tags)
+			return nil
+		},
+	}
+}
+
 // WithHTTPClient returns an option for setting a custom HTTP Client
 func WithHTTPClient(h *http.Client) Option {
 	return Option{
@@ -0,0 +1,110 @@
+package influxdb
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestWithV1Config(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	var proxied bool
+	tests := []struct {
+		name    string
+		conf    *HTTPConfig
+		wantErr bool
+	}{
+		{
+			name:    "untrusted certificate",
+			conf:    &HTTPConfig{},
+			wantErr: true,
+		},
+		{
+			name: "insecure skip verify",
+			conf: &HTTPConfig{InsecureSkipVerify: true},
+		},
+		{
+			name: "tls config",
+			conf: &HTTPConfig{TLSConfig: &tls.Config{RootCAs: pool}},
+		},
+		{
+			name: "tls config overrides insecure skip verify",
+			conf: &HTTPConfig{
+				InsecureSkipVerify: true,
+				TLSConfig:          &tls.Config{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "proxy",
+			conf: &HTTPConfig{
+				InsecureSkipVerify: true,
+				Proxy: func(req *http.Request) (*url.URL, error) {
+					proxied = true
+					return nil, nil
+				},
+			},
+		},
+		{
+			name: "http client",
+			conf: &HTTPConfig{
+				InsecureSkipVerify: false,
+				HTTPClient:         server.Client(),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(server.URL, "foo", WithV1Config(tt.conf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if err := c.Ping(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Client.Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if !proxied {
+		t.Error("expected the proxy func to be called")
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		c, err := New(server.URL, "foo", WithV1Config(&HTTPConfig{InsecureSkipVerify: true, Timeout: 50 * time.Millisecond}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if c.httpClient.Timeout != 50*time.Millisecond {
+			t.Fatalf("expected a timeout of 50ms, got %v", c.httpClient.Timeout)
+		}
+		if _, err := c.httpClient.Get(server.URL + "/slow"); err == nil {
+			t.Fatal("expected the request to time out")
+		}
+	})
+
+	t.Run("default timeout", func(t *testing.T) {
+		c, err := New(server.URL, "foo", WithV1Config(&HTTPConfig{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.httpClient.Timeout != defaultTimeout {
+			t.Fatalf("expected a timeout of %v, got %v", defaultTimeout, c.httpClient.Timeout)
+		}
+	})
+}
@@ -0,0 +1,368 @@
+package influxdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Compressor compresses request bodies, and decompresses responses, in a content encoding.
+// Implementations must be safe to use concurrently.
+type Compressor interface {
+	// Encoding is the name of the content encoding, like "gzip".
+	Encoding() string
+	// NewWriter returns a writer compressing into w. Closing it flushes what is left, without closing w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader decompressing r. Closing it doesn't close r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{
+		"gzip":    GzipCompressor(gzip.DefaultCompression),
+		"deflate": DeflateCompressor(zlib.DefaultCompression),
+	}
+)
+
+// RegisterCompressor makes a compressor's encoding available for responses, replacing any compressor registered for it before.
+// Clients accept responses in each registered encoding. Gzip and deflate are registered already.
+// Use WithCompressor to compress writes with it too.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	compressors[c.Encoding()] = c
+	compressorsMu.Unlock()
+}
+
+// registeredCompressor returns the compressor registered for encoding.
+func registeredCompressor(encoding string) (Compressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	c, ok := compressors[encoding]
+	return c, ok
+}
+
+// acceptEncoding returns the Accept-Encoding header listing the registered encodings, gzip first.
+func acceptEncoding() string {
+	compressorsMu.RLock()
+	encodings := make([]string, 0, len(compressors))
+	for encoding := range compressors {
+		encodings = append(encodings, encoding)
+	}
+	compressorsMu.RUnlock()
+	sort.Slice(encodings, func(i, j int) bool {
+		if (encodings[i] == "gzip") != (encodings[j] == "gzip") {
+			return encodings[i] == "gzip"
+		}
+		return encodings[i] < encodings[j]
+	})
+	return strings.Join(encodings, ", ")
+}
+
+// decompressResponse replaces the body of resp with its decompressed contents, if it has a Content-Encoding.
+// Closing the new body closes the original one.
+func decompressResponse(resp *http.Response) error {
+	encoding := strings.TrimSpace(resp.Header.Get("Content-Encoding"))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+	c, ok := registeredCompressor(encoding)
+	if !ok {
+		return fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	r, err := c.NewReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	resp.Body = &decompressedBody{Reader: r, r: r, body: resp.Body}
+	return nil
+}
+
+type decompressedBody struct {
+	io.Reader
+	r    io.Closer
+	body io.Closer
+}
+
+func (b *decompressedBody) Close() error {
+	_ = b.r.Close()
+	return b.body.Close()
+}
+
+var (
+	gzipCompressors    sync.Map // level -> *pooledCompressor
+	deflateCompressors sync.Map
+)
+
+// GzipCompressor returns a gzip Compressor at a compression level of compress/gzip.
+// Compressors for the same level share their pool of writers and readers.
+func GzipCompressor(level int) Compressor {
+	if c, ok := gzipCompressors.Load(level); ok {
+		return c.(Compressor)
+	}
+	c, _ := gzipCompressors.LoadOrStore(level, &pooledCompressor{
+		encoding: "gzip",
+		newWriter: func(w io.Writer) (resetWriter, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+		resetReader: func(rc io.ReadCloser, r io.Reader) error {
+			return rc.(*gzip.Reader).Reset(r)
+		},
+	})
+	return c.(Compressor)
+}
+
+// DeflateCompressor returns a deflate Compressor at a compression level of compress/zlib,
+// as the deflate content encoding is zlib's format.
+// Compressors for the same level share their pool of writers and readers.
+func DeflateCompressor(level int) Compressor {
+	if c, ok := deflateCompressors.Load(level); ok {
+		return c.(Compressor)
+	}
+	c, _ := deflateCompressors.LoadOrStore(level, &pooledCompressor{
+		encoding: "deflate",
+		newWriter: func(w io.Writer) (resetWriter, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+		newReader: zlib.NewReader,
+		resetReader: func(rc io.ReadCloser, r io.Reader) error {
+			return rc.(zlib.Resetter).Reset(r, nil)
+		},
+	})
+	return c.(Compressor)
+}
+
+type resetWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// pooledCompressor is a Compressor reusing its writers and readers once they are closed.
+type pooledCompressor struct {
+	encoding    string
+	newWriter   func(io.Writer) (resetWriter, error)
+	newReader   func(io.Reader) (io.ReadCloser, error)
+	resetReader func(io.ReadCloser, io.Reader) error
+
+	writers sync.Pool
+	readers sync.Pool
+}
+
+func (c *pooledCompressor) Encoding() string {
+	return c.encoding
+}
+
+func (c *pooledCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if zw, ok := c.writers.Get().(resetWriter); ok {
+		zw.Reset(w)
+		return &pooledWriter{resetWriter: zw, pool: &c.writers}, nil
+	}
+	zw, err := c.newWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledWriter{resetWriter: zw, pool: &c.writers}, nil
+}
+
+func (c *pooledCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	if zr, ok := c.readers.Get().(io.ReadCloser); ok {
+		if err := c.resetReader(zr, r); err != nil {
+			return nil, err
+		}
+		return &pooledReader{ReadCloser: zr, pool: &c.readers}, nil
+	}
+	zr, err := c.newReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledReader{ReadCloser: zr, pool: &c.readers}, nil
+}
+
+// pooledWriter returns its writer to the pool once closed.
+type pooledWriter struct {
+	resetWriter
+	pool *sync.Pool
+}
+
+func (w *pooledWriter) Close() error {
+	if w.resetWriter == nil {
+		return nil
+	}
+	err := w.resetWriter.Close()
+	w.pool.Put(w.resetWriter)
+	w.resetWriter = nil
+	return err
+}
+
+// pooledReader returns its reader to the pool once closed.
+type pooledReader struct {
+	io.ReadCloser
+	pool *sync.Pool
+}
+
+func (r *pooledReader) Close() error {
+	if r.ReadCloser == nil {
+		return nil
+	}
+	err := r.ReadCloser.Close()
+	r.pool.Put(r.ReadCloser)
+	r.ReadCloser = nil
+	return err
+}
+
+// compressionStats is how much compressing a request body took, for instrumentation.
+type compressionStats struct {
+	encoding string
+
+	mu      sync.Mutex
+	in, out int64
+	elapsed time.Duration
+}
+
+func (s *compressionStats) add(in, out int64, elapsed time.Duration) {
+	s.mu.Lock()
+	s.in += in
+	s.out += out
+	s.elapsed += elapsed
+	s.mu.Unlock()
+}
+
+type compressionKey struct{}
+
+// withCompressionStats records in the context of a request how its body was compressed.
+func withCompressionStats(ctx context.Context, s *compressionStats) context.Context {
+	return context.WithValue(ctx, compressionKey{}, s)
+}
+
+// compress returns data compressed with c, and adds to stats.
+func compress(c Compressor, data []byte, stats *compressionStats) ([]byte, error) {
+	start := time.Now()
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)/4))
+	w, err := c.NewWriter(buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	stats.add(int64(len(data)), int64(buf.Len()), time.Since(start))
+	return buf.Bytes(), nil
+}
+
+// compressingReader reads r compressed with a Compressor, compressing a chunk at a time as it is read,
+// so streaming a body needs neither a goroutine nor buffering it in full.
+// Closing it before the end of r is read releases the writer of the Compressor.
+type compressingReader struct {
+	r     io.Reader
+	w     io.WriteCloser // compresses into buf, nil once closed
+	buf   bytes.Buffer
+	chunk []byte
+	stats *compressionStats
+	err   error // the error to return once buf is drained
+
+	// the transport may close the body while reading it, so a Read in progress closes w instead
+	mu      sync.Mutex
+	reading bool
+	closed  bool
+}
+
+const compressChunkSize = 32 << 10
+
+func newCompressingReader(c Compressor, r io.Reader, stats *compressionStats) (*compressingReader, error) {
+	cr := &compressingReader{r: r, chunk: make([]byte, compressChunkSize), stats: stats}
+	w, err := c.NewWriter(&cr.buf)
+	if err != nil {
+		return nil, err
+	}
+	cr.w = w
+	return cr, nil
+}
+
+func (cr *compressingReader) Read(p []byte) (int, error) {
+	cr.mu.Lock()
+	if cr.closed {
+		cr.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	cr.reading = true
+	cr.mu.Unlock()
+	defer func() {
+		cr.mu.Lock()
+		cr.reading = false
+		if cr.closed {
+			cr.closeWriter()
+		}
+		cr.mu.Unlock()
+	}()
+
+	for cr.buf.Len() == 0 && cr.err == nil {
+		n, err := cr.r.Read(cr.chunk)
+		start, before := time.Now(), cr.buf.Len()
+		if n > 0 {
+			if _, werr := cr.w.Write(cr.chunk[:n]); werr != nil {
+				err = werr
+			}
+		}
+		if err != nil {
+			if cerr := cr.closeWriter(); err == io.EOF && cerr != nil {
+				err = cerr
+			}
+			cr.err = err
+		}
+		cr.stats.add(int64(n), int64(cr.buf.Len()-before), time.Since(start))
+	}
+	if cr.buf.Len() > 0 {
+		return cr.buf.Read(p)
+	}
+	return 0, cr.err
+}
+
+// Close releases the writer, if the end of r wasn't read already.
+func (cr *compressingReader) Close() error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.closed {
+		return nil
+	}
+	cr.closed = true
+	if !cr.reading {
+		cr.closeWriter()
+	}
+	return nil
+}
+
+// closeWriter closes w, once.
+func (cr *compressingReader) closeWriter() error {
+	if cr.w == nil {
+		return nil
+	}
+	err := cr.w.Close()
+	cr.w = nil
+	return err
+}
+
+// compressor returns the compressor for write requests, or nil if they aren't compressed.
+func (c *Client) compressor() Compressor {
+	switch {
+	case c.customCompressor != nil:
+		return c.customCompressor
+	case c.contentEncoding == "gzip":
+		return GzipCompressor(c.compressionLevel)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package influxdb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lancey-energy-storage/influxdb-client-go/influxdbtest"
+)
+
+const compressionText = `Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat.`
+
+func TestCompressors(t *testing.T) {
+	for _, c := range []Compressor{GzipCompressor(4), DeflateCompressor(zlib.BestSpeed)} {
+		t.Run(c.Encoding(), func(t *testing.T) {
+			// twice, to reuse the pooled writer and reader
+			for i := 0; i < 2; i++ {
+				data, err := compress(c, []byte(compressionText), &compressionStats{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				r, err := c.NewReader(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := ioutil.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := r.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != compressionText {
+					t.Fatalf("expected %q, got %q", compressionText, got)
+				}
+			}
+		})
+	}
+	if GzipCompressor(4) != GzipCompressor(4) {
+		t.Error("expected compressors of the same level to be shared")
+	}
+}
+
+func TestCompressingReader(t *testing.T) {
+	text := strings.Repeat(compressionText+"\n", 1000) // several chunks
+	stats := &compressionStats{encoding: "gzip"}
+	cr, err := newCompressingReader(GzipCompressor(4), strings.NewReader(text), stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(cr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != text {
+		t.Error("text did not encode or possibly decode properly")
+	}
+	if stats.in != int64(len(text)) || stats.out != int64(len(data)) {
+		t.Errorf("expected stats of %d bytes in and %d out, got %d and %d", len(text), len(data), stats.in, stats.out)
+	}
+}
+
+func TestClient_Write_compressor(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+
+	var (
+		mu     sync.Mutex
+		events []RequestEvent
+	)
+	c, err := New(s.URL, setup.Token,
+		WithCompressor(DeflateCompressor(zlib.BestSpeed)),
+		WithInstrumentation(InstrumentationFunc(func(e RequestEvent) {
+			mu.Lock()
+			events = append(events, e)
+			mu.Unlock()
+		})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := c.Write(ctx, "my-bucket", "my-org", createTestRowMetrics(t, 20)...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.WriteLineProtocol(ctx, "my-bucket", "my-org", strings.NewReader("cpu value=1 1556813561098000000\n")); err != nil {
+		t.Fatal(err)
+	}
+	// the metrics are all the same point, so the server stores one for them
+	if points := s.Points("my-org", "my-bucket"); len(points) != 2 {
+		t.Errorf("expected 2 points, got %d", len(points))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	for _, e := range events {
+		if e.ContentEncoding != "deflate" || e.UncompressedBytes <= 0 || e.BytesSent <= 0 || e.CompressionRatio() <= 0 {
+			t.Errorf("unexpected compression of %s: %+v", e.Op, e)
+		}
+	}
+	if e := events[0]; e.CompressionRatio() <= 1 {
+		t.Errorf("expected the metrics to compress, got a ratio of %f", e.CompressionRatio())
+	}
+
+	if _, err := New(s.URL, setup.Token, WithCompressor(nil)); err == nil {
+		t.Error("expected a nil compressor to fail")
+	}
+}
+
+// upperCompressor is an encoding of upper case text, to test registering compressors.
+type upperCompressor struct{}
+
+func (upperCompressor) Encoding() string { return "x-upper" }
+
+func (upperCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return nil, ErrUnimplemented
+}
+
+func (upperCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(strings.NewReader(strings.ToLower(string(data)))), nil
+}
+
+func TestClient_QueryCSV_encodings(t *testing.T) {
+	RegisterCompressor(upperCompressor{})
+	defer func() {
+		compressorsMu.Lock()
+		delete(compressors, "x-upper")
+		compressorsMu.Unlock()
+	}()
+
+	const csv = "#datatype,string,long\n#group,false,false\n#default,_result,\n,result,table\n,,0\n"
+	var encoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Accept-Encoding"), "gzip, deflate, x-upper"; got != want {
+			t.Errorf("expected Accept-Encoding %q, got %q", want, got)
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		switch encoding {
+		case "deflate":
+			zw := zlib.NewWriter(w)
+			_, _ = zw.Write([]byte(csv))
+			_ = zw.Close()
+		case "x-upper":
+			_, _ = w.Write([]byte(strings.ToUpper(csv)))
+		default:
+			_, _ = w.Write([]byte(csv))
+		}
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, "foo", WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, encoding = range []string{"", "deflate", "x-upper"} {
+		res, err := c.QueryCSV(context.Background(), `from(bucket:"bucket") |> range(start: -1h)`, "org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := 0
+		for res.Next() {
+			rows++
+		}
+		if res.Err != nil || rows != 1 {
+			t.Errorf("expected a row in encoding %q, got %d, %v", encoding, rows, res.Err)
+		}
+	}
+
+	encoding = "br"
+	if _, err := c.QueryCSV(context.Background(), `from(bucket:"bucket") |> range(start: -1h)`, "org"); err == nil {
+		t.Error("expected an unsupported encoding to fail")
+	}
+}
+
+func BenchmarkCompress(b *testing.B) {
+	data := []byte(strings.Repeat(compressionText+"\n", 100))
+	for _, c := range []Compressor{GzipCompressor(4), DeflateCompressor(zlib.BestSpeed)} {
+		b.Run(c.Encoding(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := compress(c, data, &compressionStats{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// countingCompressor is a Compressor counting its writers which haven't been closed.
+type countingCompressor struct {
+	Compressor
+	open int32
+}
+
+func (c *countingCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	zw, err := c.Compressor.NewWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt32(&c.open, 1)
+	return &countedWriter{WriteCloser: zw, open: &c.open}, nil
+}
+
+type countedWriter struct {
+	io.WriteCloser
+	open   *int32
+	closed bool
+}
+
+func (w *countedWriter) Close() error {
+	if !w.closed {
+		w.closed = true
+		atomic.AddInt32(w.open, -1)
+	}
+	return w.WriteCloser.Close()
+}
+
+// endlessLines reads the same line over and over.
+type endlessLines struct{}
+
+func (endlessLines) Read(p []byte) (int, error) {
+	const line = "cpu,host=a usage=0.5\n"
+	n := 0
+	for n+len(line) <= len(p) {
+		n += copy(p[n:], line)
+	}
+	return n, nil
+}
+
+func TestClient_WriteLineProtocol_canceled(t *testing.T) {
+	started, done := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = r.Body.Read(make([]byte, 1))
+		close(started)
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	compressor := &countingCompressor{Compressor: GzipCompressor(gzip.BestSpeed)}
+	c, err := New(server.URL, "foo", WithCompressor(compressor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := c.WriteLineProtocol(ctx, "bucket", "org", endlessLines{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the write to be canceled, got %v", err)
+	}
+
+	// the writer is released once the transport stops reading the body
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&compressor.open) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the writer compressing the body to be closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,266 @@
+package influxdb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lancey-energy-storage/influxdb-client-go/internal/toml"
+)
+
+// Environment variables read by NewFromEnv.
+const (
+	EnvHost       = "INFLUX_HOST"
+	EnvToken      = "INFLUX_TOKEN"
+	EnvOrg        = "INFLUX_ORG"
+	EnvBucket     = "INFLUX_BUCKET"
+	EnvGzipLevel  = "INFLUX_GZIP_LEVEL"  // 0 disables compression
+	EnvTimeout    = "INFLUX_TIMEOUT"     // a duration, like "30s"
+	EnvSkipVerify = "INFLUX_SKIP_VERIFY" // a bool, like "true"
+	EnvCACert     = "INFLUX_CA_CERT"     // path to a PEM encoded CA certificate
+)
+
+// EnvConfigsPath is the environment variable NewFromConfigFile reads the default config file path from.
+const EnvConfigsPath = "INFLUX_CONFIGS_PATH"
+
+// Defaults are the default org and bucket found alongside the connection details by NewFromEnv and NewFromConfigFile.
+type Defaults struct {
+	Org    string
+	Bucket string
+}
+
+// connectionConfig is the configuration shared by environment variables and config file profiles.
+type connectionConfig struct {
+	url        string
+	token      string
+	org        string
+	bucket     string
+	gzipLevel  *int
+	timeout    time.Duration
+	skipVerify bool
+	caCert     string
+}
+
+// NewFromEnv creates a new Client from environment variables,
+// and returns it with the default org and bucket.
+// It reads INFLUX_HOST, INFLUX_TOKEN, INFLUX_ORG and INFLUX_BUCKET, and the optional
+// INFLUX_GZIP_LEVEL, INFLUX_TIMEOUT, INFLUX_SKIP_VERIFY and INFLUX_CA_CERT.
+// options are applied after the ones derived from the environment, so they take precedence.
+func NewFromEnv(options ...Option) (*Client, Defaults, error) {
+	conf := connectionConfig{
+		url:    os.Getenv(EnvHost),
+		token:  os.Getenv(EnvToken),
+		org:    os.Getenv(EnvOrg),
+		bucket: os.Getenv(EnvBucket),
+		caCert: os.Getenv(EnvCACert),
+	}
+	if v := os.Getenv(EnvGzipLevel); v != "" {
+		level, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, Defaults{}, fmt.Errorf("invalid %s: %v", EnvGzipLevel, err)
+		}
+		conf.gzipLevel = &level
+	}
+	if v := os.Getenv(EnvTimeout); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, Defaults{}, fmt.Errorf("invalid %s: %v", EnvTimeout, err)
+		}
+		conf.timeout = timeout
+	}
+	if v := os.Getenv(EnvSkipVerify); v != "" {
+		skip, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, Defaults{}, fmt.Errorf("invalid %s: %v", EnvSkipVerify, err)
+		}
+		conf.skipVerify = skip
+	}
+	return conf.newClient(options)
+}
+
+// NewFromConfigFile creates a new Client from a profile in an influx CLI configs file,
+// and returns it with the default org and bucket.
+// If path is empty, it is read from INFLUX_CONFIGS_PATH, and defaults to ~/.influxdbv2/configs.
+// If profile is empty, the profile marked active is used, or else the one named "default", or else the first by name.
+// It fails if several profiles are marked active.
+//
+// A configs file looks like:
+//
+//	[default]
+//	  url = "http://localhost:9999"
+//	  token = "my-token"
+//	  org = "my-org"
+//	  active = true
+//
+// Besides the keys the influx CLI writes, a profile may set bucket, gzip_level, timeout (like "30s"),
+// skip_verify and ca_cert (path to a PEM encoded CA certificate).
+// options are applied after the ones derived from the profile, so they take precedence.
+func NewFromConfigFile(path, profile string, options ...Option) (*Client, Defaults, error) {
+	if path == "" {
+		path = os.Getenv(EnvConfigsPath)
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, Defaults{}, err
+		}
+		path = filepath.Join(home, ".influxdbv2", "configs")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, Defaults{}, err
+	}
+	defer f.Close()
+	tables, err := toml.Parse(f)
+	if err != nil {
+		return nil, Defaults{}, fmt.Errorf("could not parse %s: %v", path, err)
+	}
+
+	if profile == "" {
+		if profile, err = activeProfile(tables); err != nil {
+			return nil, Defaults{}, fmt.Errorf("%v in %s", err, path)
+		}
+	}
+	table, ok := tables[profile]
+	if !ok || profile == "" {
+		return nil, Defaults{}, fmt.Errorf("profile %q not found in %s", profile, path)
+	}
+
+	conf, err := configFromTable(table)
+	if err != nil {
+		return nil, Defaults{}, fmt.Errorf("profile %q in %s: %v", profile, path, err)
+	}
+	return conf.newClient(options)
+}
+
+// activeProfile returns the name of the profile marked active, or else of the one named "default",
+// or else the first by name. It fails if several are marked active, rather than picking one at random.
+func activeProfile(tables map[string]toml.Table) (string, error) {
+	var names, active []string
+	for name, table := range tables {
+		// keys outside of any profile are in the table named ""
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+		if ok, _ := table["active"].(bool); ok {
+			active = append(active, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(active)
+	switch {
+	case len(active) == 1:
+		return active[0], nil
+	case len(active) > 1:
+		return "", fmt.Errorf("profiles %q are all marked active", active)
+	}
+	if _, ok := tables["default"]; ok || len(names) == 0 {
+		return "default", nil
+	}
+	return names[0], nil
+}
+
+func configFromTable(t toml.Table) (connectionConfig, error) {
+	var conf connectionConfig
+	for key, dst := range map[string]*string{
+		"url":     &conf.url,
+		"token":   &conf.token,
+		"org":     &conf.org,
+		"bucket":  &conf.bucket,
+		"ca_cert": &conf.caCert,
+	} {
+		v, ok := t[key]
+		if !ok {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return conf, fmt.Errorf("%s must be a string", key)
+		}
+		*dst = s
+	}
+	if v, ok := t["gzip_level"]; ok {
+		level, ok := v.(int64)
+		if !ok {
+			return conf, errors.New("gzip_level must be an integer")
+		}
+		l := int(level)
+		conf.gzipLevel = &l
+	}
+	if v, ok := t["timeout"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return conf, errors.New("timeout must be a duration string")
+		}
+		timeout, err := time.ParseDuration(s)
+		if err != nil {
+			return conf, fmt.Errorf("invalid timeout: %v", err)
+		}
+		conf.timeout = timeout
+	}
+	if v, ok := t["skip_verify"]; ok {
+		skip, ok := v.(bool)
+		if !ok {
+			return conf, errors.New("skip_verify must be a boolean")
+		}
+		conf.skipVerify = skip
+	}
+	return conf, nil
+}
+
+// newClient creates a client from the config, with options applied last.
+func (conf connectionConfig) newClient(options []Option) (*Client, Defaults, error) {
+	var opts []Option
+	if conf.gzipLevel != nil && !hasOption(options, "WithGZIP", "WithNoCompression", "WithCompressor") {
+		if *conf.gzipLevel == 0 {
+			opts = append(opts, WithNoCompression())
+		} else {
+			opts = append(opts, WithGZIP(*conf.gzipLevel))
+		}
+	}
+	if conf.timeout != 0 || conf.skipVerify || conf.caCert != "" {
+		httpConf := &HTTPConfig{
+			Timeout:            conf.timeout,
+			InsecureSkipVerify: conf.skipVerify,
+		}
+		if conf.caCert != "" {
+			pem, err := ioutil.ReadFile(conf.caCert)
+			if err != nil {
+				return nil, Defaults{}, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, Defaults{}, fmt.Errorf("no certificates found in %s", conf.caCert)
+			}
+			httpConf.TLSConfig = &tls.Config{RootCAs: pool, InsecureSkipVerify: conf.skipVerify}
+		}
+		opts = append(opts, WithV1Config(httpConf))
+	}
+
+	c, err := New(conf.url, conf.token, append(opts, options...)...)
+	if err != nil {
+		return nil, Defaults{}, err
+	}
+	return c, Defaults{Org: conf.org, Bucket: conf.bucket}, nil
+}
+
+// hasOption reports whether any of options has one of the given names.
+func hasOption(options []Option, names ...string) bool {
+	for _, o := range options {
+		for _, name := range names {
+			if o.name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
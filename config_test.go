@@ -0,0 +1,179 @@
+package influxdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setenv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for k, v := range env {
+		old, ok := os.LookupEnv(k)
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatal(err)
+		}
+		k := k
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(k, old)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	setenv(t, map[string]string{
+		EnvHost:      "http://influx.example.com:9999",
+		EnvToken:     "my-token",
+		EnvOrg:       "my-org",
+		EnvBucket:    "my-bucket",
+		EnvGzipLevel: "7",
+		EnvTimeout:   "3s",
+	})
+
+	c, defaults, err := NewFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaults != (Defaults{Org: "my-org", Bucket: "my-bucket"}) {
+		t.Errorf("unexpected defaults %+v", defaults)
+	}
+	if got := c.url.String(); got != "http://influx.example.com:9999/api/v2" {
+		t.Errorf("unexpected url %s", got)
+	}
+	if c.authorization != "Token my-token" {
+		t.Errorf("unexpected authorization %s", c.authorization)
+	}
+	if c.contentEncoding != "gzip" || c.compressionLevel != 7 {
+		t.Errorf("unexpected compression %q %d", c.contentEncoding, c.compressionLevel)
+	}
+	if c.httpClient.Timeout != 3*time.Second {
+		t.Errorf("unexpected timeout %v", c.httpClient.Timeout)
+	}
+
+	// explicit options take precedence
+	c, _, err = NewFromEnv(WithNoCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.contentEncoding != "" {
+		t.Errorf("expected no compression, got %q", c.contentEncoding)
+	}
+
+	setenv(t, map[string]string{EnvTimeout: "soon"})
+	if _, _, err := NewFromEnv(); err == nil {
+		t.Error("expected an error for an invalid timeout")
+	}
+}
+
+func TestNewFromConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "influxdb-client-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "configs")
+	err = ioutil.WriteFile(path, []byte(`
+[default]
+  url = "http://localhost:9999"
+  token = "default-token"
+  org = "default-org"
+  active = false
+
+[site-a]
+  url = "https://site-a:9999"
+  token = "site-a-token"
+  org = "site-a-org"
+  bucket = "cells"
+  gzip_level = 0
+  skip_verify = true
+  active = true
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("active profile", func(t *testing.T) {
+		c, defaults, err := NewFromConfigFile(path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if defaults != (Defaults{Org: "site-a-org", Bucket: "cells"}) {
+			t.Errorf("unexpected defaults %+v", defaults)
+		}
+		if c.authorization != "Token site-a-token" || c.contentEncoding != "" {
+			t.Errorf("unexpected client %+v", c)
+		}
+		if !c.httpClient.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify {
+			t.Error("expected skip_verify to be honored")
+		}
+	})
+
+	t.Run("named profile", func(t *testing.T) {
+		c, defaults, err := NewFromConfigFile(path, "default")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if defaults != (Defaults{Org: "default-org"}) {
+			t.Errorf("unexpected defaults %+v", defaults)
+		}
+		if got := c.url.String(); got != "http://localhost:9999/api/v2" {
+			t.Errorf("unexpected url %s", got)
+		}
+	})
+
+	t.Run("path from env", func(t *testing.T) {
+		setenv(t, map[string]string{EnvConfigsPath: path})
+		if _, _, err := NewFromConfigFile("", "default"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("picked profile", func(t *testing.T) {
+		for _, test := range []struct {
+			name, configs, org string
+		}{
+			{
+				name:    "default",
+				configs: "[a]\n  org = \"a\"\n  token = \"t\"\n[default]\n  org = \"default\"\n  token = \"t\"\n[z]\n  org = \"z\"\n  token = \"t\"\n",
+				org:     "default",
+			},
+			{
+				name:    "first by name",
+				configs: "[z]\n  org = \"z\"\n  token = \"t\"\n[b]\n  org = \"b\"\n  token = \"t\"\n[c]\n  org = \"c\"\n  token = \"t\"\n",
+				org:     "b",
+			},
+			{
+				name:    "several active",
+				configs: "[a]\n  org = \"a\"\n  token = \"t\"\n  active = true\n[b]\n  org = \"b\"\n  token = \"t\"\n  active = true\n",
+			},
+		} {
+			picked := filepath.Join(dir, "picked")
+			if err := ioutil.WriteFile(picked, []byte(test.configs), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, defaults, err := NewFromConfigFile(picked, "")
+			if test.org == "" {
+				if err == nil {
+					t.Errorf("%s: expected an error", test.name)
+				}
+				continue
+			}
+			if err != nil || defaults.Org != test.org {
+				t.Errorf("%s: expected the profile of org %q, got %+v, %v", test.name, test.org, defaults, err)
+			}
+		}
+	})
+
+	t.Run("missing profile", func(t *testing.T) {
+		if _, _, err := NewFromConfigFile(path, "site-b"); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
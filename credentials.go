@@ -0,0 +1,233 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CredentialsProvider supplies the token the client authenticates with.
+// It is consulted for every request, so it should be fast and must be safe to call concurrently.
+type CredentialsProvider interface {
+	// Token returns the current token.
+	Token(ctx context.Context) (string, error)
+	// Refresh is called when the server rejects a token as unauthorized.
+	// It returns a token that should be tried instead, which may be the same token if nothing changed.
+	Refresh(ctx context.Context) (string, error)
+}
+
+// WithCredentials returns an option for authenticating with tokens from a CredentialsProvider,
+// instead of the fixed token passed to New.
+// When the server responds with 401 Unauthorized, the provider is refreshed, and the request is
+// retried once with the new token, provided its body can be replayed. Otherwise the new token is used from the next request on.
+func WithCredentials(p CredentialsProvider) Option {
+	return Option{
+		name: "WithCredentials",
+		f: func(c *Client) error {
+			c.credentials = p
+			c.authorization = ""
+			return nil
+		},
+	}
+}
+
+// StaticCredentials returns a CredentialsProvider that always returns token.
+func StaticCredentials(token string) CredentialsProvider {
+	return CredentialsFunc(func(context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// CredentialsFunc is an adapter to allow the use of an ordinary function, such as
+// a lookup in a secrets store, as a CredentialsProvider.
+// The function is called for every request, and again on refresh, so it should cache if lookups are slow.
+type CredentialsFunc func(ctx context.Context) (string, error)
+
+// Token calls f(ctx).
+func (f CredentialsFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Refresh calls f(ctx).
+func (f CredentialsFunc) Refresh(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// fileCheckInterval is how often FileCredentials checks whether its file changed.
+const fileCheckInterval = time.Second
+
+// FileCredentials returns a CredentialsProvider that reads the token from the file at path,
+// ignoring surrounding whitespace.
+// The file is checked for changes at most once a second, and re-read on refresh.
+func FileCredentials(path string) CredentialsProvider {
+	return &fileCredentials{path: path}
+}
+
+type fileCredentials struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	checked time.Time
+}
+
+func (f *fileCredentials) Token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.token != "" && time.Since(f.checked) < fileCheckInterval {
+		return f.token, nil
+	}
+	return f.load(false)
+}
+
+func (f *fileCredentials) Refresh(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.load(true)
+}
+
+// load re-reads the token if the file changed, or if force is set.
+// It must be called with f.mu held.
+func (f *fileCredentials) load(force bool) (string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", err
+	}
+	f.checked = time.Now()
+	if !force && f.token != "" && info.ModTime().Equal(f.modTime) {
+		return f.token, nil
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("token file " + f.path + " is empty")
+	}
+	f.token = token
+	f.modTime = info.ModTime()
+	return f.token, nil
+}
+
+// authorizer holds the credentials a single request is sent with.
+type authorizer struct {
+	header string       // the Authorization header
+	cookie *http.Cookie // the session cookie
+}
+
+// apply sets the credentials on a request, replacing any Authorization header.
+// It modifies the request, so callers should pass a clone.
+func (a authorizer) apply(req *http.Request) *http.Request {
+	req.Header.Del("Authorization")
+	if a.header != "" {
+		req.Header.Set("Authorization", a.header)
+	}
+	if a.cookie != nil {
+		req.AddCookie(a.cookie)
+	}
+	return req
+}
+
+// authorizer returns the credentials to send a request with: the token set by New or Setup,
+// a token from the CredentialsProvider, or else a session from the username and password.
+func (c *Client) authorizer(ctx context.Context) (authorizer, error) {
+	c.l.Lock()
+	authorization, credentials := c.authorization, c.credentials
+	useSession := c.username != "" || c.password != ""
+	c.l.Unlock()
+
+	switch {
+	case authorization != "":
+		return authorizer{header: authorization}, nil
+	case credentials != nil:
+		token, err := credentials.Token(ctx)
+		if err != nil {
+			return authorizer{}, err
+		}
+		return authorizer{header: "Token " + token}, nil
+	case useSession:
+		cookie, err := c.sessionCookie(ctx)
+		if err != nil {
+			return authorizer{}, err
+		}
+		return authorizer{cookie: cookie}, nil
+	default:
+		return authorizer{}, nil
+	}
+}
+
+// reauthorize returns new credentials after failed was rejected as unauthorized,
+// and whether they are worth retrying with.
+func (c *Client) reauthorize(ctx context.Context, failed authorizer) (authorizer, bool, error) {
+	c.l.Lock()
+	if failed.cookie != nil {
+		// the session has probably expired, so drop it and sign in again.
+		if c.session == failed.cookie {
+			c.session = nil
+		}
+		c.l.Unlock()
+		a, err := c.authorizer(ctx)
+		return a, err == nil, err
+	}
+	credentials := c.credentials
+	c.l.Unlock()
+
+	if credentials == nil || failed.header == "" {
+		return authorizer{}, false, nil
+	}
+	token, err := credentials.Refresh(ctx)
+	if err != nil {
+		return authorizer{}, false, err
+	}
+	a := authorizer{header: "Token " + token}
+	return a, a.header != failed.header, nil
+}
+
+// do sends an authenticated request, see authorizer.
+// A request rejected with 401 Unauthorized is retried once with refreshed credentials,
+// provided its body can be replayed.
+// op names the client operation the request belongs to, for logging and instrumentation.
+func (c *Client) do(op string, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	auth, err := c.authorizer(ctx)
+	if err != nil {
+		// as the transport would have, had it been sent
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := c.roundTrip(op, auth.apply(req.Clone(ctx)))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	retryAuth, retry, err := c.reauthorize(ctx, auth)
+	if err != nil {
+		c.log(LevelWarn, "could not refresh credentials", "op", op, "error", err)
+		return resp, nil
+	}
+	if !retry || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		// either nothing changed, or we can't replay the body, so let the caller deal with the 401.
+		// The refreshed credentials are used for the next request.
+		return resp, nil
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	r := retryAuth.apply(req.Clone(withAttempt(ctx, 2)))
+	if req.GetBody != nil {
+		if r.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return c.roundTrip(op, r)
+}
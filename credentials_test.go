@@ -0,0 +1,150 @@
+package influxdb
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// tokenServer accepts requests authorized with its current token.
+type tokenServer struct {
+	mu       sync.Mutex
+	token    string
+	rejected int
+}
+
+func (s *tokenServer) setToken(token string) {
+	s.mu.Lock()
+	s.token = token
+	s.mu.Unlock()
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("Authorization") != "Token "+s.token {
+		s.rejected++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":"unauthorized","message":"unauthorized access"}`))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestWithCredentials(t *testing.T) {
+	ts := &tokenServer{token: "token-1"}
+	server := httptest.NewServer(ts)
+	defer server.Close()
+
+	var current atomic.Value
+	current.Store("token-1")
+	provider := CredentialsFunc(func(context.Context) (string, error) {
+		return current.Load().(string), nil
+	})
+
+	c, err := New(server.URL, "", WithHTTPClient(server.Client()), WithCredentials(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.DeleteUser("1"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// rotate the token, the rejected request is retried with the new one
+	ts.setToken("token-2")
+	current.Store("token-2")
+	if err := c.DeleteUser("1"); err != nil {
+		t.Fatal(err)
+	}
+	if ts.rejected != 0 {
+		t.Fatalf("expected no rejected requests, got %d", ts.rejected)
+	}
+
+	// a rotation the provider doesn't know about yet fails
+	ts.setToken("token-3")
+	if err := c.DeleteUser("1"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "influxdb-client-go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(path, []byte("token-1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := &tokenServer{token: "token-1"}
+	server := httptest.NewServer(ts)
+	defer server.Close()
+
+	c, err := New(server.URL, "", WithHTTPClient(server.Client()), WithCredentials(FileCredentials(path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Write(context.Background(), "bucket", "org", createTestRowMetrics(t, 1)...); err != nil {
+		t.Fatal(err)
+	}
+
+	// the write is replayed with the new token the refresh picks up
+	ts.setToken("token-2")
+	if err := ioutil.WriteFile(path, []byte("token-2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Write(context.Background(), "bucket", "org", createTestRowMetrics(t, 1)...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithCredentials_setup(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/setup":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"auth":{"token":"setup-token"}}`))
+		default:
+			authorization = r.Header.Get("Authorization")
+			w.Write([]byte(`{"id":"1","name":"my-user"}`))
+		}
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, "", WithCredentials(StaticCredentials("provider-token")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.Setup(context.Background(), "my-user", "my-password", "my-bucket", "my-org", 0)
+	if err != nil || res.Auth.Token != "setup-token" {
+		t.Fatalf("expected the setup token, got %+v, %v", res, err)
+	}
+
+	// the provider isn't overridden by the token of the setup
+	if _, err := c.GetUserById("1"); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Token provider-token" {
+		t.Errorf("expected the token of the provider, got %q", authorization)
+	}
+}
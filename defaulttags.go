@@ -0,0 +1,156 @@
+package influxdb
+
+import (
+	"sort"
+
+	lp "github.com/influxdata/line-protocol"
+)
+
+// MetricWithDefaultTags returns m with the tags in defaults it doesn't have itself, in order of key.
+// A tag m sets to an empty value isn't written, and keeps the default out too.
+// m isn't changed. The client merges its own default tags, from WithDefaultTags, as it encodes metrics, so it doesn't need this.
+func MetricWithDefaultTags(m Metric, defaults map[string]string) Metric {
+	return DefaultTagger(defaults)(m)
+}
+
+// DefaultTagger returns a function doing what MetricWithDefaultTags does, for many metrics.
+// defaults are copied and sorted once, so changing them afterwards doesn't change the function.
+func DefaultTagger(defaults map[string]string) func(Metric) Metric {
+	sorted := sortedTags(defaults)
+	return func(m Metric) Metric {
+		return &defaultTagged{Metric: m, tags: mergeTags(m.TagList(), sorted)}
+	}
+}
+
+// defaultTagged is a metric with default tags merged into its own.
+type defaultTagged struct {
+	Metric
+	tags []*lp.Tag
+}
+
+func (m *defaultTagged) TagList() []*lp.Tag {
+	return m.tags
+}
+
+// sortedTags returns tags sorted by key, leaving out those with empty values.
+func sortedTags(tags map[string]string) []lp.Tag {
+	if len(tags) == 0 {
+		return nil
+	}
+	sorted := make([]lp.Tag, 0, len(tags))
+	for k, v := range tags {
+		if v != "" {
+			sorted = append(sorted, lp.Tag{Key: k, Value: v})
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	return sorted
+}
+
+// overlayTags returns the tags in base and over, sorted by key, with those in over taking precedence.
+func overlayTags(base, over []lp.Tag) []lp.Tag {
+	if len(base) == 0 {
+		return over
+	}
+	if len(over) == 0 {
+		return base
+	}
+	tags := make([]lp.Tag, 0, len(base)+len(over))
+	i, j := 0, 0
+	for i < len(base) || j < len(over) {
+		switch {
+		case j == len(over) || i < len(base) && base[i].Key < over[j].Key:
+			tags = append(tags, base[i])
+			i++
+		case i == len(base) || over[j].Key < base[i].Key:
+			tags = append(tags, over[j])
+			j++
+		default:
+			tags = append(tags, over[j])
+			i++
+			j++
+		}
+	}
+	return tags
+}
+
+// mergeTags returns tags with the defaults it doesn't have, sorted by key, leaving out empty values.
+func mergeTags(tags []*lp.Tag, defaults []lp.Tag) []*lp.Tag {
+	if len(defaults) == 0 {
+		return tags
+	}
+	merged := make([]*lp.Tag, 0, len(tags)+len(defaults))
+	for _, tag := range tags {
+		if tag.Value != "" {
+			merged = append(merged, &lp.Tag{Key: tag.Key, Value: tag.Value})
+		}
+	}
+	for _, d := range defaults {
+		if !hasTag(nil, tags, d.Key) {
+			merged = append(merged, &lp.Tag{Key: d.Key, Value: d.Value})
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Key < merged[j].Key })
+	return merged
+}
+
+// appendTags appends the tags of p, or else tags, with the defaults they don't have merged in by key.
+// The tags are usually sorted, as NewRowMetric and PointBuilder make them, and merged as they are appended.
+// Tags of other metrics which aren't are sorted along with the defaults first.
+func appendTags(dst []byte, p *Point, tags []*lp.Tag, defaults []lp.Tag) []byte {
+	if p == nil && len(defaults) > 0 && !tagsSorted(tags) {
+		tags = mergeTags(tags, defaults)
+		defaults = nil
+	}
+	n := len(tags)
+	if p != nil {
+		n = p.nTags
+	}
+	d := 0
+	appendDefaults := func(dst []byte, before string, last bool) []byte {
+		for ; d < len(defaults) && (last || defaults[d].Key < before); d++ {
+			if !hasTag(p, tags, defaults[d].Key) {
+				dst = appendTag(dst, defaults[d].Key, defaults[d].Value)
+			}
+		}
+		return dst
+	}
+	for i := 0; i < n; i++ {
+		key, value := tagAt(p, tags, i)
+		dst = appendDefaults(dst, key, false)
+		dst = appendTag(dst, key, value)
+	}
+	return appendDefaults(dst, "", true)
+}
+
+// tagsSorted reports whether tags are sorted by key.
+func tagsSorted(tags []*lp.Tag) bool {
+	for i := 1; i < len(tags); i++ {
+		if tags[i].Key < tags[i-1].Key {
+			return false
+		}
+	}
+	return true
+}
+
+// tagAt returns tag i of p, or else of tags.
+func tagAt(p *Point, tags []*lp.Tag, i int) (key, value string) {
+	if p != nil {
+		return p.entries[i].key, p.entries[i].str
+	}
+	return tags[i].Key, tags[i].Value
+}
+
+// hasTag reports whether p, or else tags, has a tag with key, even if its value is empty.
+func hasTag(p *Point, tags []*lp.Tag, key string) bool {
+	n := len(tags)
+	if p != nil {
+		n = p.nTags
+	}
+	for i := 0; i < n; i++ {
+		if k, _ := tagAt(p, tags, i); k == key {
+			return true
+		}
+	}
+	return false
+}
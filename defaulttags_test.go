@@ -0,0 +1,122 @@
+package influxdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cmp "github.com/google/go-cmp/cmp"
+	lp "github.com/influxdata/line-protocol"
+	"github.com/lancey-energy-storage/influxdb-client-go/influxdbtest"
+)
+
+func TestAppendMetric_defaultTags(t *testing.T) {
+	defaults := sortedTags(map[string]string{"a": "default", "c": "default", "e": "default", "empty": ""})
+	tests := []struct {
+		name string
+		m    Metric
+		want string
+	}{
+		{
+			name: "interleaved",
+			m:    NewRowMetric(map[string]interface{}{"v": 1}, "m", map[string]string{"b": "own", "d": "own"}, time.Time{}),
+			want: "m,a=default,b=own,c=default,d=own,e=default v=1i\n",
+		},
+		{
+			name: "overridden",
+			m:    NewRowMetric(map[string]interface{}{"v": 1}, "m", map[string]string{"c": "own", "f": "own"}, time.Time{}),
+			want: "m,a=default,c=own,e=default,f=own v=1i\n",
+		},
+		{
+			name: "empty own tag",
+			m:    NewRowMetric(map[string]interface{}{"v": 1}, "m", map[string]string{"c": ""}, time.Time{}),
+			want: "m,a=default,e=default v=1i\n",
+		},
+		{
+			name: "unsorted",
+			m: &defaultTagged{
+				Metric: NewRowMetric(map[string]interface{}{"v": 1}, "m", nil, time.Time{}),
+				tags:   []*lp.Tag{{Key: "d", Value: "own"}, {Key: "c", Value: "own"}, {Key: "b", Value: "own"}},
+			},
+			want: "m,a=default,b=own,c=own,d=own,e=default v=1i\n",
+		},
+		{
+			name: "point",
+			m:    mustPoint(t, NewPointBuilder().Measurement("m").Tag("a", "own").Tag("z", "own").Int("v", 1)),
+			want: "m,a=own,c=default,e=default,z=own v=1i\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := tt.m.TagList()
+			got, err := appendMetric(nil, tt.m, time.Nanosecond, true, defaults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("appendMetric() = %q, want %q", got, tt.want)
+			}
+			if diff := cmp.Diff(tags, tt.m.TagList()); diff != "" {
+				t.Errorf("the tags of the metric changed: %s", diff)
+			}
+
+			// the lp.Encoder, used to split lines, gets the same tags
+			wrapped, err := appendMetric(nil, &defaultTagged{Metric: tt.m, tags: mergeTags(tt.m.TagList(), defaults)}, time.Nanosecond, true, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(wrapped) != tt.want {
+				t.Errorf("merged tags encoded to %q, want %q", wrapped, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricWithDefaultTags(t *testing.T) {
+	m := NewRowMetric(map[string]interface{}{"v": 1}, "m", map[string]string{"rack": "3"}, time.Time{})
+	got := MetricWithDefaultTags(m, map[string]string{"site": "a", "rack": "1"})
+	want := []*lp.Tag{{Key: "rack", Value: "3"}, {Key: "site", Value: "a"}}
+	if diff := cmp.Diff(want, got.TagList()); diff != "" {
+		t.Errorf("unexpected tags: %s", diff)
+	}
+	if len(m.TagList()) != 1 {
+		t.Errorf("expected the metric to keep its own tags, got %v", m.TagList())
+	}
+}
+
+func TestClient_Write_defaultTags(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+
+	ctx := context.Background()
+	ts := time.Date(2019, 5, 2, 16, 12, 41, 0, time.UTC)
+	for _, opts := range [][]Option{nil, {WithMaxLineBytes(100)}} {
+		c, err := New(s.URL, setup.Token, append(opts, WithDefaultTags(map[string]string{"site": "a", "rack": "1"}))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := NewRowMetric(map[string]interface{}{"v": 1}, "m", map[string]string{"rack": "2"}, ts)
+		if _, err := c.Write(ctx, "my-bucket", "my-org", m); err != nil {
+			t.Fatal(err)
+		}
+		firmware := WithWriteDefaultTags(map[string]string{"firmware": "1.2", "site": "b"})
+		if _, err := c.WriteMetrics(ctx, "my-bucket", "my-org", []Metric{m}, firmware); err != nil {
+			t.Fatal(err)
+		}
+		if len(m.TagList()) != 1 {
+			t.Errorf("expected the metric to keep its own tags, got %v", m.TagList())
+		}
+	}
+
+	points := s.Points("my-org", "my-bucket")
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+	if want := map[string]string{"site": "a", "rack": "2"}; !cmp.Equal(want, points[0].Tags) {
+		t.Errorf("expected tags %v, got %v", want, points[0].Tags)
+	}
+	if want := map[string]string{"site": "b", "rack": "2", "firmware": "1.2"}; !cmp.Equal(want, points[1].Tags) {
+		t.Errorf("expected tags %v, got %v", want, points[1].Tags)
+	}
+}
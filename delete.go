@@ -0,0 +1,48 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Delete deletes the points between start and stop in a bucket in org.
+// predicate, like `_measurement="cpu" AND host="a"`, limits the points deleted, and an empty predicate deletes them all.
+// It fails with an error matching ErrUnsupported if the server's version doesn't have the delete API.
+func (c *Client) Delete(ctx context.Context, bucket, org string, start, stop time.Time, predicate string) error {
+	if bucket == "" || org == "" {
+		return errors.New("a bucket and an org are required")
+	}
+	if stop.Before(start) {
+		return errors.New("stop must not be before start")
+	}
+	if err := c.requireFeature(ctx, "Delete", FeatureDelete); err != nil {
+		return err
+	}
+	c.log(LevelDebug, "deleting points", "op", "Delete", "bucket", bucket, "org", org)
+
+	query := url.Values{}
+	query.Set("bucket", bucket)
+	query.Set("org", org)
+
+	return c.doAPI(ctx, apiRequest{
+		op:     "Delete",
+		method: http.MethodPost,
+		path:   c.apiPath("delete"),
+		query:  query,
+		body: deleteRequest{
+			Start:     start.UTC().Format(time.RFC3339Nano),
+			Stop:      stop.UTC().Format(time.RFC3339Nano),
+			Predicate: predicate,
+		},
+		status: []int{http.StatusNoContent},
+	}, nil)
+}
+
+type deleteRequest struct {
+	Start     string `json:"start"`
+	Stop      string `json:"stop"`
+	Predicate string `json:"predicate,omitempty"`
+}
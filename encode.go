@@ -0,0 +1,192 @@
+package influxdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	lp "github.com/influxdata/line-protocol"
+)
+
+// AppendMetric appends m to dst as line protocol, with its timestamp in units of precision, and returns the extended buffer.
+// It writes what the client sends for m, without the allocations of going through an lp.Encoder, and with none at all for a *Point.
+// Tags with an empty key or value are left out, as lp.Encoder does, and a field with a NaN, infinite, or unsupported value is an error.
+func AppendMetric(dst []byte, m Metric, precision time.Duration) ([]byte, error) {
+	if _, ok := precisions[precision]; !ok {
+		return dst, fmt.Errorf("unsupported write precision %s", precision)
+	}
+	return appendMetric(dst, m, precision, true, nil)
+}
+
+// errInvalidFieldKey is the error for a field key lp.Encoder can't encode.
+var errInvalidFieldKey = errors.New("invalid field key")
+
+// The characters escaped with a backslash, besides tabs, newlines, form feeds and carriage returns.
+const (
+	nameEscapes        = ", "
+	keyEscapes         = ", ="
+	stringFieldEscapes = `"\`
+)
+
+// appendMetric appends m to dst like lp.Encoder with uint support, without splitting it across lines,
+// merging in the default tags m doesn't have. On error, dst is returned as it was.
+func appendMetric(dst []byte, m Metric, precision time.Duration, failOnFieldErr bool, defaults []lp.Tag) ([]byte, error) {
+	start := len(dst)
+	name := m.Name()
+	if name == "" {
+		return dst, lp.ErrInvalidName
+	}
+	dst = appendEscaped(dst, name, nameEscapes)
+
+	// the fields of a Point with default tags are still encoded without boxing them
+	fieldsOf := m
+	if t, ok := m.(*defaultTagged); ok {
+		fieldsOf = t.Metric
+	}
+	p, isPoint := fieldsOf.(*Point)
+	if isPoint && fieldsOf == m {
+		dst = appendTags(dst, p, nil, defaults)
+	} else {
+		dst = appendTags(dst, nil, m.TagList(), defaults)
+	}
+
+	fields := 0
+	if isPoint {
+		for i := p.nTags; i < len(p.entries); i++ {
+			var err error
+			if dst, err = appendField(dst, fields == 0, p.entries[i].key, &p.entries[i], nil); err != nil {
+				if failOnFieldErr {
+					return dst[:start], err
+				}
+				continue
+			}
+			fields++
+		}
+	} else {
+		for _, f := range fieldsOf.FieldList() {
+			var err error
+			if dst, err = appendField(dst, fields == 0, f.Key, nil, f.Value); err != nil {
+				if failOnFieldErr {
+					return dst[:start], err
+				}
+				continue
+			}
+			fields++
+		}
+	}
+	if fields == 0 {
+		return dst[:start], lp.ErrNoFields
+	}
+
+	if ts := m.Time(); !ts.IsZero() {
+		dst = append(dst, ' ')
+		dst = strconv.AppendInt(dst, truncateTime(ts, precision), 10)
+	}
+	return append(dst, '\n'), nil
+}
+
+// appendField appends a field, with the value of e if it isn't nil, else value.
+// On error, dst is returned as it was.
+func appendField(dst []byte, first bool, key string, e *pointEntry, value interface{}) ([]byte, error) {
+	mark := len(dst)
+	if first {
+		dst = append(dst, ' ')
+	} else {
+		dst = append(dst, ',')
+	}
+	keyStart := len(dst)
+	dst = appendEscaped(dst, key, keyEscapes)
+	if key := dst[keyStart:]; len(key) == 0 || len(key) == 2 && key[0] == '\\' {
+		// the keys lp.Encoder can't encode
+		return dst[:mark], errInvalidFieldKey
+	}
+	dst = append(dst, '=')
+	var err error
+	if e != nil {
+		dst, err = e.appendValue(dst)
+	} else {
+		dst, err = appendFieldValue(dst, value)
+	}
+	if err != nil {
+		return dst[:mark], err
+	}
+	return dst, nil
+}
+
+func appendTag(dst []byte, key, value string) []byte {
+	if key == "" || value == "" {
+		return dst
+	}
+	dst = append(dst, ',')
+	dst = appendEscaped(dst, key, keyEscapes)
+	dst = append(dst, '=')
+	return appendEscaped(dst, value, keyEscapes)
+}
+
+// appendFieldValue appends the line protocol for a field value of one of the types lp.Encoder takes.
+func appendFieldValue(dst []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case uint64:
+		return append(strconv.AppendUint(dst, v, 10), 'u'), nil
+	case int64:
+		return append(strconv.AppendInt(dst, v, 10), 'i'), nil
+	case int:
+		return append(strconv.AppendInt(dst, int64(v), 10), 'i'), nil
+	case float64:
+		return appendFloat(dst, v)
+	case float32:
+		return appendFloat(dst, float64(v))
+	case string:
+		return appendString(dst, v), nil
+	case []byte:
+		return appendString(dst, string(v)), nil
+	case bool:
+		return strconv.AppendBool(dst, v), nil
+	}
+	return dst, fmt.Errorf("invalid value type: %T", value)
+}
+
+func appendFloat(dst []byte, v float64) ([]byte, error) {
+	if math.IsNaN(v) {
+		return dst, lp.ErrIsNaN
+	}
+	if math.IsInf(v, 0) {
+		return dst, lp.ErrIsInf
+	}
+	return strconv.AppendFloat(dst, v, 'f', -1, 64), nil
+}
+
+func appendString(dst []byte, v string) []byte {
+	dst = append(dst, '"')
+	dst = appendEscaped(dst, v, stringFieldEscapes)
+	return append(dst, '"')
+}
+
+// appendEscaped appends s, writing tabs, newlines, form feeds and carriage returns as \t, \n, \f and \r,
+// and putting a backslash before each of the characters in escapes.
+func appendEscaped(dst []byte, s, escapes string) []byte {
+	if !strings.ContainsAny(s, "\t\n\f\r") && !strings.ContainsAny(s, escapes) {
+		return append(dst, s...)
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\t':
+			dst = append(dst, `\t`...)
+		case '\n':
+			dst = append(dst, `\n`...)
+		case '\f':
+			dst = append(dst, `\f`...)
+		case '\r':
+			dst = append(dst, `\r`...)
+		default:
+			if strings.IndexByte(escapes, c) >= 0 {
+				dst = append(dst, '\\')
+			}
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
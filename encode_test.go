@@ -0,0 +1,125 @@
+package influxdb
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	lp "github.com/influxdata/line-protocol"
+)
+
+func TestAppendMetric(t *testing.T) {
+	ts := time.Date(2019, 5, 2, 16, 12, 41, 98765432, time.UTC)
+	tests := []struct {
+		name string
+		m    Metric
+	}{
+		{name: "simple", m: NewRowMetric(map[string]interface{}{"usage": 0.5}, "cpu", map[string]string{"host": "a"}, ts)},
+		{name: "no time", m: NewRowMetric(map[string]interface{}{"usage": 0.5}, "cpu", nil, time.Time{})},
+		{name: "types", m: NewRowMetric(map[string]interface{}{
+			"f": 1.5, "f32": float32(2), "i": 3, "u": uint64(4), "b": true, "s": "str", "bytes": []byte("b"),
+		}, "types", nil, ts)},
+		{name: "escapes", m: NewRowMetric(map[string]interface{}{
+			"a key=": "a \"quoted\"\tvalue\\\n",
+		}, "a measurement,=", map[string]string{"a tag,": "a value=\n"}, ts)},
+		{name: "empty tags", m: NewRowMetric(map[string]interface{}{"v": 1}, "m", map[string]string{"": "a", "b": ""}, ts)},
+		{name: "point", m: mustPoint(t, NewPointBuilder().Measurement("cpu").Tag("host", "a").Float("usage", 0.5).Time(ts))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for precision := range precisions {
+				buf := &bytes.Buffer{}
+				if _, err := newEncoder(buf, precision, true).Encode(tt.m); err != nil {
+					t.Fatal(err)
+				}
+				got, err := AppendMetric([]byte("prefix\n"), tt.m, precision)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := "prefix\n" + buf.String(); string(got) != want {
+					t.Errorf("AppendMetric() at precision %s = %q, want %q", precision, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAppendMetric_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Metric
+		want error
+	}{
+		{name: "no name", m: NewRowMetric(map[string]interface{}{"v": 1}, "", nil, time.Time{}), want: lp.ErrInvalidName},
+		{name: "no fields", m: NewRowMetric(nil, "m", nil, time.Time{}), want: lp.ErrNoFields},
+		{name: "NaN", m: NewRowMetric(map[string]interface{}{"v": math.NaN()}, "m", nil, time.Time{}), want: lp.ErrIsNaN},
+		{name: "Inf", m: mustPoint(t, NewPointBuilder().Measurement("m").Float("v", math.Inf(1))), want: lp.ErrIsInf},
+		{name: "invalid key", m: NewRowMetric(map[string]interface{}{"": 1}, "m", nil, time.Time{}), want: errInvalidFieldKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := []byte("prefix\n")
+			got, err := AppendMetric(dst, tt.m, time.Nanosecond)
+			if err != tt.want {
+				t.Errorf("AppendMetric() error = %v, want %v", err, tt.want)
+			}
+			if string(got) != string(dst) {
+				t.Errorf("AppendMetric() = %q, want the buffer unchanged", got)
+			}
+		})
+	}
+
+	if _, err := AppendMetric(nil, createTestRowMetrics(t, 1)[0], time.Minute); err == nil {
+		t.Error("expected an unsupported precision to fail")
+	}
+}
+
+func benchmarkEncode(b *testing.B, m Metric, encode func([]byte, Metric) []byte) {
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = encode(buf[:0], m)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	ts := time.Now()
+	fields := map[string]interface{}{"voltage": 3.3, "current": 1.2, "soc": int64(87), "status": "ok"}
+	tags := map[string]string{"site": "a", "rack": "3"}
+	row := NewRowMetric(fields, "cells", tags, ts)
+	pb := NewPointBuilder()
+	for k, v := range fields {
+		pb.Field(k, v)
+	}
+	for k, v := range tags {
+		pb.Tag(k, v)
+	}
+	point, err := pb.Measurement("cells").Time(ts).Point()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("lp.Encoder", func(b *testing.B) {
+		buf := &bytes.Buffer{}
+		e := newEncoder(buf, time.Nanosecond, true)
+		benchmarkEncode(b, row, func(dst []byte, m Metric) []byte {
+			buf.Reset()
+			if _, err := e.Encode(m); err != nil {
+				b.Fatal(err)
+			}
+			return dst
+		})
+	})
+	for name, m := range map[string]Metric{"RowMetric": row, "Point": point} {
+		b.Run("AppendMetric/"+name, func(b *testing.B) {
+			benchmarkEncode(b, m, func(dst []byte, m Metric) []byte {
+				dst, err := AppendMetric(dst, m, time.Nanosecond)
+				if err != nil {
+					b.Fatal(err)
+				}
+				return dst
+			})
+		})
+	}
+}
@@ -3,6 +3,11 @@ package influxdb
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Error code constants copied from influxdb
@@ -24,6 +29,50 @@ const (
 // ErrUnimplemented is an error for when pieces of the client's functionality is unimplemented.
 var ErrUnimplemented = errors.New("unimplemented")
 
+// Sentinel errors matching an *Error by its code or status code, for use with errors.Is:
+//
+//	if errors.Is(err, influxdb.ErrNotFound) {
+//		... // create it
+//	}
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrConflict     = errors.New("conflict")
+	ErrInvalid      = errors.New("invalid")
+	ErrTooLarge     = errors.New("request too large")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnavailable  = errors.New("unavailable")
+	ErrInternal     = errors.New("internal error")
+)
+
+var codeErrors = map[string]error{
+	ENotFound:            ErrNotFound,
+	EUnauthorized:        ErrUnauthorized,
+	EForbidden:           ErrForbidden,
+	EConflict:            ErrConflict,
+	EInvalid:             ErrInvalid,
+	EUnprocessableEntity: ErrInvalid,
+	EEmptyValue:          ErrInvalid,
+	ETooLarge:            ErrTooLarge,
+	ETooManyRequests:     ErrRateLimited,
+	EUnavailable:         ErrUnavailable,
+	EInternal:            ErrInternal,
+}
+
+var statusErrors = map[int]error{
+	http.StatusNotFound:              ErrNotFound,
+	http.StatusUnauthorized:          ErrUnauthorized,
+	http.StatusForbidden:             ErrForbidden,
+	http.StatusConflict:              ErrConflict,
+	http.StatusBadRequest:            ErrInvalid,
+	http.StatusUnprocessableEntity:   ErrInvalid,
+	http.StatusRequestEntityTooLarge: ErrTooLarge,
+	http.StatusTooManyRequests:       ErrRateLimited,
+	http.StatusServiceUnavailable:    ErrUnavailable,
+	http.StatusInternalServerError:   ErrInternal,
+}
+
 // Error is an error returned by a client operation
 // It contains a number of contextual fields which describe the nature
 // and cause of the error
@@ -36,18 +85,150 @@ type Error struct {
 	Line       *int32
 	MaxLength  *int32
 	RetryAfter *int32
+	// RequestID is the id the server assigned to the request, if it sent one. Quote it in support tickets.
+	RequestID string `json:"-"`
+	// Header holds the response headers, if the error came from a response.
+	Header http.Header `json:"-"`
 }
 
 // Error returns the string representation of the Error struct
 func (e *Error) Error() string {
 	errString := fmt.Sprintf("%s (%s): %s", e.Code, e.Op, e.Message)
 	if e.Line != nil {
-		return fmt.Sprintf("%s - line[%d]", errString, e.Line)
+		errString = fmt.Sprintf("%s - line[%d]", errString, *e.Line)
+	} else if e.MaxLength != nil {
+		errString = fmt.Sprintf("%s - maxlen[%d]", errString, *e.MaxLength)
 	}
 
-	if e.MaxLength != nil {
-		return fmt.Sprintf("%s - maxlen[%d]", errString, e.MaxLength)
+	if e.RequestID != "" {
+		errString = fmt.Sprintf("%s - request-id[%s]", errString, e.RequestID)
 	}
-
 	return errString
 }
+
+// Is reports whether target is the sentinel error matching e's code or status code, like ErrNotFound.
+func (e *Error) Is(target error) bool {
+	if target == nil {
+		return false
+	}
+	return codeErrors[e.Code] == target || statusErrors[e.StatusCode] == target
+}
+
+// WriteError is returned by Write when the server rejected some of the metrics,
+// and the lines it reported could be traced back to them. It unwraps to the server's *Error.
+type WriteError struct {
+	// Err is the error the server responded with.
+	Err *Error
+	// Rejected are the metrics the server rejected, in order.
+	Rejected []RejectedMetric
+	// Dropped is set if the rejected metrics were dropped and the rest written, with WithDropRejected.
+	Dropped bool
+}
+
+// RejectedMetric is a metric the server rejected.
+type RejectedMetric struct {
+	// Index is the index of the metric in the metrics passed to Write.
+	Index int
+	// Line is the 1-based line of the metric in the body of the request.
+	Line int
+	// Message is the reason the server gave.
+	Message string
+}
+
+// Error returns the server's error, with the rejected metrics.
+func (e *WriteError) Error() string {
+	indexes := make([]string, len(e.Rejected))
+	for i, r := range e.Rejected {
+		indexes[i] = strconv.Itoa(r.Index)
+	}
+	if e.Dropped {
+		return fmt.Sprintf("%s - dropped metrics[%s]", e.Err.Error(), strings.Join(indexes, ","))
+	}
+	return fmt.Sprintf("%s - rejected metrics[%s]", e.Err.Error(), strings.Join(indexes, ","))
+}
+
+// Unwrap returns the server's error, so errors.Is and errors.As see through the WriteError.
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+func (e *WriteError) rejects(i int) bool {
+	for _, r := range e.Rejected {
+		if r.Index == i {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRetryable reports whether err is worth retrying later:
+// the server was rate limiting or unavailable, or the request timed out.
+// A timeout includes the deadline of the request's context, so callers retrying in a loop should check their context too.
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrUnavailable) {
+		return true
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode == http.StatusBadGateway || e.StatusCode == http.StatusGatewayTimeout
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// RetryAfter returns how long the server asked to wait before retrying, if err is an *Error with a Retry-After header.
+func RetryAfter(err error) (time.Duration, bool) {
+	var e *Error
+	if !errors.As(err, &e) || e.RetryAfter == nil {
+		return 0, false
+	}
+	return time.Duration(*e.RetryAfter) * time.Second, true
+}
+
+// newErrorFromResponse returns an *Error with the status code, request id, headers and Retry-After of resp.
+func newErrorFromResponse(resp *http.Response) *Error {
+	err := &Error{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get("Request-Id"),
+		Header:     resp.Header,
+	}
+	if err.RequestID == "" {
+		err.RequestID = resp.Header.Get("X-Request-Id")
+	}
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if retry, perr := parseInt32(v); perr == nil {
+			err.RetryAfter = &retry
+		}
+	}
+	return err
+}
+
+// codeFromStatus returns the error code matching the status of a response that carried none.
+func codeFromStatus(resp *http.Response) string {
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		return EInvalid
+	case http.StatusUnauthorized:
+		return EUnauthorized
+	case http.StatusForbidden:
+		return EForbidden
+	case http.StatusNotFound:
+		return ENotFound
+	case http.StatusMethodNotAllowed:
+		return EMethodNotAllowed
+	case http.StatusConflict:
+		return EConflict
+	case http.StatusRequestEntityTooLarge:
+		return ETooLarge
+	case http.StatusUnprocessableEntity:
+		return EUnprocessableEntity
+	case http.StatusTooManyRequests:
+		return ETooManyRequests
+	case http.StatusServiceUnavailable:
+		return EUnavailable
+	}
+	if resp.StatusCode >= 500 {
+		return EInternal
+	}
+	return resp.Status
+}
@@ -0,0 +1,97 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestError_Error(t *testing.T) {
+	line, maxLength := int32(3), int32(64)
+	for _, test := range []struct {
+		err  *Error
+		want string
+	}{
+		{
+			err:  &Error{Code: EInvalid, Op: "writing", Message: "bad line", Line: &line},
+			want: "invalid (writing): bad line - line[3]",
+		},
+		{
+			err:  &Error{Code: ETooLarge, Op: "writing", Message: "line too long", MaxLength: &maxLength},
+			want: "request too large (writing): line too long - maxlen[64]",
+		},
+		{
+			err:  &Error{Code: ENotFound, Op: "GetBucketByID", Message: "bucket not found", RequestID: "0a1b2c"},
+			want: "not found (GetBucketByID): bucket not found - request-id[0a1b2c]",
+		},
+	} {
+		if got := test.err.Error(); got != test.want {
+			t.Errorf("expected %q, got %q", test.want, got)
+		}
+	}
+}
+
+func TestError_Is(t *testing.T) {
+	for _, test := range []struct {
+		err    error
+		target error
+		want   bool
+	}{
+		{err: &Error{Code: ENotFound}, target: ErrNotFound, want: true},
+		{err: &Error{StatusCode: http.StatusNotFound, Code: "404 Not Found"}, target: ErrNotFound, want: true},
+		{err: &Error{Code: ETooManyRequests}, target: ErrRateLimited, want: true},
+		{err: &Error{Code: EUnprocessableEntity}, target: ErrInvalid, want: true},
+		{err: fmt.Errorf("getting bucket: %w", &Error{StatusCode: http.StatusUnauthorized}), target: ErrUnauthorized, want: true},
+		{err: &Error{Code: ENotFound}, target: ErrConflict, want: false},
+		{err: &Error{Code: "something else"}, target: ErrInternal, want: false},
+		{err: errors.New("not found"), target: ErrNotFound, want: false},
+	} {
+		if got := errors.Is(test.err, test.target); got != test.want {
+			t.Errorf("errors.Is(%v, %v): expected %v, got %v", test.err, test.target, test.want, got)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+	client := &http.Client{Timeout: time.Millisecond}
+	_, timeout := client.Get(server.URL)
+
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{err: &Error{Code: EUnavailable}, want: true},
+		{err: &Error{StatusCode: http.StatusTooManyRequests}, want: true},
+		{err: &Error{StatusCode: http.StatusBadGateway, Code: EInternal}, want: true},
+		{err: &Error{StatusCode: http.StatusInternalServerError, Code: EInternal}, want: false},
+		{err: &Error{Code: ENotFound}, want: false},
+		{err: timeout, want: true},
+		{err: context.Canceled, want: false},
+		{err: errors.New("boom"), want: false},
+	} {
+		if got := IsRetryable(test.err); got != test.want {
+			t.Errorf("IsRetryable(%v): expected %v, got %v", test.err, test.want, got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	five := int32(5)
+	if d, ok := RetryAfter(fmt.Errorf("writing: %w", &Error{RetryAfter: &five})); !ok || d != 5*time.Second {
+		t.Errorf("expected 5s, got %v, %v", d, ok)
+	}
+	if _, ok := RetryAfter(&Error{}); ok {
+		t.Error("expected no retry after")
+	}
+	if _, ok := RetryAfter(errors.New("boom")); ok {
+		t.Error("expected no retry after")
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"text/template"
 	"time"
 
@@ -197,6 +198,31 @@ func ExampleClient_Write_basic() {
 	// system-metrics,hostname=hal9000 cpu=0.93,memory=1000i 1520139967000000009
 }
 
+// ExampleClient_WriteLineProtocol is an example of forwarding line protocol, like a file or a device's upload, without decoding it.
+func ExampleClient_WriteLineProtocol() {
+	myHTTPClient, myHTTPInfluxAddress, teardown := setupMockServer()
+	defer teardown() // we shut down our server at the end of the test, obviously you won't be doing this.
+	influx, err := influxdb.New(myHTTPInfluxAddress, "mytoken", influxdb.WithHTTPClient(myHTTPClient))
+	if err != nil {
+		panic(err) // error handling here, normally we wouldn't use fmt, but it works for the example
+	}
+	defer influx.Close()
+
+	// any io.Reader works, it is streamed to the server rather than read into memory first.
+	upload := strings.NewReader("system-metrics,hostname=hal9000 cpu=0.93,memory=1000i 1520139967000000008\n")
+
+	// WithValidation rejects the whole write if a line is malformed, before the server stores any of it.
+	stats, err := influx.WriteLineProtocol(context.Background(), "my-awesome-bucket", "my-very-awesome-org", upload, influxdb.WithValidation())
+	if err != nil {
+		log.Fatal(err) // as above use your own error handling here.
+	}
+	fmt.Printf("wrote %d lines, %d bytes\n", stats.Lines, stats.Bytes)
+	// Output:
+	// system-metrics,hostname=hal9000 cpu=0.93,memory=1000i 1520139967000000008
+	//
+	// wrote 1 lines, 74 bytes
+}
+
 func ExampleClient_Write_tlsMutualAuthentication() {
 	// just us setting up the server so the example will work.  You will likely have to use the old fasioned way to get an *http.Client and address
 	_, certFileName, keyfileName, myHTTPInfluxAddress, teardown := setupTLSMockserver()
@@ -0,0 +1,284 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const defaultHealthCheckInterval = 10 * time.Second
+
+// FailoverEvent describes the client switching from one endpoint to another.
+type FailoverEvent struct {
+	// From and To are the addresses of the endpoints, like "http://host:port".
+	From string
+	To   string
+	// Err is why From was considered unhealthy. It is nil when the client fails back to an endpoint that recovered.
+	Err error
+}
+
+// failover tracks the health of several endpoints serving the same data,
+// and picks the one requests are sent to.
+type failover struct {
+	interval   time.Duration
+	onFailover func(FailoverEvent)
+
+	mu        sync.Mutex
+	endpoints []*url.URL
+	healthy   []bool
+	current   int
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// failoverConfig returns the client's failover config, creating it if necessary.
+func (c *Client) failoverConfig() *failover {
+	if c.failover == nil {
+		c.failover = &failover{interval: defaultHealthCheckInterval}
+	}
+	return c.failover
+}
+
+// WithEndpoints returns an option for adding standby endpoints to the client.
+// The address passed to New is the first endpoint, followed by these in order.
+// Requests go to the first healthy endpoint. When a request fails with a connection error or a 5xx status,
+// the endpoint is marked unhealthy and the request is retried on the next one.
+// Request bodies are buffered in memory so they can be replayed.
+// Endpoints are health checked in the background through /ready, and the client fails back
+// to an earlier endpoint once it recovers.
+func WithEndpoints(endpoints ...string) Option {
+	return Option{
+		name: "WithEndpoints",
+		f: func(c *Client) error {
+			f := c.failoverConfig()
+			for _, e := range endpoints {
+				u, err := url.Parse(e)
+				if err != nil {
+					return fmt.Errorf("Error: could not parse url: %v", err)
+				}
+				if u.Scheme == "unix" {
+					return errors.New("WithEndpoints doesn't support unix socket addresses")
+				}
+				f.endpoints = append(f.endpoints, u)
+			}
+			return nil
+		},
+	}
+}
+
+// WithHealthCheckInterval returns an option for setting how often endpoints are health checked when using WithEndpoints.
+// The default is 10 seconds.
+func WithHealthCheckInterval(d time.Duration) Option {
+	return Option{
+		name: "WithHealthCheckInterval",
+		f: func(c *Client) error {
+			c.failoverConfig().interval = d
+			return nil
+		},
+	}
+}
+
+// WithFailoverHandler returns an option for being notified when the client switches endpoints.
+// fn is called synchronously, so it should return quickly.
+func WithFailoverHandler(fn func(FailoverEvent)) Option {
+	return Option{
+		name: "WithFailoverHandler",
+		f: func(c *Client) error {
+			c.failoverConfig().onFailover = fn
+			return nil
+		},
+	}
+}
+
+// Endpoint returns the address of the endpoint requests are currently sent to, like "http://host:port",
+// or "unix:///path/to/socket".
+func (c *Client) Endpoint() string {
+	if c.socketPath != "" {
+		return (&url.URL{Scheme: "unix", Path: c.socketPath}).String()
+	}
+	if c.failover == nil || len(c.failover.endpoints) == 0 {
+		return endpointString(c.url)
+	}
+	c.failover.mu.Lock()
+	defer c.failover.mu.Unlock()
+	return endpointString(c.failover.endpoints[c.failover.current])
+}
+
+func endpointString(u *url.URL) string {
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+}
+
+// start prepends the primary endpoint and starts health checking.
+func (f *failover) start(c *Client) {
+	f.endpoints = append([]*url.URL{c.url}, f.endpoints...)
+	f.healthy = make([]bool, len(f.endpoints))
+	for i := range f.healthy {
+		f.healthy[i] = true
+	}
+	if len(f.endpoints) < 2 || f.interval <= 0 {
+		return
+	}
+	f.stop = make(chan struct{})
+	f.stopped = make(chan struct{})
+	go f.checkHealth(c)
+}
+
+// close stops health checking.
+func (f *failover) close() {
+	if f.stop == nil {
+		return
+	}
+	close(f.stop)
+	<-f.stopped
+	f.stop = nil
+}
+
+func (f *failover) checkHealth(c *Client) {
+	defer close(f.stopped)
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for i, u := range f.endpoints {
+				err := c.checkEndpoint(u, f.interval)
+				f.setHealth(i, err)
+			}
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// checkEndpoint checks whether the endpoint at u is ready.
+func (c *Client) checkEndpoint(u *url.URL, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, endpointString(u)+"/ready", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send("healthcheck", req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if !isReady(resp.StatusCode) {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// setHealth records the health of endpoint i, and switches to the first healthy endpoint.
+func (f *failover) setHealth(i int, err error) {
+	f.mu.Lock()
+	f.healthy[i] = err == nil
+	from := f.current
+	to := from
+	if !f.healthy[from] {
+		// if nothing is healthy, try the next one along
+		to = (from + 1) % len(f.endpoints)
+	}
+	for j, ok := range f.healthy {
+		if ok {
+			to = j
+			break
+		}
+	}
+	f.current = to
+	f.mu.Unlock()
+
+	if from != to && f.onFailover != nil {
+		e := FailoverEvent{From: endpointString(f.endpoints[from]), To: endpointString(f.endpoints[to])}
+		if from == i {
+			e.Err = err
+		}
+		f.onFailover(e)
+	}
+}
+
+// roundTrip sends the request to the current endpoint, failing over to the others
+// on connection errors and 5xx responses.
+func (f *failover) roundTrip(c *Client, op string, req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// buffer streamed bodies, so they can be replayed on another endpoint
+		data, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.ContentLength = int64(len(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+		req.Body, _ = req.GetBody()
+	}
+
+	// requests already being retried, after reauthorizing, count on from their attempt
+	first := 1
+	if a, ok := req.Context().Value(attemptKey{}).(int); ok {
+		first = a
+	}
+	for attempt := 1; ; attempt++ {
+		f.mu.Lock()
+		i := f.current
+		u := f.endpoints[i]
+		f.mu.Unlock()
+
+		ctx := req.Context()
+		if attempt > 1 {
+			ctx = withAttempt(ctx, first+attempt-1)
+		}
+		r := req.Clone(ctx)
+		r.URL.Scheme = u.Scheme
+		r.URL.Host = u.Host
+		r.Host = ""
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+
+		resp, err := c.send(op, r)
+		ferr := failoverError(req.Context(), resp, err)
+		if ferr == nil {
+			return resp, err
+		}
+		f.setHealth(i, ferr)
+
+		if attempt >= len(f.endpoints) {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+	}
+}
+
+// failoverError returns why a request should be retried on another endpoint, or nil if it shouldn't.
+func failoverError(ctx context.Context, resp *http.Response, err error) error {
+	if err != nil {
+		if ctx.Err() != nil {
+			// the caller gave up, the endpoint is not at fault.
+			return nil
+		}
+		return err
+	}
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package influxdb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithEndpoints(t *testing.T) {
+	var primaryDown int32 = 1
+	var primaryWrites, standbyWrites int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&primaryDown) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/ready":
+			// like Ping, health checks take 204 as ready too
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "/api/v2/write":
+			atomic.AddInt32(&primaryWrites, 1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer primary.Close()
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/write" {
+			atomic.AddInt32(&standbyWrites, 1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer standby.Close()
+
+	var (
+		mu       sync.Mutex
+		events   []FailoverEvent
+		attempts []int
+	)
+	c, err := New(primary.URL, "foo",
+		WithInstrumentation(InstrumentationFunc(func(e RequestEvent) {
+			if e.Op == "Write" {
+				mu.Lock()
+				attempts = append(attempts, e.Attempt)
+				mu.Unlock()
+			}
+		})),
+		WithEndpoints(standby.URL),
+		WithHealthCheckInterval(20*time.Millisecond),
+		WithFailoverHandler(func(e FailoverEvent) {
+			mu.Lock()
+			events = append(events, e)
+			mu.Unlock()
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if got := c.Endpoint(); got != primary.URL {
+		t.Fatalf("expected endpoint %s, got %s", primary.URL, got)
+	}
+
+	// the write fails on the primary, and is retried on the standby
+	if _, err := c.Write(context.Background(), "bucket", "org", createTestRowMetrics(t, 1)...); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Endpoint(); got != standby.URL {
+		t.Fatalf("expected endpoint %s, got %s", standby.URL, got)
+	}
+	if atomic.LoadInt32(&standbyWrites) != 1 {
+		t.Fatal("expected the write to reach the standby")
+	}
+	mu.Lock()
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Errorf("expected the write to be attempted on the standby second, got attempts %v", attempts)
+	}
+	mu.Unlock()
+
+	// once the primary recovers, the health check fails back to it
+	atomic.StoreInt32(&primaryDown, 0)
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Endpoint() != primary.URL {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the client to fail back to the primary")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := c.Write(context.Background(), "bucket", "org", createTestRowMetrics(t, 1)...); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&primaryWrites) != 1 {
+		t.Fatal("expected the write to reach the primary")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 failover events, got %v", events)
+	}
+	if events[0].From != primary.URL || events[0].To != standby.URL || events[0].Err == nil {
+		t.Errorf("unexpected failover event %+v", events[0])
+	}
+	if events[1].From != standby.URL || events[1].To != primary.URL || events[1].Err != nil {
+		t.Errorf("unexpected failback event %+v", events[1])
+	}
+}
+
+func TestWithEndpoints_connectionError(t *testing.T) {
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer standby.Close()
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	primaryURL := primary.URL
+	primary.Close()
+
+	c, err := New(primaryURL, "foo", WithEndpoints(standby.URL), WithHealthCheckInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Endpoint(); got != standby.URL {
+		t.Fatalf("expected endpoint %s, got %s", standby.URL, got)
+	}
+}
@@ -2,29 +2,23 @@ package influxdb
 
 import (
 	"context"
-	"encoding/json"
-	"log"
 	"net/http"
-	"net/url"
 )
 
+// GetHealth returns the health of the server.
+// An unhealthy server responds with 503 Service Unavailable, which is returned as a *Health rather than an error.
 func (c *Client) GetHealth(ctx context.Context) (*Health, error) {
-	log.Printf("[DEBUG] Getting health of resource ")
-	pingUrl, _ := url.Parse(c.url.String())
-	pingUrl.Path = "/health"
+	c.log(LevelDebug, "getting health", "op", "GetHealth")
 
-	req, err := http.NewRequest(http.MethodGet, pingUrl.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	req = req.WithContext(ctx)
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
 	health := &Health{}
-
-	if err := json.NewDecoder(resp.Body).Decode(health); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetHealth",
+		method: http.MethodGet,
+		path:   "/health",
+		status: []int{http.StatusOK, http.StatusServiceUnavailable},
+		noAuth: true,
+	}, health)
+	if err != nil {
 		return nil, err
 	}
 	return health, nil
@@ -35,4 +29,6 @@ type Health struct {
 	Message string     `json:"message"`
 	Checks  []struct{} `json:"checks"`
 	Status  string     `json:"status"`
+	Version string     `json:"version"`
+	Commit  string     `json:"commit"`
 }
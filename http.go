@@ -1,38 +1,54 @@
 package influxdb
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"net/http"
 	"time"
 )
 
-func newTransport() *http.Transport {
-	return &http.Transport{
-		Dial: (&net.Dialer{
-			Timeout: 5 * time.Second,
-		}).Dial,
+// defaultTimeout is the timeout of the default http client.
+const defaultTimeout = 20 * time.Second
+
+// newTransport returns a transport with sane timeouts.
+// If socketPath is set, every connection is made to that unix domain socket instead.
+func newTransport(socketPath string) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+	}
+	t := &http.Transport{
+		Dial:                dialer.Dial,
 		TLSHandshakeTimeout: 5 * time.Second,
 	}
+	if socketPath != "" {
+		t.Dial = nil
+		t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+	}
+	return t
 }
 
-func defaultHTTPClient() *http.Client {
+func defaultHTTPClient(socketPath string) *http.Client {
 	return &http.Client{
-		Timeout:   time.Second * 20,
-		Transport: newTransport(),
+		Timeout:   defaultTimeout,
+		Transport: newTransport(socketPath),
 	}
 }
 
+// HTTPClientWithUnixSocket returns an *http.Client with sane timeouts that connects to the unix domain socket at socketPath.
+// New uses a client like this by default when given a "unix://" address.
+func HTTPClientWithUnixSocket(socketPath string) *http.Client {
+	return defaultHTTPClient(socketPath)
+}
+
 // HTTPClientWithTLSConfig returns an *http.Client with sane timeouts and the provided TLSClientConfig.
 func HTTPClientWithTLSConfig(conf *tls.Config) *http.Client {
+	transport := newTransport("")
+	transport.TLSClientConfig = conf
 	return &http.Client{
-		Timeout: time.Second * 20,
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout: 5 * time.Second,
-			}).Dial,
-			TLSHandshakeTimeout: 5 * time.Second,
-			TLSClientConfig:     conf,
-		},
+		Timeout:   defaultTimeout,
+		Transport: transport,
 	}
 }
@@ -0,0 +1,547 @@
+package influxdbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type links map[string]string
+
+type org struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type user struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	OauthID string `json:"oauthID,omitempty"`
+	Status  string `json:"status"`
+	Links   links  `json:"links"`
+
+	password string
+}
+
+type permission struct {
+	Action   string `json:"action"`
+	Resource struct {
+		Type  string `json:"type"`
+		ID    string `json:"id,omitempty"`
+		Name  string `json:"name,omitempty"`
+		OrgID string `json:"orgID,omitempty"`
+		Org   string `json:"org,omitempty"`
+	} `json:"resource"`
+}
+
+type authorization struct {
+	ID          string       `json:"id"`
+	Token       string       `json:"token"`
+	Status      string       `json:"status"`
+	Description string       `json:"description"`
+	OrgID       string       `json:"orgID"`
+	Org         string       `json:"org"`
+	UserID      string       `json:"userID"`
+	User        string       `json:"user"`
+	Permissions []permission `json:"permissions"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
+	Links       links        `json:"links"`
+}
+
+// SetupResult holds the ids and token created by Setup.
+type SetupResult struct {
+	UserID   string
+	OrgID    string
+	BucketID string
+	Token    string
+}
+
+// Setup sets the server up like the /api/v2/setup endpoint does, creating a user with a password, an org,
+// a bucket with infinite retention and an all-access token. It panics if the server is already set up.
+func (s *Server) Setup(username, password, orgName, bucketName string) SetupResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.onboarded {
+		panic("influxdbtest: the server is already set up")
+	}
+	u, o, b, a := s.setup(username, password, orgName, bucketName, 0, "")
+	return SetupResult{UserID: u.ID, OrgID: o.ID, BucketID: b.ID, Token: a.Token}
+}
+
+// setup creates the initial user, org, bucket and authorization. s.mu must be held.
+func (s *Server) setup(username, password, orgName, bucketName string, retentionSeconds int, token string) (*user, *org, *bucket, *authorization) {
+	s.onboarded = true
+	u := s.createUser(username, "", "active")
+	u.password = password
+	o := &org{ID: s.newID(), Name: orgName}
+	s.orgs[o.ID] = o
+	b := s.createBucket(o.ID, bucketName, "", []retentionRule{{Type: "expire", EverySeconds: retentionSeconds}})
+
+	var all []permission
+	for _, typ := range []string{"authorizations", "buckets", "orgs", "users", "write", "query"} {
+		for _, action := range []string{"read", "write"} {
+			var p permission
+			p.Action = action
+			p.Resource.Type = typ
+			all = append(all, p)
+		}
+	}
+	a := s.createAuthorization(o, u, fmt.Sprintf("%s's Token", username), "active", all)
+	if token != "" {
+		a.Token = token
+	}
+	return u, o, b, a
+}
+
+func (s *Server) serveSetup(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]bool{"allowed": !s.onboarded})
+	case http.MethodPost:
+		var req struct {
+			Username               string `json:"username"`
+			Password               string `json:"password"`
+			Org                    string `json:"org"`
+			Bucket                 string `json:"bucket"`
+			RetentionPeriodHrs     int    `json:"retentionPeriodHrs"`
+			RetentionPeriodSeconds int    `json:"retentionPeriodSeconds"`
+			Token                  string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid", err.Error())
+			return
+		}
+		if s.onboarded {
+			writeError(w, http.StatusUnprocessableEntity, "conflict", "onboarding has already been completed")
+			return
+		}
+		if req.Username == "" || req.Org == "" || req.Bucket == "" {
+			writeError(w, http.StatusUnprocessableEntity, "unprocessable entity", "username, org and bucket are required")
+			return
+		}
+		retention := req.RetentionPeriodSeconds
+		if retention == 0 {
+			retention = req.RetentionPeriodHrs * 3600
+		}
+		u, o, b, a := s.setup(req.Username, req.Password, req.Org, req.Bucket, retention, req.Token)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"user":   u,
+			"org":    o,
+			"bucket": b,
+			"auth":   a,
+		})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "method not allowed")
+	}
+}
+
+func (s *Server) serveSignin(w http.ResponseWriter, r *http.Request) {
+	name, password, ok := r.BasicAuth()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ok {
+		for _, u := range s.users {
+			if u.Name == name && u.password == password && u.password != "" {
+				session := s.newID()
+				s.sessions[session] = u.ID
+				http.SetCookie(w, &http.Cookie{Name: "session", Value: session, Path: "/api/"})
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+	}
+	writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized access")
+}
+
+func (s *Server) serveSignout(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cookie, err := r.Cookie("session")
+	if err != nil || s.sessions[cookie.Value] == "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized access")
+		return
+	}
+	delete(s.sessions, cookie.Value)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) createBucket(orgID, name, description string, rules []retentionRule) *bucket {
+	now := s.now().UTC()
+	b := &bucket{ID: s.newID(), OrgID: orgID, Type: "user", Name: name, Description: description, RetentionRules: rules, CreatedAt: now, UpdatedAt: now}
+	b.Links = links{
+		"self":    "/api/v2/buckets/" + b.ID,
+		"org":     "/api/v2/orgs/" + orgID,
+		"write":   "/api/v2/write?org=" + orgID + "&bucket=" + b.ID,
+		"labels":  "/api/v2/buckets/" + b.ID + "/labels",
+		"members": "/api/v2/buckets/" + b.ID + "/members",
+		"owners":  "/api/v2/buckets/" + b.ID + "/owners",
+		"logs":    "/api/v2/buckets/" + b.ID + "/logs",
+	}
+	if b.RetentionRules == nil {
+		b.RetentionRules = []retentionRule{}
+	}
+	s.buckets[b.ID] = b
+	return b
+}
+
+func (s *Server) serveBuckets(w http.ResponseWriter, r *http.Request, elems []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(elems) == 0 || elems[0] == "" {
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query()
+			var orgID string
+			if q.Get("org") != "" || q.Get("orgID") != "" {
+				o := s.findOrg(q.Get("org"), q.Get("orgID"))
+				if o == nil {
+					writeError(w, http.StatusNotFound, "not found", "organization not found")
+					return
+				}
+				orgID = o.ID
+			}
+			var list []interface{}
+			for _, id := range sortedKeys(s.buckets) {
+				b := s.buckets[id]
+				if (orgID == "" || b.OrgID == orgID) && (q.Get("name") == "" || b.Name == q.Get("name")) {
+					list = append(list, b)
+				}
+			}
+			writePage(w, r, "buckets", list)
+		case http.MethodPost:
+			var req struct {
+				OrgID          string          `json:"orgID"`
+				Name           string          `json:"name"`
+				Description    string          `json:"description"`
+				RetentionRules []retentionRule `json:"retentionRules"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid", err.Error())
+				return
+			}
+			if s.orgs[req.OrgID] == nil {
+				writeError(w, http.StatusNotFound, "not found", "organization not found")
+				return
+			}
+			if s.findBucket(req.OrgID, "", req.Name) != nil {
+				writeError(w, http.StatusUnprocessableEntity, "conflict", fmt.Sprintf("bucket with name %s already exists", req.Name))
+				return
+			}
+			writeJSON(w, http.StatusCreated, s.createBucket(req.OrgID, req.Name, req.Description, req.RetentionRules))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed", "method not allowed")
+		}
+		return
+	}
+
+	b, ok := s.buckets[elems[0]]
+	if !ok || len(elems) > 1 {
+		writeError(w, http.StatusNotFound, "not found", "bucket not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, b)
+	case http.MethodPatch:
+		var req struct {
+			Name           *string          `json:"name"`
+			Description    *string          `json:"description"`
+			RetentionRules *[]retentionRule `json:"retentionRules"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid", err.Error())
+			return
+		}
+		if req.Name != nil {
+			b.Name = *req.Name
+		}
+		if req.Description != nil {
+			b.Description = *req.Description
+		}
+		if req.RetentionRules != nil {
+			b.RetentionRules = *req.RetentionRules
+		}
+		b.UpdatedAt = s.now().UTC()
+		writeJSON(w, http.StatusOK, b)
+	case http.MethodDelete:
+		delete(s.buckets, b.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "method not allowed")
+	}
+}
+
+func (s *Server) createUser(name, oauthID, status string) *user {
+	if status == "" {
+		status = "active"
+	}
+	u := &user{ID: s.newID(), Name: name, OauthID: oauthID, Status: status}
+	u.Links = links{"self": "/api/v2/users/" + u.ID, "logs": "/api/v2/users/" + u.ID + "/logs"}
+	s.users[u.ID] = u
+	return u
+}
+
+func (s *Server) serveUsers(w http.ResponseWriter, r *http.Request, elems []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(elems) == 0 || elems[0] == "" {
+		switch r.Method {
+		case http.MethodGet:
+			var list []interface{}
+			for _, id := range sortedKeys(s.users) {
+				list = append(list, s.users[id])
+			}
+			writePage(w, r, "users", list)
+		case http.MethodPost:
+			var req struct {
+				Name    string `json:"name"`
+				OauthID string `json:"oauthID"`
+				Status  string `json:"status"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid", err.Error())
+				return
+			}
+			for _, u := range s.users {
+				if u.Name == req.Name {
+					writeError(w, http.StatusUnprocessableEntity, "conflict", fmt.Sprintf("user with name %s already exists", req.Name))
+					return
+				}
+			}
+			writeJSON(w, http.StatusCreated, s.createUser(req.Name, req.OauthID, req.Status))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed", "method not allowed")
+		}
+		return
+	}
+
+	u, ok := s.users[elems[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found", "user not found")
+		return
+	}
+	if len(elems) == 2 && elems[1] == "password" && (r.Method == http.MethodPut || r.Method == http.MethodPost) {
+		var req struct {
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid", err.Error())
+			return
+		}
+		u.password = req.Password
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if len(elems) > 1 {
+		writeError(w, http.StatusNotFound, "not found", "path not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, u)
+	case http.MethodPatch:
+		var req struct {
+			Name    *string `json:"name"`
+			OauthID *string `json:"oauthID"`
+			Status  *string `json:"status"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid", err.Error())
+			return
+		}
+		if req.Name != nil && *req.Name != "" {
+			u.Name = *req.Name
+		}
+		if req.OauthID != nil {
+			u.OauthID = *req.OauthID
+		}
+		if req.Status != nil && *req.Status != "" {
+			u.Status = *req.Status
+		}
+		writeJSON(w, http.StatusOK, u)
+	case http.MethodDelete:
+		delete(s.users, u.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "method not allowed")
+	}
+}
+
+func (s *Server) createAuthorization(o *org, u *user, description, status string, permissions []permission) *authorization {
+	if status == "" {
+		status = "active"
+	}
+	now := s.now().UTC()
+	a := &authorization{
+		ID:          s.newID(),
+		Token:       "token-" + s.newID(),
+		Status:      status,
+		Description: description,
+		OrgID:       o.ID,
+		Org:         o.Name,
+		Permissions: permissions,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if u != nil {
+		a.UserID, a.User = u.ID, u.Name
+	}
+	a.Links = links{"self": "/api/v2/authorizations/" + a.ID, "user": "/api/v2/users/" + a.UserID}
+	s.auths[a.ID] = a
+	return a
+}
+
+func (s *Server) serveAuthorizations(w http.ResponseWriter, r *http.Request, elems []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(elems) == 0 || elems[0] == "" {
+		switch r.Method {
+		case http.MethodGet:
+			q := r.URL.Query()
+			var list []interface{}
+			for _, id := range sortedKeys(s.auths) {
+				a := s.auths[id]
+				if (q.Get("org") == "" || a.Org == q.Get("org")) &&
+					(q.Get("orgID") == "" || a.OrgID == q.Get("orgID")) &&
+					(q.Get("user") == "" || a.User == q.Get("user")) &&
+					(q.Get("userID") == "" || a.UserID == q.Get("userID")) {
+					list = append(list, a)
+				}
+			}
+			writePage(w, r, "authorizations", list)
+		case http.MethodPost:
+			var req struct {
+				OrgID       string       `json:"orgID"`
+				UserID      string       `json:"userID"`
+				Description string       `json:"description"`
+				Status      string       `json:"status"`
+				Permissions []permission `json:"permissions"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid", err.Error())
+				return
+			}
+			o := s.orgs[req.OrgID]
+			if o == nil {
+				writeError(w, http.StatusNotFound, "not found", "organization not found")
+				return
+			}
+			if len(req.Permissions) == 0 {
+				writeError(w, http.StatusBadRequest, "invalid", "authorization must have at least one permission")
+				return
+			}
+			userID := req.UserID
+			if userID == "" {
+				userID = s.userID(r)
+			}
+			writeJSON(w, http.StatusCreated, s.createAuthorization(o, s.users[userID], req.Description, req.Status, req.Permissions))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed", "method not allowed")
+		}
+		return
+	}
+
+	a, ok := s.auths[elems[0]]
+	if !ok || len(elems) > 1 {
+		writeError(w, http.StatusNotFound, "not found", "authorization not found")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, a)
+	case http.MethodPatch:
+		var req struct {
+			Description *string `json:"description"`
+			Status      *string `json:"status"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid", err.Error())
+			return
+		}
+		if req.Description != nil {
+			a.Description = *req.Description
+		}
+		if req.Status != nil && *req.Status != "" {
+			a.Status = *req.Status
+		}
+		a.UpdatedAt = s.now().UTC()
+		writeJSON(w, http.StatusOK, a)
+	case http.MethodDelete:
+		delete(s.auths, a.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "method not allowed")
+	}
+}
+
+// writePage writes the page of list selected by the limit and offset parameters of r,
+// with a link to the next page if there is one.
+func writePage(w http.ResponseWriter, r *http.Request, name string, list []interface{}) {
+	q := r.URL.Query()
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	if offset < 0 || offset > len(list) {
+		offset = len(list)
+	}
+	end := offset + limit
+	if end > len(list) {
+		end = len(list)
+	}
+
+	l := links{"self": r.URL.Path + "?" + q.Encode()}
+	if end < len(list) {
+		next := url.Values{}
+		for k, v := range q {
+			next[k] = v
+		}
+		next.Set("limit", strconv.Itoa(limit))
+		next.Set("offset", strconv.Itoa(end))
+		l["next"] = r.URL.Path + "?" + next.Encode()
+	}
+	page := list[offset:end]
+	if page == nil {
+		page = []interface{}{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"links": l, name: page})
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]*bucket:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*user:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*authorization:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
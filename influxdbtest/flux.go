@@ -0,0 +1,694 @@
+package influxdbtest
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// fluxQuery is a parsed query of the supported subset of Flux:
+// from, then range, then any number of filter, limit and yield.
+type fluxQuery struct {
+	bucket      string
+	start, stop time.Time
+	filters     []predicate
+	limit       int // per table, 0 for none
+	result      string
+}
+
+// predicate is a filter function applied to a row.
+type predicate func(row map[string]interface{}) bool
+
+// parseFlux parses a query, resolving relative times against now.
+func parseFlux(query string, now time.Time) (*fluxQuery, error) {
+	toks, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &fluxParser{toks: toks, now: now}
+	q := &fluxQuery{result: "_result", stop: now}
+	hasRange := false
+
+	for i := 0; ; i++ {
+		if i > 0 {
+			if p.peek().text == "" {
+				break
+			}
+			if err := p.expect("|>"); err != nil {
+				return nil, err
+			}
+		}
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, fmt.Errorf("expected a function call, got %q", name.text)
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		switch {
+		case i == 0 && name.text != "from":
+			return nil, errors.New("queries must start with from()")
+		case name.text == "from":
+			if i != 0 {
+				return nil, errors.New("from() must come first")
+			}
+			args, err := p.args()
+			if err != nil {
+				return nil, err
+			}
+			bucket, ok := args["bucket"].(string)
+			if !ok {
+				if bucket, ok = args["bucketID"].(string); !ok {
+					return nil, errors.New("from() requires a bucket")
+				}
+			}
+			q.bucket = bucket
+		case name.text == "range":
+			args, err := p.args()
+			if err != nil {
+				return nil, err
+			}
+			start, ok := args["start"].(time.Time)
+			if !ok {
+				return nil, errors.New("range() requires a start time or duration")
+			}
+			q.start = start
+			if stop, ok := args["stop"].(time.Time); ok {
+				q.stop = stop
+			} else if args["stop"] != nil {
+				return nil, errors.New("range() requires the stop to be a time or duration")
+			}
+			hasRange = true
+		case name.text == "filter":
+			if err := p.expectAll("fn", ":", "(", "r", ")", "=>"); err != nil {
+				return nil, err
+			}
+			f, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			q.filters = append(q.filters, f)
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+		case name.text == "limit":
+			args, err := p.args()
+			if err != nil {
+				return nil, err
+			}
+			n, ok := args["n"].(int64)
+			if !ok || n < 0 {
+				return nil, errors.New("limit() requires a positive n")
+			}
+			q.limit = int(n)
+		case name.text == "yield":
+			args, err := p.args()
+			if err != nil {
+				return nil, err
+			}
+			if name, ok := args["name"].(string); ok {
+				q.result = name
+			}
+		default:
+			return nil, fmt.Errorf("unsupported function %s()", name.text)
+		}
+		if i == 0 {
+			continue
+		}
+		if i == 1 && !hasRange {
+			return nil, errors.New("from() must be followed by range()")
+		}
+	}
+	if !hasRange {
+		return nil, errors.New("cannot submit unbounded read, from() must be followed by range()")
+	}
+	return q, nil
+}
+
+// fluxTable is a table of a query's result: the rows of a single field of a single series.
+type fluxTable struct {
+	key   string
+	point Point // the measurement and tags
+	field string
+	times []time.Time
+	vals  []interface{}
+}
+
+type fluxResult struct {
+	q      *fluxQuery
+	tables []*fluxTable
+}
+
+// run runs the query against points.
+func (q *fluxQuery) run(points []Point) *fluxResult {
+	tables := map[string]*fluxTable{}
+	for _, p := range points {
+		if p.Time.Before(q.start) || !p.Time.Before(q.stop) {
+			continue
+		}
+		for field, value := range p.Fields {
+			row := map[string]interface{}{
+				"_measurement": p.Measurement,
+				"_field":       field,
+				"_value":       value,
+				"_time":        p.Time,
+				"_start":       q.start,
+				"_stop":        q.stop,
+			}
+			for k, v := range p.Tags {
+				row[k] = v
+			}
+			if !q.match(row) {
+				continue
+			}
+			key := p.seriesKey() + " " + field + " " + fmt.Sprintf("%T", value)
+			t, ok := tables[key]
+			if !ok {
+				t = &fluxTable{key: key, point: p, field: field}
+				tables[key] = t
+			}
+			t.times = append(t.times, p.Time)
+			t.vals = append(t.vals, value)
+		}
+	}
+
+	r := &fluxResult{q: q}
+	for _, t := range tables {
+		sort.Sort(byTime{t})
+		if q.limit > 0 && len(t.times) > q.limit {
+			t.times, t.vals = t.times[:q.limit], t.vals[:q.limit]
+		}
+		r.tables = append(r.tables, t)
+	}
+	sort.Slice(r.tables, func(i, j int) bool { return r.tables[i].key < r.tables[j].key })
+	return r
+}
+
+func (q *fluxQuery) match(row map[string]interface{}) bool {
+	for _, f := range q.filters {
+		if !f(row) {
+			return false
+		}
+	}
+	return true
+}
+
+type byTime struct{ t *fluxTable }
+
+func (b byTime) Len() int           { return len(b.t.times) }
+func (b byTime) Less(i, j int) bool { return b.t.times[i].Before(b.t.times[j]) }
+func (b byTime) Swap(i, j int) {
+	b.t.times[i], b.t.times[j] = b.t.times[j], b.t.times[i]
+	b.t.vals[i], b.t.vals[j] = b.t.vals[j], b.t.vals[i]
+}
+
+// writeCSV writes the result as annotated CSV, with the annotations repeated for every table.
+func (r *fluxResult) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	for n, t := range r.tables {
+		tagKeys := make([]string, 0, len(t.point.Tags))
+		for k := range t.point.Tags {
+			tagKeys = append(tagKeys, k)
+		}
+		sort.Strings(tagKeys)
+
+		datatypes := []string{"#datatype", "string", "long", "dateTime:RFC3339", "dateTime:RFC3339", "dateTime:RFC3339", datatype(t.vals[0]), "string", "string"}
+		group := []string{"#group", "false", "false", "true", "true", "false", "false", "true", "true"}
+		defaults := []string{"#default", r.q.result, "", "", "", "", "", "", ""}
+		header := []string{"", "result", "table", "_start", "_stop", "_time", "_value", "_field", "_measurement"}
+		for _, k := range tagKeys {
+			datatypes = append(datatypes, "string")
+			group = append(group, "true")
+			defaults = append(defaults, "")
+			header = append(header, k)
+		}
+		if n > 0 {
+			cw.Write(nil)
+		}
+		for _, row := range [][]string{datatypes, group, defaults, header} {
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+
+		for i := range t.times {
+			row := []string{"", "", strconv.Itoa(n),
+				r.q.start.UTC().Format(time.RFC3339Nano),
+				r.q.stop.UTC().Format(time.RFC3339Nano),
+				t.times[i].UTC().Format(time.RFC3339Nano),
+				formatValue(t.vals[i]),
+				t.field,
+				t.point.Measurement,
+			}
+			for _, k := range tagKeys {
+				row = append(row, t.point.Tags[k])
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func datatype(v interface{}) string {
+	switch v.(type) {
+	case float64:
+		return "double"
+	case int64:
+		return "long"
+	case uint64:
+		return "unsignedLong"
+	case bool:
+		return "boolean"
+	}
+	return "string"
+}
+
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(v)
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber // a number, duration or time literal
+	tokPunct
+)
+
+type token struct {
+	kind tokKind
+	text string
+}
+
+// tokenize splits a query into tokens, dropping comments.
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.HasPrefix(s[i:], "//"):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, errors.New("unterminated string")
+			}
+			toks = append(toks, token{tokString, b.String()})
+			i = j + 1
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, s[i:j]})
+			i = j
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || strings.IndexByte(".:-+", s[j]) >= 0) {
+				j++
+			}
+			toks = append(toks, token{tokNumber, s[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"|>", "=>", "==", "!=", "<=", ">=", "<", ">", "(", ")", "[", "]", ",", ":", ".", "-"} {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q", c)
+			}
+			toks = append(toks, token{tokPunct, op})
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+type fluxParser struct {
+	toks []token
+	now  time.Time
+}
+
+func (p *fluxParser) peek() token {
+	if len(p.toks) == 0 {
+		return token{}
+	}
+	return p.toks[0]
+}
+
+func (p *fluxParser) next() token {
+	t := p.peek()
+	if len(p.toks) > 0 {
+		p.toks = p.toks[1:]
+	}
+	return t
+}
+
+func (p *fluxParser) expect(text string) error {
+	if t := p.next(); t.text != text {
+		return fmt.Errorf("expected %q, got %q", text, t.text)
+	}
+	return nil
+}
+
+func (p *fluxParser) expectAll(texts ...string) error {
+	for _, text := range texts {
+		if err := p.expect(text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// args parses named arguments up to the closing parenthesis.
+func (p *fluxParser) args() (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for p.peek().text != ")" {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		name := p.next()
+		if name.kind != tokIdent {
+			return nil, fmt.Errorf("expected an argument name, got %q", name.text)
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		args[name.text] = v
+	}
+	p.next()
+	return args, nil
+}
+
+// literal parses a string, number, boolean, duration (as a time relative to now), time or now().
+func (p *fluxParser) literal() (interface{}, error) {
+	t := p.next()
+	neg := false
+	if t.text == "-" {
+		neg, t = true, p.next()
+		if t.kind != tokNumber {
+			return nil, fmt.Errorf("unexpected %q after -", t.text)
+		}
+	}
+	switch t.kind {
+	case tokString:
+		return t.text, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "now":
+			if err := p.expectAll("(", ")"); err != nil {
+				return nil, err
+			}
+			return p.now, nil
+		}
+		return nil, fmt.Errorf("unsupported identifier %q", t.text)
+	case tokNumber:
+		if tm, err := time.Parse(time.RFC3339Nano, t.text); err == nil && !neg {
+			return tm, nil
+		}
+		if d, err := parseDuration(t.text); err == nil {
+			if neg {
+				d = -d
+			}
+			return p.now.Add(d), nil
+		}
+		if n, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			if neg {
+				n = -n
+			}
+			return n, nil
+		}
+		if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+			if neg {
+				f = -f
+			}
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// parseDuration parses Flux durations like 1h30m, 5d and 2w.
+func parseDuration(s string) (time.Duration, error) {
+	units := []struct {
+		suffix string
+		d      time.Duration
+	}{
+		{"ns", time.Nanosecond}, {"us", time.Microsecond}, {"µs", time.Microsecond}, {"ms", time.Millisecond},
+		{"s", time.Second}, {"m", time.Minute}, {"h", time.Hour}, {"d", 24 * time.Hour}, {"w", 7 * 24 * time.Hour},
+	}
+	var total time.Duration
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		n, _ := strconv.ParseInt(s[:i], 10, 64)
+		s = s[i:]
+		if strings.HasPrefix(s, "mo") || strings.HasPrefix(s, "y") {
+			return 0, errors.New("calendar durations aren't supported")
+		}
+		found := false
+		for _, u := range units {
+			// units sharing a prefix are ordered longest first
+			if strings.HasPrefix(s, u.suffix) {
+				total += time.Duration(n) * u.d
+				s = s[len(u.suffix):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid duration unit in %q", s)
+		}
+	}
+	return total, nil
+}
+
+// or parses predicates joined with "or".
+func (p *fluxParser) or() (predicate, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().text == "or" {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row map[string]interface{}) bool { return l(row) || right(row) }
+	}
+	return left, nil
+}
+
+// and parses predicates joined with "and".
+func (p *fluxParser) and() (predicate, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().text == "and" {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row map[string]interface{}) bool { return l(row) && right(row) }
+	}
+	return left, nil
+}
+
+func (p *fluxParser) unary() (predicate, error) {
+	switch p.peek().text {
+	case "not":
+		p.next()
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(row map[string]interface{}) bool { return !f(row) }, nil
+	case "(":
+		p.next()
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return f, p.expect(")")
+	}
+	return p.comparison()
+}
+
+// comparison parses a comparison of a column of r with a literal, like r._measurement == "cpu" or r["host"] != "a".
+func (p *fluxParser) comparison() (predicate, error) {
+	column, err := p.column()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	switch op.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", op.text)
+	}
+	want, err := p.literal()
+	if err != nil {
+		return nil, err
+	}
+	return func(row map[string]interface{}) bool {
+		c, ok := compare(row[column], want)
+		if !ok {
+			// comparisons with null, or values of different types, are false
+			return false
+		}
+		switch op.text {
+		case "==":
+			return c == 0
+		case "!=":
+			return c != 0
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		}
+		return c >= 0
+	}, nil
+}
+
+func (p *fluxParser) column() (string, error) {
+	if err := p.expect("r"); err != nil {
+		return "", err
+	}
+	switch t := p.next(); t.text {
+	case ".":
+		name := p.next()
+		if name.kind != tokIdent {
+			return "", fmt.Errorf("expected a column name, got %q", name.text)
+		}
+		return name.text, nil
+	case "[":
+		name := p.next()
+		if name.kind != tokString {
+			return "", fmt.Errorf("expected a column name, got %q", name.text)
+		}
+		return name.text, p.expect("]")
+	default:
+		return "", fmt.Errorf("expected a column of r, got %q", t.text)
+	}
+}
+
+// compare compares two values, and reports whether they are comparable.
+func compare(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	switch a := a.(type) {
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	case bool:
+		b, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a == b:
+			return 0, true
+		case !a:
+			return -1, true
+		}
+		return 1, true
+	case time.Time:
+		b, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a.Before(b):
+			return -1, true
+		case a.After(b):
+			return 1, true
+		}
+		return 0, true
+	}
+	x, ok := toFloat(a)
+	y, ok2 := toFloat(b)
+	if !ok || !ok2 || math.IsNaN(x) || math.IsNaN(y) {
+		return 0, false
+	}
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	}
+	return 0, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,162 @@
+package influxdbtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseError is an error in a line of line protocol.
+type parseError struct {
+	line int // 1-based
+	msg  string
+}
+
+func (e *parseError) Error() string {
+	return fmt.Sprintf("unable to parse line %d: %s", e.line, e.msg)
+}
+
+// parseLineProtocol parses points, with timestamps in units of precision.
+// Points without a timestamp get now, truncated to precision.
+func parseLineProtocol(data []byte, precision time.Duration, now time.Time) ([]Point, error) {
+	var points []Point
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		p, err := parseLine(string(line), precision, now)
+		if err != nil {
+			return nil, &parseError{line: i + 1, msg: err.Error()}
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// parseLine parses a line like `cpu,host=a usage=0.5,count=3i 1556813561098000000`.
+func parseLine(line string, precision time.Duration, now time.Time) (Point, error) {
+	p := Point{Tags: map[string]string{}, Fields: map[string]interface{}{}}
+
+	key, rest := splitUnescaped(line, ' ', false)
+	fields, timestamp := splitUnescaped(rest, ' ', true)
+
+	parts := splitAllUnescaped(key, ',', false)
+	p.Measurement = unescape(parts[0])
+	if p.Measurement == "" {
+		return p, errors.New("missing measurement")
+	}
+	for _, tag := range parts[1:] {
+		k, v := splitUnescaped(tag, '=', false)
+		if k == "" || v == "" {
+			return p, fmt.Errorf("invalid tag %q", tag)
+		}
+		p.Tags[unescape(k)] = unescape(v)
+	}
+
+	if fields == "" {
+		return p, errors.New("missing fields")
+	}
+	for _, field := range splitAllUnescaped(fields, ',', true) {
+		k, v := splitUnescaped(field, '=', true)
+		if k == "" || v == "" {
+			return p, fmt.Errorf("invalid field %q", field)
+		}
+		value, err := parseFieldValue(v)
+		if err != nil {
+			return p, fmt.Errorf("invalid field %q: %v", unescape(k), err)
+		}
+		p.Fields[unescape(k)] = value
+	}
+
+	if timestamp = strings.TrimSpace(timestamp); timestamp == "" {
+		p.Time = now.Truncate(precision)
+		return p, nil
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return p, fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	p.Time = time.Unix(0, ts*int64(precision)).UTC()
+	return p, nil
+}
+
+func parseFieldValue(v string) (interface{}, error) {
+	switch {
+	case v[0] == '"':
+		if len(v) < 2 || v[len(v)-1] != '"' {
+			return nil, errors.New("unterminated string")
+		}
+		s := v[1 : len(v)-1]
+		s = strings.Replace(s, `\"`, `"`, -1)
+		s = strings.Replace(s, `\\`, `\`, -1)
+		return s, nil
+	case strings.HasSuffix(v, "i"):
+		return strconv.ParseInt(v[:len(v)-1], 10, 64)
+	case strings.HasSuffix(v, "u"):
+		return strconv.ParseUint(v[:len(v)-1], 10, 64)
+	}
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// splitUnescaped splits s at the first sep which isn't escaped with a backslash, or inside a quoted string if quotes is set.
+func splitUnescaped(s string, sep byte, quotes bool) (string, string) {
+	if i := indexUnescaped(s, sep, quotes); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+func splitAllUnescaped(s string, sep byte, quotes bool) []string {
+	var parts []string
+	for {
+		i := indexUnescaped(s, sep, quotes)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+1:]
+	}
+}
+
+func indexUnescaped(s string, sep byte, quotes bool) int {
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			inString = !inString
+		case !inString && s[i] == sep:
+			return i
+		}
+	}
+	return -1
+}
+
+// unescape removes the backslashes escaping commas, equals signs and spaces.
+func unescape(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case ',', '=', ' ', '\\':
+				i++
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
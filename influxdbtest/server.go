@@ -0,0 +1,574 @@
+// Package influxdbtest provides an in-memory InfluxDB server for tests, so they can run without a real one.
+//
+// The server speaks enough of the InfluxDB 2 API for the client. It stores line protocol written to /api/v2/write,
+// answers simple Flux queries like
+//
+//	from(bucket: "my-bucket")
+//		|> range(start: -1h)
+//		|> filter(fn: (r) => r._measurement == "cpu" and r.host == "a")
+//
+// as annotated CSV, and implements setup, sign in, buckets, users and authorizations.
+// Failures can be injected to exercise retries. Use it like so:
+//
+//	s := influxdbtest.NewServer()
+//	defer s.Close()
+//	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+//	influx, err := influxdb.New(s.URL, setup.Token)
+//	...
+//	points := s.Points("my-org", "my-bucket")
+//
+// Until the server is set up, every request is allowed. After that, requests need the token of an active
+// authorization or a session cookie, but permissions aren't checked.
+package influxdbtest
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultVersion = "2.0.0"
+
+// Server is an in-memory InfluxDB server.
+type Server struct {
+	// URL is the address of the server, like "http://127.0.0.1:1234", to pass to influxdb.New.
+	URL string
+
+	srv     *httptest.Server
+	version string
+	now     func() time.Time
+
+	mu        sync.Mutex
+	nextID    uint64
+	onboarded bool
+	orgs      map[string]*org
+	buckets   map[string]*bucket
+	users     map[string]*user
+	auths     map[string]*authorization
+	sessions  map[string]string // session cookie value to user id
+	failures  []*Failure
+	requests  map[string]int // per path
+}
+
+// Option is a functional option for NewServer.
+type Option func(*Server)
+
+// WithVersion sets the version the server reports in the X-Influxdb-Version header and /health. It defaults to 2.0.0.
+func WithVersion(version string) Option {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+// WithNow sets the clock the server uses for points written without a timestamp, and for now() and relative times in queries.
+func WithNow(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// NewServer starts and returns a new Server. The caller should call Close when finished, to shut it down.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		version:  defaultVersion,
+		now:      time.Now,
+		orgs:     map[string]*org{},
+		buckets:  map[string]*bucket{},
+		users:    map[string]*user{},
+		auths:    map[string]*authorization{},
+		sessions: map[string]string{},
+		requests: map[string]int{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Failure makes the server fail requests before handling them.
+type Failure struct {
+	// Path limits the failure to requests for the path, like "/api/v2/write". An empty Path matches every request.
+	Path string
+	// StatusCode is the status code of the responses, like http.StatusTooManyRequests.
+	StatusCode int
+	// RetryAfter is sent in the Retry-After header, in seconds, unless it is zero.
+	RetryAfter int
+	// Count is the number of requests to fail, after which the failure is removed. Zero fails requests until ClearFailures.
+	Count int
+}
+
+// RateLimited returns a Failure responding to count requests for path with 429 Too Many Requests and Retry-After.
+func RateLimited(path string, retryAfter, count int) Failure {
+	return Failure{Path: path, StatusCode: http.StatusTooManyRequests, RetryAfter: retryAfter, Count: count}
+}
+
+// Unavailable returns a Failure responding to count requests for path with 503 Service Unavailable.
+func Unavailable(path string, count int) Failure {
+	return Failure{Path: path, StatusCode: http.StatusServiceUnavailable, Count: count}
+}
+
+// TooLarge returns a Failure responding to count requests for path with 413 Request Entity Too Large.
+func TooLarge(path string, count int) Failure {
+	return Failure{Path: path, StatusCode: http.StatusRequestEntityTooLarge, Count: count}
+}
+
+// Fail adds a failure. Failures are matched in the order they were added.
+func (s *Server) Fail(f Failure) {
+	s.mu.Lock()
+	s.failures = append(s.failures, &f)
+	s.mu.Unlock()
+}
+
+// ClearFailures removes all the failures.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	s.failures = nil
+	s.mu.Unlock()
+}
+
+// Requests returns the number of requests the server received for path, like "/api/v2/write", including failed ones.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// Point is a point stored by the server.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	// Fields are float64, int64, uint64, bool or string.
+	Fields map[string]interface{}
+	Time   time.Time
+}
+
+// Points returns the points stored in a bucket in an org, in the order they were first written.
+// It returns nil if there is no such bucket.
+func (s *Server) Points(orgName, bucketName string) []Point {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.findBucket(orgName, "", bucketName)
+	if b == nil {
+		return nil
+	}
+	points := make([]Point, len(b.points))
+	for i, p := range b.points {
+		points[i] = p.copy()
+	}
+	return points
+}
+
+// Write parses line protocol with nanosecond timestamps and stores the points in a bucket in an org, for seeding queries.
+func (s *Server) Write(orgName, bucketName, lineProtocol string) error {
+	points, err := parseLineProtocol([]byte(lineProtocol), time.Nanosecond, s.now())
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.findBucket(orgName, "", bucketName)
+	if b == nil {
+		return fmt.Errorf("bucket %q not found in org %q", bucketName, orgName)
+	}
+	b.write(points)
+	return nil
+}
+
+func (p Point) copy() Point {
+	c := Point{Measurement: p.Measurement, Tags: make(map[string]string, len(p.Tags)), Fields: make(map[string]interface{}, len(p.Fields)), Time: p.Time}
+	for k, v := range p.Tags {
+		c.Tags[k] = v
+	}
+	for k, v := range p.Fields {
+		c.Fields[k] = v
+	}
+	return c
+}
+
+// seriesKey identifies the series of a point.
+func (p Point) seriesKey() string {
+	keys := make([]string, 0, len(p.Tags))
+	for k := range p.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(p.Measurement)
+	for _, k := range keys {
+		b.WriteString("," + k + "=" + p.Tags[k])
+	}
+	return b.String()
+}
+
+type bucket struct {
+	ID             string          `json:"id"`
+	OrgID          string          `json:"orgID"`
+	Type           string          `json:"type"`
+	Name           string          `json:"name"`
+	Description    string          `json:"description"`
+	RetentionRules []retentionRule `json:"retentionRules"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+	Links          links           `json:"links"`
+
+	points []Point
+	index  map[string]int // series key and time to the index in points
+}
+
+type retentionRule struct {
+	Type         string `json:"type"`
+	EverySeconds int    `json:"everySeconds"`
+}
+
+// write stores points, merging the fields of points with the same series and time like InfluxDB does.
+func (b *bucket) write(points []Point) {
+	if b.index == nil {
+		b.index = map[string]int{}
+	}
+	for _, p := range points {
+		key := p.seriesKey() + " " + strconv.FormatInt(p.Time.UnixNano(), 10)
+		if i, ok := b.index[key]; ok {
+			for k, v := range p.Fields {
+				b.points[i].Fields[k] = v
+			}
+			continue
+		}
+		b.index[key] = len(b.points)
+		b.points = append(b.points, p)
+	}
+}
+
+// findBucket returns the bucket with the name or id in the org with the name or id, or nil. s.mu must be held.
+func (s *Server) findBucket(orgNameOrID, orgID, bucketNameOrID string) *bucket {
+	o := s.findOrg(orgNameOrID, orgID)
+	if o == nil {
+		return nil
+	}
+	if b, ok := s.buckets[bucketNameOrID]; ok && b.OrgID == o.ID {
+		return b
+	}
+	for _, b := range s.buckets {
+		if b.OrgID == o.ID && b.Name == bucketNameOrID {
+			return b
+		}
+	}
+	return nil
+}
+
+// findOrg returns the org with the id, or else with the name or id nameOrID, or nil. s.mu must be held.
+func (s *Server) findOrg(nameOrID, id string) *org {
+	if id != "" {
+		return s.orgs[id]
+	}
+	for _, o := range s.orgs {
+		if o.Name == nameOrID {
+			return o
+		}
+	}
+	return s.orgs[nameOrID]
+}
+
+// newID returns a new id, like InfluxDB's 16 hex digit ones. s.mu must be held.
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("%016x", 0x0a00000000000000+s.nextID)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Influxdb-Version", s.version)
+	w.Header().Set("X-Influxdb-Build", "OSS")
+
+	s.mu.Lock()
+	w.Header().Set("Request-Id", s.newID())
+	s.requests[r.URL.Path]++
+	f := s.failure(r.URL.Path)
+	s.mu.Unlock()
+	if f != nil {
+		if f.RetryAfter != 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(f.RetryAfter))
+		}
+		writeError(w, f.StatusCode, codeFromStatus(f.StatusCode), http.StatusText(f.StatusCode))
+		return
+	}
+
+	var (
+		body io.ReadCloser
+		err  error
+	)
+	switch r.Header.Get("Content-Encoding") {
+	case "gzip":
+		body, err = gzip.NewReader(r.Body)
+	case "deflate":
+		body, err = zlib.NewReader(r.Body)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+	if body != nil {
+		defer body.Close()
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{body, r.Body}
+	}
+
+	switch r.URL.Path {
+	case "/ready", "/health", "/ping":
+		s.serveStatus(w, r)
+		return
+	case "/api/v2/setup":
+		s.serveSetup(w, r)
+		return
+	case "/api/v2/signin":
+		s.serveSignin(w, r)
+		return
+	case "/api/v2/signout":
+		s.serveSignout(w, r)
+		return
+	}
+
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unauthorized access")
+		return
+	}
+
+	elems := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v2"), "/"), "/")
+	switch elems[0] {
+	case "write":
+		s.serveWrite(w, r)
+	case "query":
+		s.serveQuery(w, r)
+	case "buckets":
+		s.serveBuckets(w, r, elems[1:])
+	case "users":
+		s.serveUsers(w, r, elems[1:])
+	case "authorizations":
+		s.serveAuthorizations(w, r, elems[1:])
+	default:
+		writeError(w, http.StatusNotFound, "not found", "path not found")
+	}
+}
+
+// failure returns the failure matching path, if any, and counts it. s.mu must be held.
+func (s *Server) failure(path string) *Failure {
+	for i, f := range s.failures {
+		if f.Path != "" && f.Path != path {
+			continue
+		}
+		if f.Count > 0 {
+			f.Count--
+			if f.Count == 0 {
+				s.failures = append(s.failures[:i:i], s.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// authorized reports whether r has the token of an active authorization or a session cookie,
+// or the server isn't set up yet.
+func (s *Server) authorized(r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.userID(r) != "" || !s.onboarded
+}
+
+// userID returns the id of the user making the request, or "". s.mu must be held.
+func (s *Server) userID(r *http.Request) string {
+	if cookie, err := r.Cookie("session"); err == nil {
+		if id, ok := s.sessions[cookie.Value]; ok {
+			return id
+		}
+	}
+	h := r.Header.Get("Authorization")
+	var token string
+	switch {
+	case strings.HasPrefix(h, "Token "):
+		token = strings.TrimPrefix(h, "Token ")
+	case strings.HasPrefix(h, "Bearer "):
+		token = strings.TrimPrefix(h, "Bearer ")
+	default:
+		return ""
+	}
+	for _, a := range s.auths {
+		if a.Token == token && a.Status == "active" {
+			return a.UserID
+		}
+	}
+	return ""
+}
+
+func (s *Server) serveStatus(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/ready":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready", "started": s.now().UTC().Format(time.RFC3339Nano), "up": "0s"})
+	case "/health":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"name":    "influxdb",
+			"message": "ready for queries and writes",
+			"status":  "pass",
+			"checks":  []struct{}{},
+			"version": s.version,
+			"commit":  "influxdbtest",
+		})
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) serveWrite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "method not allowed")
+		return
+	}
+	q := r.URL.Query()
+	precision, ok := precisions[q.Get("precision")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid", fmt.Sprintf("invalid precision %q", q.Get("precision")))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+	points, err := parseLineProtocol(body, precision, s.now())
+	if err != nil {
+		if perr, ok := err.(*parseError); ok {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"code": "invalid", "message": perr.Error(), "line": perr.line})
+			return
+		}
+		writeError(w, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.findOrg(q.Get("org"), q.Get("orgID"))
+	if o == nil {
+		writeError(w, http.StatusNotFound, "not found", fmt.Sprintf("organization name %q not found", q.Get("org")))
+		return
+	}
+	b := s.findBucket(o.ID, "", q.Get("bucket"))
+	if b == nil {
+		writeError(w, http.StatusNotFound, "not found", fmt.Sprintf("bucket %q not found", q.Get("bucket")))
+		return
+	}
+	b.write(points)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var precisions = map[string]time.Duration{
+	"":   time.Nanosecond,
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+func (s *Server) serveQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed", "method not allowed")
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid", err.Error())
+		return
+	}
+	query := string(body)
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/vnd.flux") {
+		var req struct {
+			Query string `json:"query"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid", "failed to decode request body: "+err.Error())
+			return
+		}
+		query = req.Query
+	}
+
+	now := s.now()
+	p, err := parseFlux(query, now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid", "compilation failed: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	o := s.findOrg(r.URL.Query().Get("org"), r.URL.Query().Get("orgID"))
+	var b *bucket
+	if o != nil {
+		b = s.findBucket(o.ID, "", p.bucket)
+	}
+	var points []Point
+	if b != nil {
+		// copied deeply, as writes merge fields into the points while the query runs
+		points = make([]Point, len(b.points))
+		for i, point := range b.points {
+			points[i] = point.copy()
+		}
+	}
+	s.mu.Unlock()
+	if o == nil {
+		writeError(w, http.StatusNotFound, "not found", fmt.Sprintf("organization name %q not found", r.URL.Query().Get("org")))
+		return
+	}
+	if b == nil {
+		writeError(w, http.StatusNotFound, "not found", fmt.Sprintf("could not find bucket %q", p.bucket))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = p.run(points).writeCSV(w)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]string{"code": code, "message": message})
+}
+
+func codeFromStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not found"
+	case http.StatusRequestEntityTooLarge:
+		return "request too large"
+	case http.StatusTooManyRequests:
+		return "too many requests"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	}
+	return "internal error"
+}
@@ -0,0 +1,200 @@
+package influxdbtest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	influxdb "github.com/lancey-energy-storage/influxdb-client-go"
+	"github.com/lancey-energy-storage/influxdb-client-go/influxdbtest"
+)
+
+func newClient(t *testing.T, s *influxdbtest.Server, token string) *influxdb.Client {
+	c, err := influxdb.New(s.URL, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestServer_writeAndQuery(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+	c := newClient(t, s, setup.Token)
+	defer c.Close()
+
+	ctx := context.Background()
+	ts := time.Date(2019, 5, 2, 16, 12, 41, 0, time.UTC)
+	metrics := []influxdb.Metric{
+		influxdb.NewRowMetric(map[string]interface{}{"usage": 0.5, "count": int64(3)}, "cpu", map[string]string{"host": "a"}, ts),
+		influxdb.NewRowMetric(map[string]interface{}{"usage": 0.25}, "cpu", map[string]string{"host": "b"}, ts.Add(time.Second)),
+	}
+	if _, err := c.Write(ctx, "my-bucket", "my-org", metrics...); err != nil {
+		t.Fatal(err)
+	}
+
+	points := s.Points("my-org", "my-bucket")
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+	if got := points[0].Fields["count"]; got != int64(3) {
+		t.Errorf("expected count 3, got %v", got)
+	}
+	if !points[1].Time.Equal(ts.Add(time.Second)) {
+		t.Errorf("expected time %s, got %s", ts.Add(time.Second), points[1].Time)
+	}
+
+	res, err := c.QueryCSV(ctx, `from(bucket: "my-bucket") |> range(start: 2019-05-02T00:00:00Z) |> filter(fn: (r) => r._field == "usage" and r.host == "b")`, "my-org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]interface{}
+	for res.Next() {
+		row := map[string]interface{}{}
+		if err := res.Unmarshal(row); err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if len(rows) != 1 || rows[0]["_value"] != 0.25 || rows[0]["host"] != "b" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
+
+func TestServer_writeErrors(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+	ctx := context.Background()
+	m := influxdb.NewRowMetric(map[string]interface{}{"usage": 0.5}, "cpu", nil, time.Now())
+
+	c := newClient(t, s, "wrong-token")
+	if _, err := c.Write(ctx, "my-bucket", "my-org", m); !errors.Is(err, influxdb.ErrUnauthorized) {
+		t.Errorf("expected an unauthorized error, got %v", err)
+	}
+
+	c = newClient(t, s, setup.Token)
+	if _, err := c.Write(ctx, "missing", "my-org", m); !errors.Is(err, influxdb.ErrNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestServer_Fail(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+	c := newClient(t, s, setup.Token)
+	ctx := context.Background()
+	m := influxdb.NewRowMetric(map[string]interface{}{"usage": 0.5}, "cpu", nil, time.Now())
+
+	s.Fail(influxdbtest.RateLimited("/api/v2/write", 3, 1))
+	_, err := c.Write(ctx, "my-bucket", "my-org", m)
+	if !errors.Is(err, influxdb.ErrRateLimited) || !influxdb.IsRetryable(err) {
+		t.Fatalf("expected a retryable rate limited error, got %v", err)
+	}
+	if d, ok := influxdb.RetryAfter(err); !ok || d != 3*time.Second {
+		t.Errorf("expected to retry after 3s, got %s, %v", d, ok)
+	}
+
+	// the failure only applied to one request
+	if _, err := c.Write(ctx, "my-bucket", "my-org", m); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Requests("/api/v2/write"); got != 2 {
+		t.Errorf("expected 2 write requests, got %d", got)
+	}
+	if got := len(s.Points("my-org", "my-bucket")); got != 1 {
+		t.Errorf("expected 1 point, got %d", got)
+	}
+}
+
+func TestServer_setup(t *testing.T) {
+	s := influxdbtest.NewServer(influxdbtest.WithVersion("2.0.0-beta.10"))
+	defer s.Close()
+	c, err := influxdb.New(s.URL, "", influxdb.WithUserAndPass("my-user", "my-password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	setup, err := c.GetSetup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !setup.Allowed {
+		t.Fatal("expected setup to be allowed")
+	}
+	res, err := c.Setup(ctx, "my-user", "my-password", "my-bucket", "my-org", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Auth.Token == "" {
+		t.Fatal("expected a token")
+	}
+	if res, err := c.Setup(ctx, "my-user", "my-password", "my-bucket", "my-org", 0); err != nil || res.Code != "conflict" {
+		t.Errorf("expected setting up twice to conflict, got %v, %v", res, err)
+	}
+
+	info, err := c.ServerInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "2.0.0-beta.10" {
+		t.Errorf("expected version 2.0.0-beta.10, got %q", info.Version)
+	}
+	if ok, err := c.Supports(ctx, influxdb.FeatureDelete); err != nil || !ok {
+		t.Errorf("expected delete to be supported, got %v, %v", ok, err)
+	}
+}
+
+func TestServer_resources(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+	c := newClient(t, s, setup.Token)
+	ctx := context.Background()
+
+	rules := []influxdb.RetentionRules{{Type: "expire", EverySeconds: 3600}}
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := c.CreateBucket("", name, setup.OrgID, rules, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.CreateBucket("", "a", setup.OrgID, rules, ""); !errors.Is(err, influxdb.ErrConflict) {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+	buckets, err := c.BucketIterator(ctx, influxdb.BucketFilter{OrgID: setup.OrgID, PageSize: 2}).ListAll(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 4 {
+		t.Errorf("expected 4 buckets, got %d", len(buckets))
+	}
+
+	u, err := c.CreateUser("other-user", "", "active")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err = c.UpdateUser(u.Id, "renamed-user", "", ""); err != nil || u.Name != "renamed-user" {
+		t.Fatalf("unexpected user %v, %v", u, err)
+	}
+	if err := c.DeleteUser(u.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUserById(u.Id); !errors.Is(err, influxdb.ErrNotFound) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	auths, err := c.AuthorizationIterator(ctx, influxdb.AuthorizationFilter{OrgID: setup.OrgID}).ListAll(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(auths) != 1 || auths[0].Token != setup.Token {
+		t.Errorf("unexpected authorizations %v", auths)
+	}
+}
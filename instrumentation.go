@@ -0,0 +1,110 @@
+package influxdb
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestEvent describes a single http request made by the client.
+type RequestEvent struct {
+	// Op is the client operation the request belongs to, such as "Write", "QueryCSV" or "GetBuckets".
+	Op string
+	// Method and Path are the http method and url path of the request.
+	Method string
+	Path   string
+	// StatusCode is the status code of the response, or 0 if the request failed without one.
+	StatusCode int
+	// Latency is the time until the response headers were received.
+	Latency time.Duration
+	// BytesSent and BytesReceived are the lengths of the request and response bodies, or -1 if unknown.
+	BytesSent     int64
+	BytesReceived int64
+	// ContentEncoding is the encoding the client compressed the request body with, if it did.
+	// UncompressedBytes is then the length of the body before compression, and CompressionTime the time spent compressing it.
+	ContentEncoding   string
+	UncompressedBytes int64
+	CompressionTime   time.Duration
+	// Attempt is 1 for the first attempt of a request, and counts up when the client retries it.
+	Attempt int
+	// Err is the error returned by the http client, if any.
+	Err error
+}
+
+// CompressionRatio returns how many times smaller compression made the request body, or 0 if it wasn't compressed, or its size isn't known.
+func (e RequestEvent) CompressionRatio() float64 {
+	if e.ContentEncoding == "" || e.BytesSent <= 0 || e.UncompressedBytes <= 0 {
+		return 0
+	}
+	return float64(e.UncompressedBytes) / float64(e.BytesSent)
+}
+
+// Instrumentation receives an event for every request the client makes.
+// Implementations must be safe to call concurrently, and should return quickly.
+type Instrumentation interface {
+	RequestDone(e RequestEvent)
+}
+
+// InstrumentationFunc is an adapter to allow the use of ordinary functions as Instrumentation.
+type InstrumentationFunc func(e RequestEvent)
+
+// RequestDone calls f(e).
+func (f InstrumentationFunc) RequestDone(e RequestEvent) {
+	f(e)
+}
+
+// WithInstrumentation returns an option for reporting every request the client makes to i.
+func WithInstrumentation(i Instrumentation) Option {
+	return Option{
+		name: "WithInstrumentation",
+		f: func(c *Client) error {
+			c.instrumentation = i
+			return nil
+		},
+	}
+}
+
+type attemptKey struct{}
+
+// withAttempt records in the context that a request is being retried.
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// instrument reports the outcome of a single request to the client's instrumentation, if it has one.
+func (c *Client) instrument(op string, req *http.Request, resp *http.Response, err error, latency time.Duration) {
+	if c.instrumentation == nil {
+		return
+	}
+	e := RequestEvent{
+		Op:            op,
+		Method:        req.Method,
+		Path:          req.URL.Path,
+		Latency:       latency,
+		BytesSent:     req.ContentLength,
+		BytesReceived: -1,
+		Attempt:       1,
+		Err:           err,
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.ContentLength == 0 {
+		// http.NewRequest only knows the length of in-memory bodies
+		e.BytesSent = -1
+	}
+	if s, ok := req.Context().Value(compressionKey{}).(*compressionStats); ok {
+		s.mu.Lock()
+		e.ContentEncoding, e.UncompressedBytes, e.CompressionTime = s.encoding, s.in, s.elapsed
+		if e.BytesSent == -1 {
+			// a streamed body, which has been sent by the time there's a response
+			e.BytesSent = s.out
+		}
+		s.mu.Unlock()
+	}
+	if attempt, ok := req.Context().Value(attemptKey{}).(int); ok {
+		e.Attempt = attempt
+	}
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+		e.BytesReceived = resp.ContentLength
+	}
+	c.instrumentation.RequestDone(e)
+}
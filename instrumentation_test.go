@@ -0,0 +1,201 @@
+package influxdb
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithInstrumentation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/write":
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/v2/users/1":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	var events []RequestEvent
+	c, err := New(server.URL, "foo",
+		WithHTTPClient(server.Client()),
+		WithInstrumentation(InstrumentationFunc(func(e RequestEvent) {
+			events = append(events, e)
+		})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Write(context.Background(), "bucket", "org", createTestRowMetrics(t, 3)...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUserById("1"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %v", events)
+	}
+	if e := events[0]; e.Op != "Write" || e.Method != http.MethodPost || e.Path != "/api/v2/write" || e.StatusCode != http.StatusNoContent || e.Attempt != 1 || e.BytesSent <= 0 {
+		t.Errorf("unexpected write event %+v", e)
+	}
+	if e := events[1]; e.Op != "GetUserById" || e.StatusCode != http.StatusNotFound || e.BytesSent != 0 || e.Latency <= 0 {
+		t.Errorf("unexpected management event %+v", e)
+	}
+}
+
+func TestSelfMetrics(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		written []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/write" && r.URL.Query().Get("bucket") == "monitoring" {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data, _ := ioutil.ReadAll(reader)
+			mu.Lock()
+			written = append(written, string(data))
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	m := NewSelfMetrics("monitoring", "org", time.Hour)
+	c, err := New(server.URL, "foo", WithHTTPClient(server.Client()), WithInstrumentation(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Flush(context.Background()); err == nil {
+		t.Fatal("expected an error flushing before Start")
+	}
+	m.Start(c)
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Write(context.Background(), "bucket", "org", createTestRowMetrics(t, 1)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(written) != 1 {
+		t.Fatalf("expected a single write of self metrics, got %v", written)
+	}
+	line := written[0]
+	for _, want := range []string{
+		"influxdb_client_requests,method=POST,op=Write,status=204 ",
+		"requests=3i",
+		"errors=0i",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in %q", want, line)
+		}
+	}
+}
+
+func TestNewSelfMetrics_interval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		m := NewSelfMetrics("monitoring", "org", interval)
+		if m.interval != defaultSelfMetricsInterval {
+			t.Errorf("expected an interval of %s to default to %s, got %s", interval, defaultSelfMetricsInterval, m.interval)
+		}
+		// the ticker of a non-positive interval would panic
+		m.Start(&Client{})
+		if err := m.Stop(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSelfMetrics_Flush_failed(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fail    = true
+		written []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("bucket") == "monitoring" {
+			mu.Lock()
+			defer mu.Unlock()
+			if fail {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			data, _ := ioutil.ReadAll(reader)
+			written = append(written, string(data))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	m := NewSelfMetrics("monitoring", "org", time.Hour)
+	c, err := New(server.URL, "foo", WithHTTPClient(server.Client()), WithInstrumentation(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start(c)
+	defer m.Stop()
+
+	if _, err := c.Write(context.Background(), "bucket", "org", createTestRowMetrics(t, 1)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Flush(context.Background()); err == nil {
+		t.Fatal("expected the flush to fail")
+	}
+
+	// the next flush writes the statistics of the failed one too
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	if _, err := c.Write(context.Background(), "bucket", "org", createTestRowMetrics(t, 1)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(written) != 1 {
+		t.Fatalf("expected a single write of self metrics, got %v", written)
+	}
+	requests := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(written[0]), "\n") {
+		series := strings.SplitN(line, " ", 2)[0]
+		for _, field := range strings.Split(strings.SplitN(line, " ", 3)[1], ",") {
+			if strings.HasPrefix(field, "requests=") {
+				requests[series] = field
+			}
+		}
+	}
+	want := map[string]string{
+		"influxdb_client_requests,method=POST,op=Write,status=204": "requests=2i",
+		"influxdb_client_requests,method=POST,op=Write,status=503": "requests=1i",
+	}
+	if !reflect.DeepEqual(want, requests) {
+		t.Errorf("expected requests %v, got %v in %q", want, requests, written[0])
+	}
+}
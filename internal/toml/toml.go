@@ -0,0 +1,125 @@
+// Package toml parses the small subset of TOML used by influx CLI config files:
+// tables of keys with string, boolean, integer and float values.
+package toml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Table is a table of keys and their values.
+// Values are string, bool, int64 or float64.
+type Table map[string]interface{}
+
+// Parse parses a document into its top level keys and named tables.
+// Keys outside of any table are returned in the table named "".
+func Parse(r io.Reader) (map[string]Table, error) {
+	tables := map[string]Table{"": {}}
+	current := tables[""]
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table header %q", n, line)
+			}
+			name := unquoteKey(strings.TrimSpace(line[1 : len(line)-1]))
+			if name == "" {
+				return nil, fmt.Errorf("line %d: empty table name", n)
+			}
+			if _, ok := tables[name]; ok {
+				return nil, fmt.Errorf("line %d: table %q is defined more than once", n, name)
+			}
+			current = Table{}
+			tables[name] = current
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key := unquoteKey(strings.TrimSpace(line[:eq]))
+		if key == "" {
+			return nil, fmt.Errorf("line %d: empty key", n)
+		}
+		value, err := parseValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		current[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// stripComment removes a trailing comment, ignoring # inside of quoted strings.
+func stripComment(line string) string {
+	var (
+		quote   byte
+		escaped bool
+	)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && c == '\\':
+			escaped = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquoteKey(key string) string {
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+func parseValue(v string) (interface{}, error) {
+	switch {
+	case v == "":
+		return nil, fmt.Errorf("missing value")
+	case v == "true":
+		return true, nil
+	case v == "false":
+		return false, nil
+	case v[0] == '"':
+		s, err := strconv.Unquote(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", v)
+		}
+		return s, nil
+	case v[0] == '\'':
+		if len(v) < 2 || v[len(v)-1] != '\'' {
+			return nil, fmt.Errorf("invalid string %s", v)
+		}
+		return v[1 : len(v)-1], nil
+	}
+	clean := strings.Replace(v, "_", "", -1)
+	if i, err := strconv.ParseInt(clean, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(clean, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported value %s", v)
+}
@@ -0,0 +1,66 @@
+package toml_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lancey-energy-storage/influxdb-client-go/internal/toml"
+)
+
+func TestParse(t *testing.T) {
+	doc := `
+# a comment
+top = 1
+
+[default]
+  url = "http://localhost:9999" # trailing comment
+  token = "abc#def=="
+  org = 'my-org'
+  active = true
+  timeout = 1_000
+  ratio = 0.5
+
+["other profile"]
+  url = "http://remote:9999"
+  active = false
+`
+	got, err := toml.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]toml.Table{
+		"": {"top": int64(1)},
+		"default": {
+			"url":     "http://localhost:9999",
+			"token":   "abc#def==",
+			"org":     "my-org",
+			"active":  true,
+			"timeout": int64(1000),
+			"ratio":   0.5,
+		},
+		"other profile": {
+			"url":    "http://remote:9999",
+			"active": false,
+		},
+	}
+	if !cmp.Equal(got, want) {
+		t.Fatalf("unexpected result: %s", cmp.Diff(want, got))
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	for _, doc := range []string{
+		"[default",
+		"[a]\n[a]",
+		"key",
+		"key =",
+		"key = nope",
+		`key = "unterminated`,
+		"[[array]]",
+	} {
+		if _, err := toml.Parse(strings.NewReader(doc)); err == nil {
+			t.Errorf("expected an error parsing %q", doc)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// LineProtocolStats reports how much line protocol WriteLineProtocol sent.
+type LineProtocolStats struct {
+	// Bytes is the number of bytes read from the reader, before compression.
+	Bytes int64
+	// Lines is the number of lines, not counting blank lines and comments.
+	Lines int64
+}
+
+// WriteLineProtocol writes line protocol from r to a bucket, and org.
+// Timestamps are in units of the client's precision, nanoseconds unless set with WithPrecision or WithWritePrecision.
+// The reader is streamed into the request, compressed on the fly when the client compresses writes, so it is never buffered in full,
+// unless the client has failover endpoints and has to be able to resend it.
+// The stats are those of what was read from r, even if the write failed.
+func (c *Client) WriteLineProtocol(ctx context.Context, bucket, org string, r io.Reader, opts ...WriteOption) (LineProtocolStats, error) {
+	o, err := c.writeOptions(opts)
+	if err != nil {
+		return LineProtocolStats{}, err
+	}
+
+	c.log(LevelDebug, "writing line protocol", "op", "WriteLineProtocol", "bucket", bucket, "org", org)
+
+	select {
+	case <-ctx.Done():
+		return LineProtocolStats{}, ctx.Err()
+	default:
+	}
+
+	lr := &lineReader{r: r, validate: o.validate}
+	req, err := c.newWriteRequest(ctx, bucket, org, o.precision, lr, nil)
+	if err != nil {
+		return LineProtocolStats{}, err
+	}
+
+	resp, err := c.do("WriteLineProtocol", req)
+	// the transport doesn't read the body to the end if the request is abandoned,
+	// so close it to release the writer compressing it
+	_ = req.Body.Close()
+	stats, verr := lr.result()
+	if verr != nil {
+		// the transport reports the read error in its own words
+		err = verr
+	}
+	if err != nil {
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		return stats, err
+	}
+
+	defer func() {
+		// discard body so connection can be reused
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	eerr, err := parseWriteError(resp)
+	if err != nil {
+		return stats, err
+	}
+	if eerr != nil {
+		eerr.Op = "WriteLineProtocol"
+		return stats, eerr
+	}
+	return stats, nil
+}
+
+// lineReader counts the bytes and lines read through it, and optionally validates each line.
+// Only the current line is kept in memory.
+type lineReader struct {
+	r        io.Reader
+	validate bool
+	line     []byte
+	lineNum  int32
+
+	// mu guards stats and err, as the body may be read by the compressing goroutine
+	mu    sync.Mutex
+	stats LineProtocolStats
+	err   *Error
+}
+
+// result returns the stats so far, and the validation error if there was one.
+func (l *lineReader) result() (LineProtocolStats, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.stats, l.err
+	}
+	return l.stats, nil
+}
+
+func (l *lineReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Bytes += int64(n)
+	for data := p[:n]; len(data) > 0; {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			l.line = append(l.line, data...)
+			break
+		}
+		l.line = append(l.line, data[:i]...)
+		data = data[i+1:]
+		if verr := l.endLine(); verr != nil {
+			return 0, verr
+		}
+	}
+	if err == io.EOF && len(l.line) > 0 {
+		if verr := l.endLine(); verr != nil {
+			return 0, verr
+		}
+	}
+	return n, err
+}
+
+func (l *lineReader) endLine() error {
+	l.lineNum++
+	line := bytes.TrimSpace(l.line)
+	l.line = l.line[:0]
+	if len(line) == 0 || line[0] == '#' {
+		return nil
+	}
+	l.stats.Lines++
+	if !l.validate {
+		return nil
+	}
+	if err := validateLine(line); err != nil {
+		num := l.lineNum
+		l.err = &Error{Code: EInvalid, Op: "WriteLineProtocol", Message: err.Error(), Line: &num}
+		return l.err
+	}
+	return nil
+}
+
+// validateLine checks the structure of a line of line protocol: a measurement, tags, at least one field, and an optional integer timestamp.
+// It does not check the types of field values beyond strings being terminated.
+func validateLine(line []byte) error {
+	key, rest := cutUnescaped(line, ' ', false)
+	fields, timestamp := cutUnescaped(rest, ' ', true)
+
+	if len(key) == 0 || key[0] == ',' {
+		return errors.New("missing measurement")
+	}
+	for tags := key; ; {
+		i := indexUnescaped(tags, ',', false)
+		if i < 0 {
+			break
+		}
+		tags = tags[i+1:]
+		tag, _ := cutUnescaped(tags, ',', false)
+		k, v := cutUnescaped(tag, '=', false)
+		if len(k) == 0 || len(v) == 0 {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+
+	if len(fields) == 0 {
+		return errors.New("missing fields")
+	}
+	for {
+		// a comma is followed by another field, so a trailing one leaves an empty field
+		i := indexUnescaped(fields, ',', true)
+		field := fields
+		if i >= 0 {
+			field = fields[:i]
+		}
+		k, v := cutUnescaped(field, '=', true)
+		if len(k) == 0 || len(v) == 0 {
+			return fmt.Errorf("invalid field %q", field)
+		}
+		if v[0] == '"' && (len(v) < 2 || v[len(v)-1] != '"') {
+			return fmt.Errorf("unterminated string in field %q", k)
+		}
+		if i < 0 {
+			break
+		}
+		fields = fields[i+1:]
+	}
+
+	digits := timestamp
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if len(timestamp) > 0 && len(digits) == 0 {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	for _, b := range digits {
+		if b < '0' || b > '9' {
+			return fmt.Errorf("invalid timestamp %q", timestamp)
+		}
+	}
+	return nil
+}
+
+// cutUnescaped cuts s around the first sep not escaped by a backslash, or inside a quoted string if quotes is set.
+func cutUnescaped(s []byte, sep byte, quotes bool) ([]byte, []byte) {
+	if i := indexUnescaped(s, sep, quotes); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, nil
+}
+
+func indexUnescaped(s []byte, sep byte, quotes bool) int {
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			inString = !inString
+		case !inString && s[i] == sep:
+			return i
+		}
+	}
+	return -1
+}
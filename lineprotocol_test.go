@@ -0,0 +1,96 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lancey-energy-storage/influxdb-client-go/influxdbtest"
+)
+
+func TestClient_WriteLineProtocol(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+
+	data := "# from the device\n" +
+		"cpu,host=a usage=0.5 1556813561098000000\n" +
+		"\n" +
+		"cpu,host=b usage=0.25,count=3i 1556813561098000000\n" +
+		`log,host=a msg="hello\, world" 1556813561098000000` // no trailing newline
+
+	for _, opt := range []Option{WithGZIP(4), WithNoCompression()} {
+		t.Run(opt.name, func(t *testing.T) {
+			s.ClearFailures()
+			c, err := New(s.URL, setup.Token, opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats, err := c.WriteLineProtocol(context.Background(), "my-bucket", "my-org", strings.NewReader(data), WithValidation())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Bytes != int64(len(data)) || stats.Lines != 3 {
+				t.Errorf("unexpected stats %+v", stats)
+			}
+		})
+	}
+	if got := len(s.Points("my-org", "my-bucket")); got != 3 {
+		t.Errorf("expected 3 points, got %d", got)
+	}
+}
+
+func TestClient_WriteLineProtocol_invalid(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+	c, err := New(s.URL, setup.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := "cpu usage=0.5\ncpu usage=0.6\ncpu,host usage=0.7\ncpu usage=0.8\n"
+	stats, err := c.WriteLineProtocol(context.Background(), "my-bucket", "my-org", strings.NewReader(data), WithValidation())
+	var ierr *Error
+	if !errors.As(err, &ierr) || !errors.Is(err, ErrInvalid) {
+		t.Fatalf("expected an invalid error, got %v", err)
+	}
+	if ierr.Line == nil || *ierr.Line != 3 {
+		t.Errorf("expected the error on line 3, got %v", ierr)
+	}
+	if stats.Lines != 3 {
+		t.Errorf("expected 3 lines read, got %d", stats.Lines)
+	}
+	if got := len(s.Points("my-org", "my-bucket")); got != 0 {
+		t.Errorf("expected no points, got %d", got)
+	}
+}
+
+func TestValidateLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		wantErr bool
+	}{
+		{line: `cpu usage=0.5`},
+		{line: `cpu,host=a,region=us usage=0.5,count=3i 1556813561098000000`},
+		{line: `cpu\ load,host\=x=a\,b usage=0.5`},
+		{line: `log msg="a, b=c d" -5`},
+		{line: `,host=a usage=0.5`, wantErr: true},
+		{line: `cpu`, wantErr: true},
+		{line: `cpu,host usage=0.5`, wantErr: true},
+		{line: `cpu,host=a`, wantErr: true},
+		{line: `cpu usage=`, wantErr: true},
+		{line: `cpu usage=0.5,=1`, wantErr: true},
+		{line: `log msg="open`, wantErr: true},
+		{line: `cpu usage=0.5 12a`, wantErr: true},
+		{line: `cpu usage=0.5,`, wantErr: true},
+		{line: `cpu usage=0.5 -`, wantErr: true},
+		{line: `cpu usage=0.5 1-2`, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateLine([]byte(tt.line)); (err != nil) != tt.wantErr {
+			t.Errorf("validateLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+		}
+	}
+}
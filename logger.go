@@ -0,0 +1,125 @@
+package influxdb
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// LogLevel is the severity of a log message.
+type LogLevel int
+
+// Log levels, from the most to the least verbose.
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the name of the level, as used by the standard library adapter.
+func (l LogLevel) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+// Logger receives the client's log messages.
+// keyvals is a list of alternating keys and values, such as "op", "GetBuckets", "status", 200.
+// Implementations must be safe to call concurrently.
+type Logger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+// WithLogger returns an option for setting the Logger the client logs to.
+// By default the client doesn't log anything.
+func WithLogger(l Logger) Option {
+	return Option{
+		name: "WithLogger",
+		f: func(c *Client) error {
+			c.logger = l
+			return nil
+		},
+	}
+}
+
+// NewStdLogger returns a Logger that writes messages at or above minLevel to a standard library *log.Logger.
+// If l is nil, messages are written to stderr.
+// Messages look like:
+//
+//	[DEBUG] request op=GetBucketByID method=GET status=200
+func NewStdLogger(l *log.Logger, minLevel LogLevel) Logger {
+	if l == nil {
+		l = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return &stdLogger{l: l, minLevel: minLevel}
+}
+
+type stdLogger struct {
+	l        *log.Logger
+	minLevel LogLevel
+}
+
+func (s *stdLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	if level < s.minLevel {
+		return
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "[%s] %s", level, msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		var v interface{} = "(MISSING)"
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+		val := fmt.Sprint(v)
+		if strings.ContainsAny(val, " \t\n\"=") {
+			val = fmt.Sprintf("%q", val)
+		}
+		fmt.Fprintf(b, " %v=%s", keyvals[i], val)
+	}
+	s.l.Print(b.String())
+}
+
+// log sends a message to the client's logger, if it has one.
+func (c *Client) log(level LogLevel, msg string, keyvals ...interface{}) {
+	if c.logger == nil {
+		return
+	}
+	c.logger.Log(level, msg, keyvals...)
+}
+
+// logRoundTrip logs the outcome of a single request.
+func (c *Client) logRoundTrip(op string, req *http.Request, resp *http.Response, err error, latency time.Duration) {
+	if c.logger == nil {
+		return
+	}
+	if err != nil {
+		c.logger.Log(LevelError, "request failed",
+			"op", op,
+			"method", req.Method,
+			"url", req.URL.String(),
+			"latency", latency,
+			"error", err)
+		return
+	}
+	c.logger.Log(LevelDebug, "request",
+		"op", op,
+		"method", req.Method,
+		"url", req.URL.String(),
+		"status", resp.StatusCode,
+		"latency", latency,
+		"bytes_sent", req.ContentLength,
+		"bytes_received", resp.ContentLength)
+}
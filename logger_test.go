@@ -0,0 +1,81 @@
+package influxdb
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type logEntry struct {
+	level   LogLevel
+	msg     string
+	keyvals []interface{}
+}
+
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (r *recordingLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, logEntry{level, msg, keyvals})
+}
+
+func TestWithLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	l := &recordingLogger{}
+	c, err := New(server.URL, "foo", WithHTTPClient(server.Client()), WithLogger(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteUser("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(l.entries) != 2 {
+		t.Fatalf("expected 2 log entries, got %v", l.entries)
+	}
+	if got := fmt.Sprint(l.entries[0].keyvals); got != "[op DeleteUser userID 1]" {
+		t.Errorf("unexpected operation fields %s", got)
+	}
+	req := l.entries[1]
+	if req.level != LevelDebug || req.msg != "request" {
+		t.Errorf("unexpected request entry %v", req)
+	}
+	fields := map[interface{}]interface{}{}
+	for i := 0; i < len(req.keyvals); i += 2 {
+		fields[req.keyvals[i]] = req.keyvals[i+1]
+	}
+	if fields["method"] != http.MethodDelete || fields["status"] != http.StatusNoContent || fields["url"] != server.URL+"/api/v2/users/1" {
+		t.Errorf("unexpected request fields %v", fields)
+	}
+	if _, ok := fields["latency"]; !ok {
+		t.Error("expected a latency field")
+	}
+}
+
+func TestNewStdLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := NewStdLogger(log.New(buf, "", 0), LevelInfo)
+
+	l.Log(LevelDebug, "hidden", "op", "Write")
+	l.Log(LevelInfo, "setting up", "op", "Setup", "org", "my org")
+	l.Log(LevelError, "request failed", "status", 500, "dangling")
+
+	want := `[INFO] setting up op=Setup org="my org"
+[ERROR] request failed status=500 dangling=(MISSING)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
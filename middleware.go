@@ -0,0 +1,54 @@
+package influxdb
+
+import (
+	"net/http"
+	"time"
+)
+
+// RoundTripFunc sends a single http request and returns its response.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the RoundTripFunc used to send every request the client makes.
+// A Middleware may inspect or modify the request, decide not to call next,
+// or inspect or replace the response.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// WithMiddleware returns an option for wrapping every outgoing request in middleware.
+// Middleware is applied in the order given, so the first one sees the request first and the response last.
+// Using this option more than once appends to the chain.
+func WithMiddleware(mw ...Middleware) Option {
+	return Option{
+		name: "WithMiddleware",
+		f: func(c *Client) error {
+			c.middleware = append(c.middleware, mw...)
+			return nil
+		},
+	}
+}
+
+// roundTrip sends a request to the current endpoint, see send.
+// op names the client operation the request belongs to.
+func (c *Client) roundTrip(op string, req *http.Request) (*http.Response, error) {
+	if c.failover != nil {
+		return c.failover.roundTrip(c, op, req)
+	}
+	return c.send(op, req)
+}
+
+// send sends a request through the middleware chain and then the http client,
+// and then logs and reports the outcome.
+func (c *Client) send(op string, req *http.Request) (*http.Response, error) {
+	next := RoundTripFunc(c.httpClient.Do)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		next = c.middleware[i](next)
+	}
+	start := time.Now()
+	resp, err := next(req)
+	latency := time.Since(start)
+	if resp != nil {
+		c.observeVersion(resp.Header)
+	}
+	c.logRoundTrip(op, req, resp, err, latency)
+	c.instrument(op, req, resp, err, latency)
+	return resp, err
+}
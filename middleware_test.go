@@ -0,0 +1,79 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("X-Trace-Id")+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var order []string
+	named := func(name string) Middleware {
+		return func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+" before")
+				resp, err := next(req)
+				order = append(order, name+" after")
+				return resp, err
+			}
+		}
+	}
+	tracing := func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Trace-Id", "abc")
+			return next(req)
+		}
+	}
+
+	c, err := New(server.URL, "foo",
+		WithHTTPClient(server.Client()),
+		WithMiddleware(named("outer"), named("inner")),
+		WithMiddleware(tracing),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Write(context.Background(), "bucket", "org", createTestRowMetrics(t, 1)...); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"abc /ready", "abc /api/v2/write"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("expected server to see %v, got %v", want, seen)
+	}
+	want := []string{"outer before", "inner before", "inner after", "outer after"}
+	if !reflect.DeepEqual(order[:4], want) {
+		t.Errorf("expected middleware order %v, got %v", want, order[:4])
+	}
+
+	t.Run("short circuit", func(t *testing.T) {
+		errInjected := errors.New("injected fault")
+		c, err := New(server.URL, "foo",
+			WithHTTPClient(server.Client()),
+			WithMiddleware(func(RoundTripFunc) RoundTripFunc {
+				return func(*http.Request) (*http.Response, error) {
+					return nil, errInjected
+				}
+			}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Ping(context.Background()); !errors.Is(err, errInjected) {
+			t.Fatalf("expected %v, got %v", errInjected, err)
+		}
+	})
+}
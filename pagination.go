@@ -0,0 +1,300 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// maxPageSize is the largest page the server returns.
+const maxPageSize = 100
+
+// ErrListCapReached is returned by the ListAll methods of iterators when there are more results than the cap.
+var ErrListCapReached = errors.New("more results than the list cap")
+
+// listPage is a page of results from a list endpoint.
+type listPage interface {
+	// pageInfo returns the number of results on the page, the id of the first one, and the link to the next page.
+	pageInfo() (n int, firstID string, next string)
+}
+
+// pager fetches the pages of a list endpoint, following links.next when the server sends one,
+// and counting offsets when it doesn't.
+type pager struct {
+	c        *Client
+	ctx      context.Context
+	op       string
+	path     string
+	query    url.Values // of the next page
+	pageSize int
+	firstID  string // of the previous page
+	done     bool
+	err      error
+}
+
+func newPager(ctx context.Context, c *Client, op, path string, query url.Values, pageSize int) *pager {
+	if pageSize <= 0 || pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	query.Set("limit", strconv.Itoa(pageSize))
+	query.Set("offset", "0")
+	return &pager{c: c, ctx: ctx, op: op, path: path, query: query, pageSize: pageSize}
+}
+
+// fetch decodes the next page into page, and reports whether there was one with results in it.
+func (p *pager) fetch(page listPage) bool {
+	if p.done || p.err != nil {
+		return false
+	}
+	c := p.c
+	c.log(LevelDebug, "fetching page", "op", p.op, "offset", p.query.Get("offset"))
+
+	p.err = c.doAPI(p.ctx, apiRequest{
+		op:     p.op,
+		method: http.MethodGet,
+		path:   p.path,
+		query:  p.query,
+	}, page)
+	if p.err != nil {
+		return false
+	}
+
+	n, firstID, next := page.pageInfo()
+	if n == 0 || (firstID != "" && firstID == p.firstID) {
+		// an empty page, or the server ignored the offset and sent the previous page again.
+		p.done = true
+		return false
+	}
+	p.firstID = firstID
+
+	if next != "" {
+		if u, err := url.Parse(next); err == nil && u.Query().Get("offset") != "" {
+			p.query = u.Query()
+			return true
+		}
+	}
+	if n != p.pageSize {
+		// a short page is the last one, and a long one means the server doesn't paginate.
+		p.done = true
+		return true
+	}
+	offset, _ := strconv.Atoi(p.query.Get("offset"))
+	p.query.Set("offset", strconv.Itoa(offset+n))
+	return true
+}
+
+func (s *BucketSource) pageInfo() (int, string, string) {
+	if len(s.Buckets) == 0 {
+		return 0, "", ""
+	}
+	return len(s.Buckets), s.Buckets[0].Id, s.Links.Next
+}
+
+func (l *UserList) pageInfo() (int, string, string) {
+	if len(l.Users) == 0 {
+		return 0, "", ""
+	}
+	return len(l.Users), l.Users[0].Id, l.Links.Next
+}
+
+func (l *AuthorizationsList) pageInfo() (int, string, string) {
+	if len(l.Authorizations) == 0 {
+		return 0, "", ""
+	}
+	return len(l.Authorizations), l.Authorizations[0].Id, l.Links.Next
+}
+
+// BucketFilter selects the buckets listed by BucketIterator. Empty fields don't filter.
+type BucketFilter struct {
+	Name  string
+	Org   string
+	OrgID string
+	// PageSize is the number of buckets fetched per request, at most 100, which is also the default.
+	PageSize int
+}
+
+// BucketIterator iterates over buckets, fetching them a page at a time.
+type BucketIterator struct {
+	p    *pager
+	page []Bucket
+	i    int
+}
+
+// BucketIterator returns an iterator over the buckets matching filter.
+// Pages are fetched as they are needed, so stopping early saves requests. Use it like so:
+//
+//	it := influx.BucketIterator(ctx, influxdb.BucketFilter{Org: "my-org"})
+//	for it.Next() {
+//		b := it.Bucket()
+//		... // do thing here
+//	}
+//	if err := it.Err(); err != nil {
+//		... // handle error
+//	}
+func (c *Client) BucketIterator(ctx context.Context, filter BucketFilter) *BucketIterator {
+	query := url.Values{}
+	setIfNotEmpty(query, "name", filter.Name)
+	setIfNotEmpty(query, "org", filter.Org)
+	setIfNotEmpty(query, "orgID", filter.OrgID)
+	return &BucketIterator{p: newPager(ctx, c, "GetBuckets", c.apiPath("buckets"), query, filter.PageSize)}
+}
+
+// Next advances to the next bucket, and reports whether there is one.
+func (it *BucketIterator) Next() bool {
+	it.i++
+	for it.i >= len(it.page) {
+		page := &BucketSource{}
+		if !it.p.fetch(page) {
+			it.page = nil
+			return false
+		}
+		it.page, it.i = page.Buckets, 0
+	}
+	return true
+}
+
+// Bucket returns the current bucket.
+func (it *BucketIterator) Bucket() Bucket {
+	return it.page[it.i]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *BucketIterator) Err() error {
+	return it.p.err
+}
+
+// ListAll collects the remaining buckets.
+// If there are more than max, it returns the first max with ErrListCapReached. A max of 0 or less means no cap.
+func (it *BucketIterator) ListAll(max int) ([]Bucket, error) {
+	var all []Bucket
+	for it.Next() {
+		if max > 0 && len(all) == max {
+			return all, ErrListCapReached
+		}
+		all = append(all, it.Bucket())
+	}
+	return all, it.Err()
+}
+
+// UserFilter selects the users listed by UserIterator.
+type UserFilter struct {
+	// PageSize is the number of users fetched per request, at most 100, which is also the default.
+	PageSize int
+}
+
+// UserIterator iterates over users, fetching them a page at a time.
+type UserIterator struct {
+	p    *pager
+	page []User
+	i    int
+}
+
+// UserIterator returns an iterator over the users matching filter.
+// It is used like BucketIterator.
+func (c *Client) UserIterator(ctx context.Context, filter UserFilter) *UserIterator {
+	return &UserIterator{p: newPager(ctx, c, "GetAllUsers", c.apiPath("users"), url.Values{}, filter.PageSize)}
+}
+
+// Next advances to the next user, and reports whether there is one.
+func (it *UserIterator) Next() bool {
+	it.i++
+	for it.i >= len(it.page) {
+		page := &UserList{}
+		if !it.p.fetch(page) {
+			it.page = nil
+			return false
+		}
+		it.page, it.i = page.Users, 0
+	}
+	return true
+}
+
+// User returns the current user.
+func (it *UserIterator) User() User {
+	return it.page[it.i]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *UserIterator) Err() error {
+	return it.p.err
+}
+
+// ListAll collects the remaining users.
+// If there are more than max, it returns the first max with ErrListCapReached. A max of 0 or less means no cap.
+func (it *UserIterator) ListAll(max int) ([]User, error) {
+	var all []User
+	for it.Next() {
+		if max > 0 && len(all) == max {
+			return all, ErrListCapReached
+		}
+		all = append(all, it.User())
+	}
+	return all, it.Err()
+}
+
+// AuthorizationFilter selects the authorizations listed by AuthorizationIterator. Empty fields don't filter.
+type AuthorizationFilter struct {
+	Org    string
+	OrgID  string
+	User   string
+	UserID string
+	// PageSize is the number of authorizations fetched per request, at most 100, which is also the default.
+	PageSize int
+}
+
+// AuthorizationIterator iterates over authorizations, fetching them a page at a time.
+type AuthorizationIterator struct {
+	p    *pager
+	page []Authorization
+	i    int
+}
+
+// AuthorizationIterator returns an iterator over the authorizations matching filter.
+// It is used like BucketIterator.
+func (c *Client) AuthorizationIterator(ctx context.Context, filter AuthorizationFilter) *AuthorizationIterator {
+	query := url.Values{}
+	setIfNotEmpty(query, "org", filter.Org)
+	setIfNotEmpty(query, "orgID", filter.OrgID)
+	setIfNotEmpty(query, "user", filter.User)
+	setIfNotEmpty(query, "userID", filter.UserID)
+	return &AuthorizationIterator{p: newPager(ctx, c, "GetAllAuthorizations", c.apiPath("authorizations"), query, filter.PageSize)}
+}
+
+// Next advances to the next authorization, and reports whether there is one.
+func (it *AuthorizationIterator) Next() bool {
+	it.i++
+	for it.i >= len(it.page) {
+		page := &AuthorizationsList{}
+		if !it.p.fetch(page) {
+			it.page = nil
+			return false
+		}
+		it.page, it.i = page.Authorizations, 0
+	}
+	return true
+}
+
+// Authorization returns the current authorization.
+func (it *AuthorizationIterator) Authorization() Authorization {
+	return it.page[it.i]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *AuthorizationIterator) Err() error {
+	return it.p.err
+}
+
+// ListAll collects the remaining authorizations.
+// If there are more than max, it returns the first max with ErrListCapReached. A max of 0 or less means no cap.
+func (it *AuthorizationIterator) ListAll(max int) ([]Authorization, error) {
+	var all []Authorization
+	for it.Next() {
+		if max > 0 && len(all) == max {
+			return all, ErrListCapReached
+		}
+		all = append(all, it.Authorization())
+	}
+	return all, it.Err()
+}
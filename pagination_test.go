@@ -0,0 +1,119 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// pagingServer serves n buckets with offset and limit, linking to the next page,
+// and n users, ignoring offset and limit.
+func pagingServer(t *testing.T, n int, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v2/buckets":
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			if r.URL.Query().Get("org") != "my-org" {
+				t.Errorf("expected the org filter, got %q", r.URL.RawQuery)
+			}
+			page := &BucketSource{}
+			for i := offset; i < offset+limit && i < n; i++ {
+				page.Buckets = append(page.Buckets, Bucket{Id: strconv.Itoa(i)})
+			}
+			if len(page.Buckets) == limit {
+				page.Links.Next = fmt.Sprintf("/api/v2/buckets?limit=%d&offset=%d&org=my-org", limit, offset+limit)
+			}
+			json.NewEncoder(w).Encode(page)
+		case "/api/v2/users":
+			list := &UserList{}
+			for i := 0; i < n; i++ {
+				list.Users = append(list.Users, User{Id: strconv.Itoa(i)})
+			}
+			json.NewEncoder(w).Encode(list)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestBucketIterator(t *testing.T) {
+	var requests int32
+	server := pagingServer(t, 250, &requests)
+	defer server.Close()
+	c, err := New(server.URL, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("all", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		it := c.BucketIterator(context.Background(), BucketFilter{Org: "my-org"})
+		i := 0
+		for it.Next() {
+			if id := it.Bucket().Id; id != strconv.Itoa(i) {
+				t.Fatalf("expected bucket %d, got %s", i, id)
+			}
+			i++
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if i != 250 {
+			t.Errorf("expected 250 buckets, got %d", i)
+		}
+		if r := atomic.LoadInt32(&requests); r != 3 {
+			t.Errorf("expected 3 requests, got %d", r)
+		}
+	})
+
+	t.Run("early termination", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		it := c.BucketIterator(context.Background(), BucketFilter{Org: "my-org", PageSize: 10})
+		for i := 0; i < 15 && it.Next(); i++ {
+		}
+		if r := atomic.LoadInt32(&requests); r != 2 {
+			t.Errorf("expected 2 requests, got %d", r)
+		}
+	})
+
+	t.Run("cap", func(t *testing.T) {
+		buckets, err := c.BucketIterator(context.Background(), BucketFilter{Org: "my-org"}).ListAll(120)
+		if err != ErrListCapReached {
+			t.Errorf("expected ErrListCapReached, got %v", err)
+		}
+		if len(buckets) != 120 {
+			t.Errorf("expected 120 buckets, got %d", len(buckets))
+		}
+		buckets, err = c.BucketIterator(context.Background(), BucketFilter{Org: "my-org"}).ListAll(250)
+		if err != nil || len(buckets) != 250 {
+			t.Errorf("expected 250 buckets, got %d, %v", len(buckets), err)
+		}
+	})
+}
+
+func TestUserIterator_serverIgnoresOffset(t *testing.T) {
+	for _, n := range []int{30, 100} {
+		var requests int32
+		server := pagingServer(t, n, &requests)
+		c, err := New(server.URL, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		users, err := c.UserIterator(context.Background(), UserFilter{}).ListAll(0)
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != n {
+			t.Errorf("expected %d users, got %d", n, len(users))
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaults for retrying the chunks of WriteParallel, unless set with WithParallelRetries
+const (
+	defaultParallelAttempts = 5
+	defaultParallelBackoff  = time.Second
+)
+
+// ParallelWriteResult is the outcome of WriteParallel.
+type ParallelWriteResult struct {
+	// Written is the number of metrics written, across all chunks.
+	Written int
+	// Chunks are the results of each chunk, in the order of the metrics.
+	Chunks []ChunkResult
+}
+
+// ChunkResult is the outcome of writing one chunk of the metrics passed to WriteParallel.
+type ChunkResult struct {
+	// Start and End are the indexes of the chunk's metrics, as in metrics[Start:End].
+	Start, End int
+	// Written is the number of the chunk's metrics written, leaving out those dropped with WithDropRejected.
+	Written int
+	// Consumed is the number of the chunk's metrics written or dropped, which are the first ones of the chunk,
+	// so metrics[Start+Consumed:End] are those left to write.
+	Consumed int
+	// Attempts is the number of times the chunk was tried, 0 if the write was canceled before it started.
+	Attempts int
+	// Err is why the chunk failed, if it did.
+	Err error
+}
+
+// Failed returns the results of the chunks which failed.
+func (r *ParallelWriteResult) Failed() []ChunkResult {
+	var failed []ChunkResult
+	for _, chunk := range r.Chunks {
+		if chunk.Err != nil {
+			failed = append(failed, chunk)
+		}
+	}
+	return failed
+}
+
+// ParallelWriteError is returned by WriteParallel when some of the chunks failed.
+// It unwraps to the error of the first chunk which failed, so errors.Is and errors.As see it.
+type ParallelWriteError struct {
+	// Chunks is the number of chunks written.
+	Chunks int
+	// Failed are the results of the chunks which failed, in the order of the metrics.
+	Failed []ChunkResult
+}
+
+func (e *ParallelWriteError) Error() string {
+	first := e.Failed[0]
+	return fmt.Sprintf("%d of %d chunks failed, the first of metrics %d to %d: %v", len(e.Failed), e.Chunks, first.Start, first.End, first.Err)
+}
+
+// Unwrap returns the error of the first chunk which failed.
+func (e *ParallelWriteError) Unwrap() error {
+	return e.Failed[0].Err
+}
+
+// WithParallelRetries returns an option for how WriteParallel retries a chunk which failed with an error IsRetryable reports:
+// up to maxAttempts times in all, waiting for the Retry-After of the error, or else attempt times backoff.
+// The default is 5 attempts, with a backoff of a second.
+func WithParallelRetries(maxAttempts int, backoff time.Duration) Option {
+	return Option{
+		name: "WithParallelRetries",
+		f: func(c *Client) error {
+			if maxAttempts < 1 {
+				return fmt.Errorf("WriteParallel needs at least 1 attempt, got %d", maxAttempts)
+			}
+			c.parallelAttempts = maxAttempts
+			c.parallelBackoff = backoff
+			return nil
+		},
+	}
+}
+
+// WriteParallel writes metrics to a bucket, and org, in chunks of chunkSize metrics, writing up to concurrency chunks at once.
+// It is meant for large batches, like backfilling history, which would be slow as the single request of Write.
+// Each chunk is encoded and written like a call to WriteMetrics, so it is split further to fit WithMaxBodyBytes,
+// and retried on its own, resuming after the metrics already written or dropped, as set with WithParallelRetries.
+// A chunk which fails doesn't stop the others, so the result has the outcome of every chunk,
+// and the error is a *ParallelWriteError if any failed. Canceling ctx stops starting chunks, and fails those not written.
+// opts apply to every chunk, as they would to WriteMetrics.
+func (c *Client) WriteParallel(ctx context.Context, bucket, org string, concurrency, chunkSize int, metrics []Metric, opts ...WriteOption) (*ParallelWriteResult, error) {
+	if concurrency < 1 {
+		return nil, fmt.Errorf("WriteParallel needs a concurrency of at least 1, got %d", concurrency)
+	}
+	if chunkSize < 1 {
+		return nil, fmt.Errorf("WriteParallel needs a chunk size of at least 1, got %d", chunkSize)
+	}
+	if _, err := c.writeOptions(opts); err != nil {
+		return nil, err
+	}
+
+	res := &ParallelWriteResult{Chunks: make([]ChunkResult, 0, (len(metrics)+chunkSize-1)/chunkSize)}
+	for start := 0; start < len(metrics); start += chunkSize {
+		end := start + chunkSize
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		res.Chunks = append(res.Chunks, ChunkResult{Start: start, End: end})
+	}
+	if concurrency > len(res.Chunks) {
+		concurrency = len(res.Chunks)
+	}
+
+	c.log(LevelDebug, "writing metrics in parallel", "op", "WriteParallel", "bucket", bucket, "org", org, "count", len(metrics), "chunks", len(res.Chunks), "concurrency", concurrency)
+
+	chunks := make(chan *ChunkResult)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				c.writeChunk(ctx, bucket, org, metrics, chunk, opts)
+			}
+		}()
+	}
+	// each result is only touched by the worker writing it, until they are all done
+	i := 0
+send:
+	for ; i < len(res.Chunks); i++ {
+		select {
+		case chunks <- &res.Chunks[i]:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(chunks)
+	wg.Wait()
+	for ; i < len(res.Chunks); i++ {
+		res.Chunks[i].Err = ctx.Err()
+	}
+
+	var failed []ChunkResult
+	for _, chunk := range res.Chunks {
+		res.Written += chunk.Written
+		if chunk.Err != nil {
+			failed = append(failed, chunk)
+		}
+	}
+	if len(failed) > 0 {
+		return res, &ParallelWriteError{Chunks: len(res.Chunks), Failed: failed}
+	}
+	return res, nil
+}
+
+// writeChunk writes the metrics of a chunk, retrying the ones not written yet while the error is retryable.
+func (c *Client) writeChunk(ctx context.Context, bucket, org string, metrics []Metric, chunk *ChunkResult, opts []WriteOption) {
+	attempts, backoff := c.parallelAttempts, c.parallelBackoff
+	if attempts == 0 {
+		attempts, backoff = defaultParallelAttempts, defaultParallelBackoff
+	}
+	for {
+		chunk.Attempts++
+		attemptCtx := ctx
+		if chunk.Attempts > 1 {
+			attemptCtx = withAttempt(ctx, chunk.Attempts)
+		}
+		n, consumed, err := c.writeMetricsConsumed(attemptCtx, bucket, org, metrics[chunk.Start+chunk.Consumed:chunk.End], opts)
+		chunk.Written += n
+		chunk.Consumed += consumed
+		chunk.Err = err
+		if err == nil || !IsRetryable(err) || chunk.Attempts >= attempts {
+			return
+		}
+
+		wait, ok := RetryAfter(err)
+		if !ok {
+			wait = time.Duration(chunk.Attempts) * backoff
+		}
+		c.log(LevelWarn, "retrying chunk", "op", "WriteParallel", "bucket", bucket, "org", org, "start", chunk.Start, "end", chunk.End, "attempt", chunk.Attempts, "wait", wait, "error", err)
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			chunk.Err = ctx.Err()
+			return
+		}
+	}
+}
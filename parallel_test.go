@@ -0,0 +1,263 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lancey-energy-storage/influxdb-client-go/influxdbtest"
+)
+
+// createDistinctRowMetrics returns count metrics with a timestamp a second apart, so the server stores each.
+func createDistinctRowMetrics(t *testing.T, count int) []Metric {
+	t.Helper()
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	metrics := make([]Metric, count)
+	for i := range metrics {
+		metrics[i] = NewRowMetric(map[string]interface{}{"value": i}, "backfill", map[string]string{"host": "a"}, start.Add(time.Duration(i)*time.Second))
+	}
+	return metrics
+}
+
+func TestClient_WriteParallel(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+	c, err := New(s.URL, setup.Token, WithParallelRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := createDistinctRowMetrics(t, 1050)
+	res, err := c.WriteParallel(context.Background(), "my-bucket", "my-org", 4, 100, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Written != 1050 || len(res.Chunks) != 11 {
+		t.Fatalf("expected 1050 metrics written in 11 chunks, got %d in %d", res.Written, len(res.Chunks))
+	}
+	if last := res.Chunks[10]; last.Start != 1000 || last.End != 1050 || last.Written != 50 || last.Attempts != 1 {
+		t.Errorf("unexpected result of the last chunk: %+v", last)
+	}
+	if n := s.Requests("/api/v2/write"); n != 11 {
+		t.Errorf("expected 11 requests, got %d", n)
+	}
+	if points := s.Points("my-org", "my-bucket"); len(points) != 1050 {
+		t.Errorf("expected 1050 points, got %d", len(points))
+	}
+}
+
+func TestClient_WriteParallel_options(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+	c, err := New(s.URL, setup.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := createDistinctRowMetrics(t, 20)
+	if _, err := c.WriteParallel(context.Background(), "my-bucket", "my-org", 2, 5, metrics, WithWritePrecision(time.Second), WithWriteDefaultTags(map[string]string{"source": "archive"})); err != nil {
+		t.Fatal(err)
+	}
+	points := s.Points("my-org", "my-bucket")
+	if len(points) != 20 {
+		t.Fatalf("expected 20 points, got %d", len(points))
+	}
+	for _, p := range points {
+		if p.Tags["source"] != "archive" {
+			t.Errorf("expected the default tag on every point, got %+v", p)
+			break
+		}
+	}
+
+	if _, err := c.WriteParallel(context.Background(), "my-bucket", "my-org", 2, 5, metrics, WithWritePrecision(time.Minute)); err == nil {
+		t.Error("expected an unsupported precision to fail")
+	}
+	if n := s.Requests("/api/v2/write"); n != 4 {
+		t.Errorf("expected 4 requests, got %d", n)
+	}
+}
+
+func TestClient_WriteParallel_retries(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+	var (
+		mu         sync.Mutex
+		retries    int
+		maxAttempt int
+	)
+	c, err := New(s.URL, setup.Token, WithParallelRetries(3, time.Millisecond), WithInstrumentation(InstrumentationFunc(func(e RequestEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if e.Attempt > 1 {
+			retries++
+		}
+		if e.Attempt > maxAttempt {
+			maxAttempt = e.Attempt
+		}
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := createDistinctRowMetrics(t, 50)
+
+	// retryable failures are retried, up to the attempts of each chunk
+	s.Fail(influxdbtest.Unavailable("/api/v2/write", 3))
+	res, err := c.WriteParallel(context.Background(), "my-bucket", "my-org", 2, 10, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	for _, chunk := range res.Chunks {
+		attempts += chunk.Attempts
+	}
+	if res.Written != 50 || attempts != 8 {
+		t.Errorf("expected 50 metrics written in 8 attempts, got %d in %d", res.Written, attempts)
+	}
+	// the retries are instrumented as such
+	mu.Lock()
+	if retries != 3 || maxAttempt < 2 {
+		t.Errorf("expected 3 requests instrumented as retries, got %d up to attempt %d", retries, maxAttempt)
+	}
+	mu.Unlock()
+
+	// other failures fail their chunk only
+	s.Fail(influxdbtest.Failure{Path: "/api/v2/write", StatusCode: http.StatusInternalServerError, Count: 1})
+	res, err = c.WriteParallel(context.Background(), "my-bucket", "my-org", 1, 10, metrics)
+	var perr *ParallelWriteError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a *ParallelWriteError, got %v", err)
+	}
+	failed := res.Failed()
+	if res.Written != 40 || len(failed) != 1 || len(perr.Failed) != 1 || failed[0].Start != 0 || failed[0].Attempts != 1 {
+		t.Errorf("expected the first chunk to fail once, got %d written and %+v", res.Written, failed)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected the error to unwrap to the chunk's, got %v", err)
+	}
+
+	// retries give up after the last attempt
+	s.Fail(influxdbtest.RateLimited("/api/v2/write", 0, 3))
+	res, err = c.WriteParallel(context.Background(), "my-bucket", "my-org", 1, 50, metrics)
+	if !errors.Is(err, ErrRateLimited) || res.Chunks[0].Attempts != 3 || res.Written != 0 {
+		t.Errorf("expected the chunk to fail after 3 attempts, got %+v, %v", res.Chunks[0], err)
+	}
+}
+
+func TestClient_WriteParallel_dropRejected(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+		written  []string
+		rejected int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		data, _ := ioutil.ReadAll(r.Body)
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		// the request after the first metric was dropped fails once
+		if requests == 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var messages []string
+		for i, line := range lines {
+			if strings.Contains(line, "bad") {
+				rejected++
+				messages = append(messages, fmt.Sprintf("unable to parse '%s': bad value %d", line, i+1))
+			}
+		}
+		if len(messages) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":"invalid","message":%q}`, strings.Join(messages, "\n"))
+			return
+		}
+		written = append(written, lines...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	metrics := make([]Metric, 6)
+	for i := range metrics {
+		value := "good"
+		if i == 1 {
+			value = "bad!"
+		}
+		metrics[i] = NewRowMetric(map[string]interface{}{"value": value}, "m", nil, time.Unix(int64(i+1), 0))
+	}
+	line := len("m value=\"good\" 1000000000\n")
+	c, err := New(server.URL, "foo", WithNoCompression(), WithMaxBodyBytes(2*line), WithParallelRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// metrics 0 and 1 are sent, 1 is dropped and 0 written, then 2 and 3 fail and are retried
+	res, err := c.WriteParallel(context.Background(), "bucket", "org", 1, 6, metrics, WithDropRejected())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := res.Chunks[0]
+	if chunk.Written != 5 || chunk.Consumed != 6 || chunk.Attempts != 2 || res.Written != 5 {
+		t.Errorf("expected 5 metrics written in 2 attempts, got %+v", chunk)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(written) != 5 || rejected != 1 {
+		t.Errorf("expected each metric to be sent once after it was stored or rejected, got %d written and %d rejected", len(written), rejected)
+	}
+}
+
+func TestClient_WriteParallel_canceled(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+	c, err := New(s.URL, setup.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := c.WriteParallel(ctx, "my-bucket", "my-org", 2, 10, createDistinctRowMetrics(t, 50))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the write to be canceled, got %v", err)
+	}
+	if res.Written != 0 || len(res.Failed()) != 5 {
+		t.Errorf("expected all 5 chunks to fail, got %d written and %d failed", res.Written, len(res.Failed()))
+	}
+
+	// canceling while waiting to retry fails the chunk
+	c, err = New(s.URL, setup.Token, WithParallelRetries(3, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Fail(influxdbtest.Unavailable("/api/v2/write", 1))
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	res, err = c.WriteParallel(ctx, "my-bucket", "my-org", 1, 10, createDistinctRowMetrics(t, 10))
+	if !errors.Is(err, context.Canceled) || res.Chunks[0].Attempts != 1 {
+		t.Errorf("expected the chunk to be canceled after 1 attempt, got %+v, %v", res.Chunks[0], err)
+	}
+
+	for _, args := range [][2]int{{0, 10}, {2, 0}} {
+		if _, err := c.WriteParallel(context.Background(), "my-bucket", "my-org", args[0], args[1], nil); err == nil {
+			t.Errorf("expected a concurrency of %d and chunk size of %d to fail", args[0], args[1])
+		}
+	}
+	if _, err := New(s.URL, setup.Token, WithParallelRetries(0, time.Second)); err == nil {
+		t.Error("expected 0 attempts to fail")
+	}
+}
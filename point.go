@@ -0,0 +1,287 @@
+package influxdb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	lp "github.com/influxdata/line-protocol"
+)
+
+// Point is a Metric made by a PointBuilder.
+// It holds its tags and fields by value, sorted by key, so the client encodes it without allocating.
+// Points can't be changed once built, so they are safe to buffer, as the writer package does.
+type Point struct {
+	name    string
+	entries []pointEntry // the tags, then the fields
+	nTags   int
+	ts      time.Time
+}
+
+// Name returns the measurement of the point.
+func (p *Point) Name() string {
+	return p.name
+}
+
+// Time returns the timestamp of the point.
+func (p *Point) Time() time.Time {
+	return p.ts
+}
+
+// TagList returns the tags of the point. Unlike encoding the point, it allocates.
+func (p *Point) TagList() []*lp.Tag {
+	if p.nTags == 0 {
+		return nil
+	}
+	tags := make([]lp.Tag, p.nTags)
+	list := make([]*lp.Tag, p.nTags)
+	for i, e := range p.entries[:p.nTags] {
+		tags[i] = lp.Tag{Key: e.key, Value: e.str}
+		list[i] = &tags[i]
+	}
+	return list
+}
+
+// FieldList returns the fields of the point. Unlike encoding the point, it allocates.
+func (p *Point) FieldList() []*lp.Field {
+	entries := p.entries[p.nTags:]
+	fields := make([]lp.Field, len(entries))
+	list := make([]*lp.Field, len(entries))
+	for i := range entries {
+		fields[i] = lp.Field{Key: entries[i].key, Value: entries[i].value()}
+		list[i] = &fields[i]
+	}
+	return list
+}
+
+// hasUint reports whether the point has an unsigned integer field.
+func (p *Point) hasUint() bool {
+	for _, e := range p.entries[p.nTags:] {
+		if e.kind == kindUint {
+			return true
+		}
+	}
+	return false
+}
+
+type entryKind uint8
+
+const (
+	kindString entryKind = iota
+	kindFloat
+	kindInt
+	kindUint
+	kindBool
+)
+
+// pointEntry is a tag or field of a Point, with its value stored by kind, so it needn't be boxed in an interface.
+type pointEntry struct {
+	key  string
+	kind entryKind
+	num  uint64 // the bits of a float, an int, a uint, or 1 for true
+	str  string
+}
+
+func (e *pointEntry) value() interface{} {
+	switch e.kind {
+	case kindFloat:
+		return math.Float64frombits(e.num)
+	case kindInt:
+		return int64(e.num)
+	case kindUint:
+		return e.num
+	case kindBool:
+		return e.num == 1
+	}
+	return e.str
+}
+
+func (e *pointEntry) appendValue(dst []byte) ([]byte, error) {
+	switch e.kind {
+	case kindFloat:
+		return appendFloat(dst, math.Float64frombits(e.num))
+	case kindInt:
+		return append(strconv.AppendInt(dst, int64(e.num), 10), 'i'), nil
+	case kindUint:
+		return append(strconv.AppendUint(dst, e.num, 10), 'u'), nil
+	case kindBool:
+		return strconv.AppendBool(dst, e.num == 1), nil
+	}
+	return appendString(dst, e.str), nil
+}
+
+var pointBuilders = sync.Pool{
+	New: func() interface{} { return &PointBuilder{} },
+}
+
+// PointBuilder builds Points, keeping its buffers from one to the next.
+// Unlike NewRowMetric, it takes fields by type, and reports unsupported values as an error instead of panicking:
+//
+//	b := influxdb.NewPointBuilder()
+//	defer b.Release()
+//	for _, cell := range cells {
+//		p, err := b.Measurement("cells").Tag("site", site).Float("voltage", cell.Voltage).Time(now).Point()
+//		...
+//	}
+//
+// A PointBuilder is not safe for concurrent use.
+type PointBuilder struct {
+	name   string
+	ts     time.Time
+	tags   []pointEntry // sorted by key
+	fields []pointEntry // sorted by key
+	err    error
+}
+
+// NewPointBuilder returns a PointBuilder from a pool. Release it once done with it.
+func NewPointBuilder() *PointBuilder {
+	return pointBuilders.Get().(*PointBuilder)
+}
+
+// Release resets the builder, and returns it to the pool. It must not be used afterwards.
+func (b *PointBuilder) Release() {
+	b.Reset()
+	pointBuilders.Put(b)
+}
+
+// Reset clears the point being built.
+func (b *PointBuilder) Reset() {
+	// clear the entries, so the pool doesn't keep their strings alive
+	for i := range b.tags {
+		b.tags[i] = pointEntry{}
+	}
+	for i := range b.fields {
+		b.fields[i] = pointEntry{}
+	}
+	*b = PointBuilder{tags: b.tags[:0], fields: b.fields[:0]}
+}
+
+// Measurement sets the measurement of the point.
+func (b *PointBuilder) Measurement(name string) *PointBuilder {
+	b.name = name
+	return b
+}
+
+// Time sets the timestamp of the point.
+func (b *PointBuilder) Time(ts time.Time) *PointBuilder {
+	b.ts = ts
+	return b
+}
+
+// Tag sets a tag, replacing any with the same key.
+func (b *PointBuilder) Tag(key, value string) *PointBuilder {
+	b.tags = insertEntry(b.tags, pointEntry{key: key, kind: kindString, str: value})
+	return b
+}
+
+// Float sets a float field, replacing any with the same key.
+func (b *PointBuilder) Float(key string, value float64) *PointBuilder {
+	return b.field(pointEntry{key: key, kind: kindFloat, num: math.Float64bits(value)})
+}
+
+// Int sets an integer field, replacing any with the same key.
+func (b *PointBuilder) Int(key string, value int64) *PointBuilder {
+	return b.field(pointEntry{key: key, kind: kindInt, num: uint64(value)})
+}
+
+// Uint sets an unsigned integer field, replacing any with the same key.
+func (b *PointBuilder) Uint(key string, value uint64) *PointBuilder {
+	return b.field(pointEntry{key: key, kind: kindUint, num: value})
+}
+
+// Bool sets a boolean field, replacing any with the same key.
+func (b *PointBuilder) Bool(key string, value bool) *PointBuilder {
+	e := pointEntry{key: key, kind: kindBool}
+	if value {
+		e.num = 1
+	}
+	return b.field(e)
+}
+
+// String sets a string field, replacing any with the same key.
+func (b *PointBuilder) String(key, value string) *PointBuilder {
+	return b.field(pointEntry{key: key, kind: kindString, str: value})
+}
+
+// Field sets a field of any of the types NewRowMetric takes, replacing any with the same key.
+// A value of another type makes Point return an error.
+func (b *PointBuilder) Field(key string, value interface{}) *PointBuilder {
+	switch v := value.(type) {
+	case float64:
+		return b.Float(key, v)
+	case float32:
+		return b.Float(key, float64(v))
+	case int:
+		return b.Int(key, int64(v))
+	case int8:
+		return b.Int(key, int64(v))
+	case int16:
+		return b.Int(key, int64(v))
+	case int32:
+		return b.Int(key, int64(v))
+	case int64:
+		return b.Int(key, v)
+	case uint:
+		return b.Uint(key, uint64(v))
+	case uint8:
+		return b.Uint(key, uint64(v))
+	case uint16:
+		return b.Uint(key, uint64(v))
+	case uint32:
+		return b.Uint(key, uint64(v))
+	case uint64:
+		return b.Uint(key, v)
+	case bool:
+		return b.Bool(key, v)
+	case string:
+		return b.String(key, v)
+	case []byte:
+		return b.String(key, string(v))
+	}
+	if b.err == nil {
+		b.err = fmt.Errorf("field %q has unsupported type %T", key, value)
+	}
+	return b
+}
+
+func (b *PointBuilder) field(e pointEntry) *PointBuilder {
+	b.fields = insertEntry(b.fields, e)
+	return b
+}
+
+// Point returns the point built, and resets the builder for the next one.
+func (b *PointBuilder) Point() (*Point, error) {
+	if err := b.err; err != nil {
+		b.Reset()
+		return nil, err
+	}
+	p := &Point{
+		name:    b.name,
+		entries: make([]pointEntry, len(b.tags)+len(b.fields)),
+		nTags:   len(b.tags),
+		ts:      b.ts,
+	}
+	copy(p.entries, b.tags)
+	copy(p.entries[len(b.tags):], b.fields)
+	b.Reset()
+	return p, nil
+}
+
+// insertEntry inserts e into entries, sorted by key, replacing any with the same key.
+// It searches from the end, as entries are mostly added in order.
+func insertEntry(entries []pointEntry, e pointEntry) []pointEntry {
+	i := len(entries)
+	for i > 0 && entries[i-1].key > e.key {
+		i--
+	}
+	if i > 0 && entries[i-1].key == e.key {
+		entries[i-1] = e
+		return entries
+	}
+	entries = append(entries, pointEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = e
+	return entries
+}
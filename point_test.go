@@ -0,0 +1,118 @@
+package influxdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cmp "github.com/google/go-cmp/cmp"
+	"github.com/lancey-energy-storage/influxdb-client-go/influxdbtest"
+)
+
+func mustPoint(t testing.TB, b *PointBuilder) *Point {
+	t.Helper()
+	p, err := b.Point()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPointBuilder(t *testing.T) {
+	ts := time.Date(2019, 5, 2, 16, 12, 41, 0, time.UTC)
+	b := NewPointBuilder()
+	defer b.Release()
+
+	p := mustPoint(t, b.Measurement("cells").
+		Tag("site", "a").Tag("rack", "3").Tag("site", "b").
+		Float("voltage", 3.3).Int("soc", 87).Uint("cycles", 7).Bool("ok", true).String("status", "fine").
+		Field("temp", float32(21.5)).Field("voltage", 3.4).
+		Time(ts))
+
+	want := NewRowMetric(map[string]interface{}{
+		"voltage": 3.4, "soc": 87, "cycles": uint64(7), "ok": true, "status": "fine", "temp": float32(21.5),
+	}, "cells", map[string]string{"site": "b", "rack": "3"}, ts)
+	if p.Name() != want.Name() || !p.Time().Equal(want.Time()) {
+		t.Errorf("expected %s at %s, got %s at %s", want.Name(), want.Time(), p.Name(), p.Time())
+	}
+	if diff := cmp.Diff(want.TagList(), p.TagList()); diff != "" {
+		t.Errorf("unexpected tags: %s", diff)
+	}
+	if diff := cmp.Diff(want.FieldList(), p.FieldList()); diff != "" {
+		t.Errorf("unexpected fields: %s", diff)
+	}
+
+	// the builder is reset for the next point
+	p = mustPoint(t, b.Measurement("other").Int("v", 1))
+	if len(p.TagList()) != 0 || len(p.FieldList()) != 1 || !p.Time().IsZero() {
+		t.Errorf("expected a point with only a field, got %v %v %s", p.TagList(), p.FieldList(), p.Time())
+	}
+
+	if _, err := b.Measurement("m").Field("v", struct{}{}).Int("w", 1).Point(); err == nil {
+		t.Error("expected an unsupported field type to fail")
+	}
+	if p := mustPoint(t, b.Measurement("m").Int("w", 1)); len(p.FieldList()) != 1 {
+		t.Errorf("expected the error to be reset, got %v", p.FieldList())
+	}
+}
+
+func TestClient_Write_points(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+	c, err := New(s.URL, setup.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Date(2019, 5, 2, 16, 12, 41, 0, time.UTC)
+	b := NewPointBuilder()
+	defer b.Release()
+	var metrics []Metric
+	for i := 0; i < 3; i++ {
+		metrics = append(metrics, mustPoint(t, b.Measurement("cells").Tag("cell", string(rune('a'+i))).Uint("cycles", uint64(i)).Time(ts)))
+	}
+	if n, err := c.Write(context.Background(), "my-bucket", "my-org", metrics...); err != nil || n != 3 {
+		t.Fatalf("expected 3 points written, got %d, %v", n, err)
+	}
+	points := s.Points("my-org", "my-bucket")
+	if len(points) != 3 {
+		t.Fatalf("expected 3 points, got %d", len(points))
+	}
+	if v := points[2].Fields["cycles"]; v != uint64(2) {
+		t.Errorf("expected cycles=2u, got %v", points[2].Fields)
+	}
+}
+
+func BenchmarkPointBuilder(b *testing.B) {
+	ts := time.Now()
+	pb := NewPointBuilder()
+	defer pb.Release()
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p, err := pb.Measurement("cells").Tag("site", "a").Tag("rack", "3").
+			Float("voltage", 3.3).Float("current", 1.2).Int("soc", 87).String("status", "ok").
+			Time(ts).Point()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if buf, err = AppendMetric(buf[:0], p, time.Nanosecond); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewRowMetric(b *testing.B) {
+	ts := time.Now()
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := NewRowMetric(map[string]interface{}{"voltage": 3.3, "current": 1.2, "soc": int64(87), "status": "ok"},
+			"cells", map[string]string{"site": "a", "rack": "3"}, ts)
+		var err error
+		if buf, err = AppendMetric(buf[:0], m, time.Nanosecond); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
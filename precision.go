@@ -0,0 +1,101 @@
+package influxdb
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"time"
+
+	lp "github.com/influxdata/line-protocol"
+)
+
+// precisions are the write precisions the server accepts, by the value of their precision parameter.
+var precisions = map[time.Duration]string{
+	time.Nanosecond:  "ns",
+	time.Microsecond: "us",
+	time.Millisecond: "ms",
+	time.Second:      "s",
+}
+
+// encoder encodes metrics as line protocol, with timestamps in units of precision.
+type encoder struct {
+	w         io.Writer
+	e         *lp.Encoder
+	precision time.Duration
+	// maxLineBytes splits metrics into several lines, each no longer than it, if it isn't zero.
+	// Encode returns lp.ErrNeedMoreSpace if a single field doesn't fit.
+	maxLineBytes int
+
+	buf  bytes.Buffer // the lines without timestamps, when not using nanoseconds
+	line []byte
+}
+
+func newEncoder(w io.Writer, precision time.Duration, failOnFieldErr bool) *encoder {
+	enc := &encoder{w: w, precision: precision}
+	if precision == time.Nanosecond {
+		enc.e = lp.NewEncoder(w)
+	} else {
+		enc.e = lp.NewEncoder(&enc.buf)
+	}
+	enc.e.SetFieldTypeSupport(lp.UintSupport)
+	enc.e.FailOnFieldErr(failOnFieldErr)
+	return enc
+}
+
+// Encode writes m, truncating its timestamp to the precision.
+func (enc *encoder) Encode(m Metric) (int, error) {
+	if enc.precision == time.Nanosecond {
+		enc.e.SetMaxLineBytes(enc.maxLineBytes)
+		return enc.e.Encode(m)
+	}
+
+	// The line protocol encoder only writes nanoseconds, so encode without the timestamp,
+	// and append it to each line: the encoder escapes newlines, so every newline ends a line.
+	var ts []byte
+	if t := m.Time(); !t.IsZero() {
+		ts = strconv.AppendInt([]byte{' '}, truncateTime(t, enc.precision), 10)
+	}
+	if enc.maxLineBytes > 0 {
+		// leave room for the timestamp
+		if enc.maxLineBytes <= len(ts) {
+			return 0, lp.ErrNeedMoreSpace
+		}
+		enc.e.SetMaxLineBytes(enc.maxLineBytes - len(ts))
+	}
+	enc.buf.Reset()
+	if _, err := enc.e.Encode(untimedMetric{m}); err != nil {
+		return 0, err
+	}
+	if ts == nil {
+		return enc.w.Write(enc.buf.Bytes())
+	}
+
+	enc.line = enc.line[:0]
+	for data := enc.buf.Bytes(); len(data) > 0; {
+		i := bytes.IndexByte(data, '\n')
+		enc.line = append(enc.line, data[:i]...)
+		enc.line = append(enc.line, ts...)
+		enc.line = append(enc.line, '\n')
+		data = data[i+1:]
+	}
+	return enc.w.Write(enc.line)
+}
+
+// truncateTime returns t in units of precision since the epoch, rounded down.
+func truncateTime(t time.Time, precision time.Duration) int64 {
+	ns := t.UnixNano()
+	ts := ns / int64(precision)
+	if ns%int64(precision) < 0 {
+		ts--
+	}
+	return ts
+}
+
+// untimedMetric is a metric without a timestamp.
+type untimedMetric struct {
+	Metric
+}
+
+func (untimedMetric) Time() time.Time {
+	return time.Time{}
+}
@@ -0,0 +1,77 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lancey-energy-storage/influxdb-client-go/influxdbtest"
+)
+
+func TestEncoder_precision(t *testing.T) {
+	ts := time.Date(2019, 5, 2, 16, 12, 41, 98765432, time.UTC)
+	tests := []struct {
+		precision time.Duration
+		ts        time.Time
+		want      string
+	}{
+		{precision: time.Nanosecond, ts: ts, want: "cpu,host=a usage=0.5 1556813561098765432\n"},
+		{precision: time.Microsecond, ts: ts, want: "cpu,host=a usage=0.5 1556813561098765\n"},
+		{precision: time.Millisecond, ts: ts, want: "cpu,host=a usage=0.5 1556813561098\n"},
+		{precision: time.Second, ts: ts, want: "cpu,host=a usage=0.5 1556813561\n"},
+		{precision: time.Second, ts: time.Unix(-1, 500), want: "cpu,host=a usage=0.5 -1\n"},
+		{precision: time.Second, ts: time.Unix(0, -500), want: "cpu,host=a usage=0.5 -1\n"},
+		{precision: time.Second, want: "cpu,host=a usage=0.5\n"},
+	}
+	for _, tt := range tests {
+		buf := &bytes.Buffer{}
+		e := newEncoder(buf, tt.precision, true)
+		m := NewRowMetric(map[string]interface{}{"usage": 0.5}, "cpu", map[string]string{"host": "a"}, tt.ts)
+		if _, err := e.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("Encode() at precision %s = %q, want %q", tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestClient_WriteMetrics_precision(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+
+	if _, err := New(s.URL, setup.Token, WithPrecision(time.Minute)); err == nil {
+		t.Error("expected an unsupported precision to fail")
+	}
+	c, err := New(s.URL, setup.Token, WithPrecision(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	ts := time.Date(2019, 5, 2, 16, 12, 41, 98765432, time.UTC)
+	m := NewRowMetric(map[string]interface{}{"usage": 0.5}, "cpu", map[string]string{"host": "a"}, ts)
+	if _, err := c.Write(ctx, "my-bucket", "my-org", m); err != nil {
+		t.Fatal(err)
+	}
+	m = NewRowMetric(map[string]interface{}{"usage": 0.5}, "cpu", map[string]string{"host": "b"}, ts)
+	if _, err := c.WriteMetrics(ctx, "my-bucket", "my-org", []Metric{m}, WithWritePrecision(time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.WriteMetrics(ctx, "my-bucket", "my-org", []Metric{m}, WithWritePrecision(time.Hour)); err == nil {
+		t.Error("expected an unsupported precision to fail")
+	}
+
+	points := s.Points("my-org", "my-bucket")
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+	if want := ts.Truncate(time.Second); !points[0].Time.Equal(want) {
+		t.Errorf("expected %s, got %s", want, points[0].Time)
+	}
+	if want := ts.Truncate(time.Millisecond); !points[1].Time.Equal(want) {
+		t.Errorf("expected %s, got %s", want, points[1].Time)
+	}
+}
@@ -2,14 +2,12 @@ package influxdb
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
@@ -50,6 +48,7 @@ type dialect struct {
 // QueryCSV returns the result of a flux query.
 // TODO: annotations
 func (c *Client) QueryCSV(ctx context.Context, flux string, org string, extern ...interface{}) (*QueryCSVResult, error) {
+	c.log(LevelDebug, "querying", "op", "QueryCSV", "org", org)
 	qURL, err := c.makeQueryURL(org)
 	if err != nil {
 		return nil, err
@@ -77,10 +76,9 @@ func (c *Client) QueryCSV(ctx context.Context, flux string, org string, extern .
 
 	req = req.WithContext(ctx)
 	req.Header.Set("User-Agent", c.userAgent)
-	req.Header.Set("Authorization", c.authorization)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept-Encoding", "gzip")
-	resp, err := c.httpClient.Do(req)
+	req.Header.Set("Accept-Encoding", acceptEncoding())
+	resp, err := c.do("QueryCSV", req)
 	if err != nil {
 		return nil, err
 	}
@@ -90,28 +88,12 @@ func (c *Client) QueryCSV(ctx context.Context, flux string, org string, extern .
 	}
 	defer func() { cleanup() }()
 
-	switch resp.Header.Get("Content-Encoding") {
-	case "gzip":
-		resp.Body, err = gzip.NewReader(resp.Body)
-		if err != nil {
-			return nil, err
-		}
+	if err := decompressResponse(resp); err != nil {
+		return nil, err
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		r := io.LimitReader(resp.Body, 1<<14) // only support errors that are 16kB long, more than that and something is probably wrong.
-		gerr := &Error{Code: resp.Status}
-		if resp.ContentLength != 0 {
-			if err := json.NewDecoder(r).Decode(&gerr); err != nil {
-				gerr.Code = resp.Status
-				message, err := ioutil.ReadAll(r)
-				if err != nil {
-					return nil, err
-				}
-				gerr.Message = string(message)
-			}
-		}
-		return nil, gerr
+		return nil, newResponseError("QueryCSV", resp, resp.Body)
 	}
 	cleanup = func() {} // we don't want to close the body if we got a status code in the 2xx range.
 	csvReader := csv.NewReader(resp.Body)
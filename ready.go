@@ -2,29 +2,21 @@ package influxdb
 
 import (
 	"context"
-	"encoding/json"
-	"log"
 	"net/http"
-	"net/url"
 )
 
+// Ready returns whether the server is ready to serve requests.
 func (c *Client) Ready(ctx context.Context) (*ReadyResult, error) {
-	log.Printf("[DEBUG] Pinging resource ")
-	pingUrl, _ := url.Parse(c.url.String())
-	pingUrl.Path = "/ready"
+	c.log(LevelDebug, "checking readiness", "op", "Ready")
 
-	req, err := http.NewRequest(http.MethodGet, pingUrl.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	req = req.WithContext(ctx)
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
 	readyResult := &ReadyResult{}
-
-	if err := json.NewDecoder(resp.Body).Decode(readyResult); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "Ready",
+		method: http.MethodGet,
+		path:   "/ready",
+		noAuth: true,
+	}, readyResult)
+	if err != nil {
 		return nil, err
 	}
 	return readyResult, nil
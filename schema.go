@@ -0,0 +1,480 @@
+package influxdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	lp "github.com/influxdata/line-protocol"
+)
+
+// FieldType is the type of the values of a field, named as InfluxDB names them.
+type FieldType string
+
+// The types of field values.
+const (
+	FieldFloat    FieldType = "float"
+	FieldInteger  FieldType = "integer"
+	FieldUnsigned FieldType = "unsigned"
+	FieldString   FieldType = "string"
+	FieldBoolean  FieldType = "boolean"
+)
+
+func (t FieldType) valid() bool {
+	switch t {
+	case FieldFloat, FieldInteger, FieldUnsigned, FieldString, FieldBoolean:
+		return true
+	}
+	return false
+}
+
+// fieldTypeOf returns the type a field value is written as, if it is one line protocol can encode.
+func fieldTypeOf(v interface{}) (FieldType, bool) {
+	switch v.(type) {
+	case float64, float32:
+		return FieldFloat, true
+	case int64, int, int32, int16, int8:
+		return FieldInteger, true
+	case uint64, uint, uint32, uint16, uint8:
+		return FieldUnsigned, true
+	case string, []byte:
+		return FieldString, true
+	case bool:
+		return FieldBoolean, true
+	}
+	return "", false
+}
+
+// MeasurementSchema is the tag keys and field types of a measurement.
+type MeasurementSchema struct {
+	// Tags are the tag keys, sorted.
+	Tags []string `json:"tags"`
+	// Fields are the types of the fields, by key.
+	Fields map[string]FieldType `json:"fields"`
+	// Declared is set for schemas declared rather than learned.
+	// Metrics with tags or fields a declared schema doesn't have conflict with it,
+	// while a learned schema learns them.
+	Declared bool `json:"declared,omitempty"`
+}
+
+// SchemaPolicy is what a write does with metrics conflicting with the schema of their measurement.
+type SchemaPolicy int
+
+const (
+	// SchemaReject fails writes with conflicting metrics before sending them, with a *WriteError listing them.
+	// With WithDropRejected, the client writes the rest of the metrics.
+	SchemaReject SchemaPolicy = iota
+	// SchemaCoerce converts field values to the type in the schema when nothing is lost, like 1 to 1.0,
+	// and rejects the metrics it can't convert like SchemaReject.
+	SchemaCoerce
+	// SchemaReport writes conflicting metrics as they are, only passing the conflicts to the registry's OnConflict.
+	SchemaReport
+)
+
+// SchemaConflict is a tag or field of a metric conflicting with the schema of its measurement.
+type SchemaConflict struct {
+	// Index is the index of the metric in the metrics checked.
+	Index int
+	// Measurement and Key are the name of the metric, and the key of the tag or field.
+	Measurement string
+	Key         string
+	// Reason is how the tag or field conflicts, like `field "soc" is integer, not float`.
+	Reason string
+	// Coerced is set if the field's value was converted to the type in the schema, with SchemaCoerce.
+	Coerced bool
+}
+
+// Error returns the measurement and reason of the conflict.
+func (c SchemaConflict) Error() string {
+	return fmt.Sprintf("measurement %q: %s", c.Measurement, c.Reason)
+}
+
+// SchemaRegistry holds the schemas of measurements, declared up front or learned from the first metric checked for each,
+// so writes can catch metrics the server would reject, like a field written as an integer after a float, before sending them.
+// Pass it to WithSchema, or to the writer package's WithSchema. It is safe to use concurrently, and to share.
+type SchemaRegistry struct {
+	mu           sync.Mutex
+	measurements map[string]*measurementSchema
+	onConflict   func(SchemaConflict)
+}
+
+type measurementSchema struct {
+	tags     map[string]struct{}
+	fields   map[string]FieldType
+	declared bool
+}
+
+// NewSchemaRegistry returns an empty registry, which learns the schema of each measurement not declared with Declare.
+// The zero SchemaRegistry is empty too, ready to load with UnmarshalJSON.
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{measurements: map[string]*measurementSchema{}}
+}
+
+// OnConflict sets a function called with each conflict found, whatever the policy. It must be safe to call concurrently, and not use the registry.
+func (r *SchemaRegistry) OnConflict(f func(SchemaConflict)) {
+	r.mu.Lock()
+	r.onConflict = f
+	r.mu.Unlock()
+}
+
+// Declare sets the schema of a measurement, replacing any declared or learned before.
+// Metrics with tags or fields it doesn't have conflict with it.
+func (r *SchemaRegistry) Declare(measurement string, schema MeasurementSchema) error {
+	s, err := newMeasurementSchema(measurement, schema)
+	if err != nil {
+		return err
+	}
+	s.declared = true
+	r.mu.Lock()
+	if r.measurements == nil {
+		r.measurements = map[string]*measurementSchema{}
+	}
+	r.measurements[measurement] = s
+	r.mu.Unlock()
+	return nil
+}
+
+func newMeasurementSchema(measurement string, schema MeasurementSchema) (*measurementSchema, error) {
+	s := &measurementSchema{tags: make(map[string]struct{}, len(schema.Tags)), fields: make(map[string]FieldType, len(schema.Fields)), declared: schema.Declared}
+	for _, key := range schema.Tags {
+		s.tags[key] = struct{}{}
+	}
+	for key, typ := range schema.Fields {
+		if !typ.valid() {
+			return nil, fmt.Errorf("field %q of measurement %q has an unknown type %q", key, measurement, typ)
+		}
+		if _, ok := s.tags[key]; ok {
+			return nil, fmt.Errorf("%q of measurement %q is both a tag and a field", key, measurement)
+		}
+		s.fields[key] = typ
+	}
+	return s, nil
+}
+
+// Schema returns the schema of a measurement, if it was declared or learned.
+func (r *SchemaRegistry) Schema(measurement string) (MeasurementSchema, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.measurements[measurement]
+	if !ok {
+		return MeasurementSchema{}, false
+	}
+	return s.export(), true
+}
+
+func (s *measurementSchema) export() MeasurementSchema {
+	schema := MeasurementSchema{Tags: make([]string, 0, len(s.tags)), Fields: make(map[string]FieldType, len(s.fields)), Declared: s.declared}
+	for key := range s.tags {
+		schema.Tags = append(schema.Tags, key)
+	}
+	sort.Strings(schema.Tags)
+	for key, typ := range s.fields {
+		schema.Fields[key] = typ
+	}
+	return schema
+}
+
+// MarshalJSON returns the schemas of the registry as a JSON object of MeasurementSchema by measurement,
+// so learned schemas can be reviewed, and loaded later with UnmarshalJSON.
+func (r *SchemaRegistry) MarshalJSON() ([]byte, error) {
+	r.mu.Lock()
+	schemas := make(map[string]MeasurementSchema, len(r.measurements))
+	for measurement, s := range r.measurements {
+		schemas[measurement] = s.export()
+	}
+	r.mu.Unlock()
+	return json.Marshal(schemas)
+}
+
+// UnmarshalJSON replaces the schemas of the registry with ones in the format of MarshalJSON.
+// Schemas keep whether they were declared, so learned ones go on learning.
+func (r *SchemaRegistry) UnmarshalJSON(data []byte) error {
+	var schemas map[string]MeasurementSchema
+	if err := json.Unmarshal(data, &schemas); err != nil {
+		return err
+	}
+	measurements := make(map[string]*measurementSchema, len(schemas))
+	for measurement, schema := range schemas {
+		s, err := newMeasurementSchema(measurement, schema)
+		if err != nil {
+			return err
+		}
+		measurements[measurement] = s
+	}
+	r.mu.Lock()
+	r.measurements = measurements
+	r.mu.Unlock()
+	return nil
+}
+
+// Check checks metrics against the schemas of their measurements, learning the schemas of measurements it hasn't seen,
+// and the new tags and fields of learned ones, from metrics which don't conflict.
+// It returns the metrics to write under policy: all of them with SchemaReport, otherwise the ones which don't conflict,
+// with values converted with SchemaCoerce, and a *WriteError matching ErrConflict listing the rest.
+// The metrics passed in aren't changed.
+func (r *SchemaRegistry) Check(m []Metric, policy SchemaPolicy) ([]Metric, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var (
+		checked  = m
+		changed  bool
+		rejected []RejectedMetric
+	)
+	for i := range m {
+		coerced, conflicts := r.check(i, m[i], policy == SchemaCoerce)
+		if r.onConflict != nil {
+			for _, conflict := range conflicts {
+				r.onConflict(conflict)
+			}
+		}
+		if policy == SchemaReport {
+			continue
+		}
+
+		var reasons []string
+		for _, conflict := range conflicts {
+			if !conflict.Coerced {
+				reasons = append(reasons, conflict.Error())
+			}
+		}
+		if len(reasons) > 0 {
+			rejected = append(rejected, RejectedMetric{Index: i, Message: strings.Join(reasons, "; ")})
+		}
+		if !changed && (len(reasons) > 0 || coerced != nil) {
+			// copy on the first change, so m isn't changed
+			checked, changed = append(make([]Metric, 0, len(m)), m[:i]...), true
+		}
+		switch {
+		case !changed || len(reasons) > 0:
+		case coerced != nil:
+			checked = append(checked, coerced)
+		default:
+			checked = append(checked, m[i])
+		}
+	}
+	if len(rejected) > 0 {
+		return checked, &WriteError{
+			Err:      &Error{Code: EConflict, Op: "Write", Message: fmt.Sprintf("%d metrics conflict with the schema of their measurement", len(rejected))},
+			Rejected: rejected,
+		}
+	}
+	return checked, nil
+}
+
+// check checks metric i against the schema of its measurement, returning a copy with its values converted to the schema,
+// if coerce is set and any needed converting. The schema learns from the metric if it is learned, and the metric doesn't conflict with it.
+func (r *SchemaRegistry) check(i int, metric Metric, coerce bool) (Metric, []SchemaConflict) {
+	if r.measurements == nil {
+		r.measurements = map[string]*measurementSchema{}
+	}
+	name := metric.Name()
+	s, ok := r.measurements[name]
+	if !ok {
+		s = &measurementSchema{tags: map[string]struct{}{}, fields: map[string]FieldType{}}
+		r.measurements[name] = s
+	}
+
+	var (
+		conflicts []SchemaConflict
+		newTags   []string
+		newFields []*lp.Field
+		coerced   []*lp.Field
+		conflict  = func(key, format string, args ...interface{}) {
+			conflicts = append(conflicts, SchemaConflict{Index: i, Measurement: name, Key: key, Reason: fmt.Sprintf(format, args...)})
+		}
+	)
+	for _, tag := range metric.TagList() {
+		if tag.Value == "" {
+			// not written
+			continue
+		}
+		if _, ok := s.tags[tag.Key]; ok {
+			continue
+		}
+		switch _, field := s.fields[tag.Key]; {
+		case field:
+			conflict(tag.Key, "tag %q is a field", tag.Key)
+		case s.declared:
+			conflict(tag.Key, "tag %q isn't in the schema", tag.Key)
+		default:
+			newTags = append(newTags, tag.Key)
+		}
+	}
+	fields := metric.FieldList()
+	for k, field := range fields {
+		typ, ok := fieldTypeOf(field.Value)
+		if !ok {
+			// fails to encode anyway
+			continue
+		}
+		want, known := s.fields[field.Key]
+		if !known {
+			switch _, tag := s.tags[field.Key]; {
+			case tag:
+				conflict(field.Key, "field %q is a tag", field.Key)
+			case s.declared:
+				conflict(field.Key, "field %q isn't in the schema", field.Key)
+			default:
+				newFields = append(newFields, field)
+			}
+			continue
+		}
+		if typ == want {
+			continue
+		}
+		conflict(field.Key, "field %q is %s, not %s", field.Key, typ, want)
+		if !coerce {
+			continue
+		}
+		if v, ok := coerceValue(field.Value, want); ok {
+			if coerced == nil {
+				coerced = append([]*lp.Field{}, fields...)
+			}
+			coerced[k] = &lp.Field{Key: field.Key, Value: v}
+			conflicts[len(conflicts)-1].Coerced = true
+		}
+	}
+
+	for _, c := range conflicts {
+		if !c.Coerced {
+			return nil, conflicts
+		}
+	}
+	for _, key := range newTags {
+		s.tags[key] = struct{}{}
+	}
+	for _, field := range newFields {
+		s.fields[field.Key], _ = fieldTypeOf(field.Value)
+	}
+	if coerced != nil {
+		return &coercedMetric{Metric: metric, fields: coerced}, conflicts
+	}
+	return nil, conflicts
+}
+
+// coercedMetric is a metric with field values converted to the types of its schema.
+type coercedMetric struct {
+	Metric
+	fields []*lp.Field
+}
+
+func (m *coercedMetric) FieldList() []*lp.Field {
+	return m.fields
+}
+
+// maxExactFloat is the largest integer up to which every integer is a float64 exactly.
+const maxExactFloat = 1 << 53
+
+// coerceValue converts v to a value of type want, if it can without losing anything.
+func coerceValue(v interface{}, want FieldType) (interface{}, bool) {
+	var (
+		i        int64
+		u        uint64
+		f        float64
+		isInt    bool
+		isUint   bool
+		isFloat  bool
+		negative bool
+	)
+	switch v := v.(type) {
+	case int64:
+		i, isInt = v, true
+	case int:
+		i, isInt = int64(v), true
+	case int32:
+		i, isInt = int64(v), true
+	case int16:
+		i, isInt = int64(v), true
+	case int8:
+		i, isInt = int64(v), true
+	case uint64:
+		u, isUint = v, true
+	case uint:
+		u, isUint = uint64(v), true
+	case uint32:
+		u, isUint = uint64(v), true
+	case uint16:
+		u, isUint = uint64(v), true
+	case uint8:
+		u, isUint = uint64(v), true
+	case float64:
+		f, isFloat = v, true
+	case float32:
+		f, isFloat = float64(v), true
+	default:
+		return nil, false
+	}
+	negative = isInt && i < 0 || isFloat && f < 0
+
+	switch want {
+	case FieldFloat:
+		switch {
+		case isInt && i >= -maxExactFloat && i <= maxExactFloat:
+			return float64(i), true
+		case isUint && u <= maxExactFloat:
+			return float64(u), true
+		}
+	case FieldInteger:
+		switch {
+		case isUint && u <= math.MaxInt64:
+			return int64(u), true
+		case isFloat && f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64:
+			return int64(f), true
+		}
+	case FieldUnsigned:
+		switch {
+		case negative:
+		case isInt:
+			return uint64(i), true
+		case isFloat && f == math.Trunc(f) && f < math.MaxUint64:
+			return uint64(f), true
+		}
+	}
+	return nil, false
+}
+
+// WithSchema returns an option for checking the metrics of each write against the schemas of a registry before sending them,
+// dealing with metrics which conflict as policy says. Line protocol passed to WriteLineProtocol isn't checked.
+func WithSchema(registry *SchemaRegistry, policy SchemaPolicy) Option {
+	return Option{
+		name: "WithSchema",
+		f: func(c *Client) error {
+			if registry == nil {
+				return errors.New("the schema registry must not be nil")
+			}
+			c.schema = registry
+			c.schemaPolicy = policy
+			return nil
+		},
+	}
+}
+
+// checkSchema checks metrics against the client's schema registry, returning the metrics to write,
+// with the index each has in m, or nil if it is the same.
+// If some conflict, it returns the *WriteError of the registry, with Dropped set if o.dropRejected is.
+func (c *Client) checkSchema(m []Metric, o writeOptions) ([]Metric, []int, error) {
+	checked, err := c.schema.Check(m, c.schemaPolicy)
+	werr, ok := err.(*WriteError)
+	if !ok {
+		return checked, nil, err
+	}
+	if !o.dropRejected {
+		return nil, nil, werr
+	}
+	werr.Dropped = true
+	indexes := make([]int, 0, len(checked))
+	for i, j := 0, 0; i < len(m); i++ {
+		if j < len(werr.Rejected) && werr.Rejected[j].Index == i {
+			j++
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	c.log(LevelWarn, "dropping metrics conflicting with the schema", "op", "Write", "count", len(werr.Rejected), "error", werr.Err)
+	return checked, indexes, werr
+}
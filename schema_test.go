@@ -0,0 +1,230 @@
+package influxdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	cmp "github.com/google/go-cmp/cmp"
+	"github.com/lancey-energy-storage/influxdb-client-go/influxdbtest"
+)
+
+func batteryMetric(fields map[string]interface{}, tags map[string]string) Metric {
+	return NewRowMetric(fields, "battery", tags, time.Date(2019, 5, 2, 16, 12, 41, 0, time.UTC))
+}
+
+// rejectedIndexes returns the indexes of the metrics err rejects.
+func rejectedIndexes(t *testing.T, err error) []int {
+	t.Helper()
+	var werr *WriteError
+	if !errors.As(err, &werr) {
+		t.Fatalf("expected a *WriteError, got %v", err)
+	}
+	indexes := make([]int, len(werr.Rejected))
+	for i, r := range werr.Rejected {
+		indexes[i] = r.Index
+	}
+	return indexes
+}
+
+func TestSchemaRegistry_Check(t *testing.T) {
+	var (
+		r         = NewSchemaRegistry()
+		conflicts []SchemaConflict
+	)
+	r.OnConflict(func(c SchemaConflict) { conflicts = append(conflicts, c) })
+
+	m := []Metric{
+		batteryMetric(map[string]interface{}{"soc": 0.5}, map[string]string{"rack": "1"}),
+		batteryMetric(map[string]interface{}{"soc": 1}, nil),
+		batteryMetric(map[string]interface{}{"soc": 0.7, "temp": 21.5}, map[string]string{"site": "a"}),
+		batteryMetric(map[string]interface{}{"rack": "2", "soc": 0.8}, map[string]string{"soc": "high"}),
+	}
+	checked, err := r.Check(m, SchemaReject)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	if diff := cmp.Diff([]int{1, 3}, rejectedIndexes(t, err)); diff != "" {
+		t.Errorf("unexpected rejected metrics: %s", diff)
+	}
+	if len(checked) != 2 || checked[0] != m[0] || checked[1] != m[2] {
+		t.Errorf("expected metrics 0 and 2 to be left, got %v", checked)
+	}
+	if len(conflicts) != 3 || conflicts[0].Index != 1 || conflicts[0].Key != "soc" || conflicts[0].Reason != `field "soc" is integer, not float` {
+		t.Errorf("unexpected conflicts: %+v", conflicts)
+	}
+
+	want := MeasurementSchema{Tags: []string{"rack", "site"}, Fields: map[string]FieldType{"soc": FieldFloat, "temp": FieldFloat}}
+	if got, _ := r.Schema("battery"); !cmp.Equal(want, got) {
+		t.Errorf("expected the schema %+v to be learned, got %+v", want, got)
+	}
+
+	// coercing converts what it can
+	conflicts = nil
+	m = []Metric{
+		batteryMetric(map[string]interface{}{"soc": 1}, nil),
+		batteryMetric(map[string]interface{}{"soc": "full"}, nil),
+		batteryMetric(map[string]interface{}{"soc": 0.9}, nil),
+	}
+	checked, err = r.Check(m, SchemaCoerce)
+	if diff := cmp.Diff([]int{1}, rejectedIndexes(t, err)); diff != "" {
+		t.Errorf("unexpected rejected metrics: %s", diff)
+	}
+	if len(checked) != 2 || checked[0].FieldList()[0].Value != float64(1) || checked[1] != m[2] {
+		t.Errorf("expected the first metric to be converted, got %v", checked)
+	}
+	if m[0].FieldList()[0].Value != int64(1) {
+		t.Errorf("expected the metric itself to be left alone, got %v", m[0].FieldList()[0].Value)
+	}
+	if len(conflicts) != 2 || !conflicts[0].Coerced || conflicts[1].Coerced {
+		t.Errorf("unexpected conflicts: %+v", conflicts)
+	}
+
+	// reporting writes them all
+	checked, err = r.Check(m, SchemaReport)
+	if err != nil || len(checked) != 3 {
+		t.Errorf("expected all metrics to be left, got %v, %v", checked, err)
+	}
+}
+
+func TestSchemaRegistry_Declare(t *testing.T) {
+	r := NewSchemaRegistry()
+	if err := r.Declare("battery", MeasurementSchema{Tags: []string{"rack"}, Fields: map[string]FieldType{"soc": FieldFloat}}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := r.Check([]Metric{
+		batteryMetric(map[string]interface{}{"soc": 0.5}, map[string]string{"rack": "1"}),
+		batteryMetric(map[string]interface{}{"soc": 0.5}, map[string]string{"site": "a"}),
+		batteryMetric(map[string]interface{}{"soc": 0.5, "temp": 21.5}, nil),
+	}, SchemaReject)
+	if diff := cmp.Diff([]int{1, 2}, rejectedIndexes(t, err)); diff != "" {
+		t.Errorf("unexpected rejected metrics: %s", diff)
+	}
+	if s, _ := r.Schema("battery"); len(s.Tags) != 1 || len(s.Fields) != 1 || !s.Declared {
+		t.Errorf("expected the declared schema to be left alone, got %+v", s)
+	}
+
+	if err := r.Declare("battery", MeasurementSchema{Fields: map[string]FieldType{"soc": "double"}}); err == nil {
+		t.Error("expected an unknown type to fail")
+	}
+	if err := r.Declare("battery", MeasurementSchema{Tags: []string{"soc"}, Fields: map[string]FieldType{"soc": FieldFloat}}); err == nil {
+		t.Error("expected a key which is a tag and a field to fail")
+	}
+}
+
+func TestSchemaRegistry_JSON(t *testing.T) {
+	r := NewSchemaRegistry()
+	if err := r.Declare("battery", MeasurementSchema{Tags: []string{"rack"}, Fields: map[string]FieldType{"soc": FieldFloat}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Check([]Metric{NewRowMetric(map[string]interface{}{"kw": int64(3), "on": true}, "inverter", map[string]string{"id": "7"}, time.Time{})}, SchemaReject); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"battery":{"tags":["rack"],"fields":{"soc":"float"},"declared":true},"inverter":{"tags":["id"],"fields":{"kw":"integer","on":"boolean"}}}`
+	if string(data) != want {
+		t.Errorf("expected %s, got %s", want, data)
+	}
+
+	var loaded SchemaRegistry
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	for _, measurement := range []string{"battery", "inverter"} {
+		got, _ := loaded.Schema(measurement)
+		want, _ := r.Schema(measurement)
+		if !cmp.Equal(want, got) {
+			t.Errorf("expected the schema of %s to be %+v, got %+v", measurement, want, got)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"battery":{"fields":{"soc":"double"}}}`), &loaded); err == nil {
+		t.Error("expected an unknown type to fail")
+	}
+}
+
+func TestCoerceValue(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want FieldType
+		got  interface{}
+	}{
+		{v: int64(3), want: FieldFloat, got: float64(3)},
+		{v: uint64(3), want: FieldFloat, got: float64(3)},
+		{v: int64(1<<53 + 1), want: FieldFloat},
+		{v: 3.0, want: FieldInteger, got: int64(3)},
+		{v: 3.5, want: FieldInteger},
+		{v: uint64(math.MaxUint64), want: FieldInteger},
+		{v: int64(3), want: FieldUnsigned, got: uint64(3)},
+		{v: int64(-3), want: FieldUnsigned},
+		{v: -3.0, want: FieldUnsigned},
+		{v: float32(2), want: FieldUnsigned, got: uint64(2)},
+		{v: true, want: FieldInteger},
+		{v: int64(1), want: FieldBoolean},
+		{v: int64(1), want: FieldString},
+	}
+	for _, tt := range tests {
+		got, ok := coerceValue(tt.v, tt.want)
+		if ok != (tt.got != nil) || got != tt.got {
+			t.Errorf("coerceValue(%T(%v), %s) = %v, %v, want %v", tt.v, tt.v, tt.want, got, ok, tt.got)
+		}
+	}
+}
+
+func TestClient_Write_schema(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+
+	ctx := context.Background()
+	m := []Metric{
+		batteryMetric(map[string]interface{}{"soc": 0.5}, map[string]string{"rack": "1"}),
+		batteryMetric(map[string]interface{}{"soc": 1}, map[string]string{"rack": "2"}),
+		batteryMetric(map[string]interface{}{"soc": "full"}, map[string]string{"rack": "3"}),
+	}
+
+	// rejected before sending
+	c, err := New(s.URL, setup.Token, WithSchema(NewSchemaRegistry(), SchemaReject))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.Write(ctx, "my-bucket", "my-org", m...); !errors.Is(err, ErrConflict) || n != 0 {
+		t.Fatalf("expected the write to conflict, got %d, %v", n, err)
+	}
+	if requests := s.Requests("/api/v2/write"); requests != 0 {
+		t.Errorf("expected no requests, got %d", requests)
+	}
+
+	// the rest written
+	n, err := c.WriteMetrics(ctx, "my-bucket", "my-org", m, WithDropRejected())
+	var werr *WriteError
+	if !errors.As(err, &werr) || !werr.Dropped || n != 1 {
+		t.Fatalf("expected the conflicting metrics to be dropped, got %d, %v", n, err)
+	}
+	if diff := cmp.Diff([]int{1, 2}, rejectedIndexes(t, err)); diff != "" {
+		t.Errorf("unexpected dropped metrics: %s", diff)
+	}
+
+	// coerced
+	c, err = New(s.URL, setup.Token, WithSchema(NewSchemaRegistry(), SchemaCoerce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.WriteMetrics(ctx, "my-bucket", "my-org", m, WithDropRejected()); n != 2 || len(rejectedIndexes(t, err)) != 1 {
+		t.Fatalf("expected 2 metrics written, got %d, %v", n, err)
+	}
+	points := s.Points("my-org", "my-bucket")
+	if len(points) != 2 || points[1].Tags["rack"] != "2" || points[1].Fields["soc"] != float64(1) {
+		t.Errorf("expected the soc of rack 2 to be written as a float, got %+v", points)
+	}
+
+	if _, err := New(s.URL, setup.Token, WithSchema(nil, SchemaReject)); err == nil {
+		t.Error("expected a nil registry to fail")
+	}
+}
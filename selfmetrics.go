@@ -0,0 +1,233 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSelfMetricsMeasurement = "influxdb_client_requests"
+	defaultSelfMetricsInterval    = time.Minute
+)
+
+type selfMetricsKey struct {
+	op     string
+	method string
+	status string
+}
+
+type requestStats struct {
+	requests      int64
+	retries       int64
+	errors        int64
+	latencySum    time.Duration
+	latencyMax    time.Duration
+	bytesSent     int64
+	bytesReceived int64
+	// for compressed request bodies
+	bytesUncompressed int64
+	compressionSum    time.Duration
+}
+
+// add adds the statistics of o to st.
+func (st *requestStats) add(o *requestStats) {
+	st.requests += o.requests
+	st.retries += o.retries
+	st.errors += o.errors
+	st.latencySum += o.latencySum
+	if o.latencyMax > st.latencyMax {
+		st.latencyMax = o.latencyMax
+	}
+	st.bytesSent += o.bytesSent
+	st.bytesReceived += o.bytesReceived
+	st.bytesUncompressed += o.bytesUncompressed
+	st.compressionSum += o.compressionSum
+}
+
+// SelfMetrics is an Instrumentation which aggregates request statistics per operation and status code,
+// and periodically writes them as metrics into a bucket through the client's own Write.
+// Each flush writes the statistics gathered since the previous flush, so the client monitors itself.
+// The requests SelfMetrics makes to write its statistics are counted as well.
+//
+// Use it like so:
+//
+//	m := influxdb.NewSelfMetrics("monitoring", "my-org", time.Minute)
+//	influx, err := influxdb.New(addr, token, influxdb.WithInstrumentation(m))
+//	...
+//	m.Start(influx)
+//	defer m.Stop()
+type SelfMetrics struct {
+	bucket      string
+	org         string
+	interval    time.Duration
+	measurement string
+
+	mu      sync.Mutex
+	stats   map[selfMetricsKey]*requestStats
+	client  *Client
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewSelfMetrics returns a *SelfMetrics that writes to bucket in org every interval, once started.
+// An interval which isn't positive is a minute.
+func NewSelfMetrics(bucket, org string, interval time.Duration) *SelfMetrics {
+	if interval <= 0 {
+		interval = defaultSelfMetricsInterval
+	}
+	return &SelfMetrics{
+		bucket:      bucket,
+		org:         org,
+		interval:    interval,
+		measurement: defaultSelfMetricsMeasurement,
+		stats:       map[selfMetricsKey]*requestStats{},
+	}
+}
+
+// SetMeasurement sets the measurement name the statistics are written to.
+// It defaults to "influxdb_client_requests".
+func (s *SelfMetrics) SetMeasurement(name string) {
+	s.mu.Lock()
+	s.measurement = name
+	s.mu.Unlock()
+}
+
+// RequestDone records a request in the current statistics.
+func (s *SelfMetrics) RequestDone(e RequestEvent) {
+	key := selfMetricsKey{op: e.Op, method: e.Method, status: "error"}
+	if e.Err == nil {
+		key.status = strconv.Itoa(e.StatusCode)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stats[key]
+	if !ok {
+		st = &requestStats{}
+		s.stats[key] = st
+	}
+	st.requests++
+	if e.Attempt > 1 {
+		st.retries++
+	}
+	if e.Err != nil || e.StatusCode >= 400 {
+		st.errors++
+	}
+	st.latencySum += e.Latency
+	if e.Latency > st.latencyMax {
+		st.latencyMax = e.Latency
+	}
+	if e.BytesSent > 0 {
+		st.bytesSent += e.BytesSent
+	}
+	if e.BytesReceived > 0 {
+		st.bytesReceived += e.BytesReceived
+	}
+	if e.UncompressedBytes > 0 {
+		st.bytesUncompressed += e.UncompressedBytes
+	}
+	st.compressionSum += e.CompressionTime
+}
+
+// Start begins writing the statistics through c every interval.
+// c should be the client this SelfMetrics was passed to with WithInstrumentation.
+func (s *SelfMetrics) Start(c *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.client = c
+	s.stop = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go s.run(s.stop, s.stopped)
+}
+
+func (s *SelfMetrics) run(stop, stopped chan struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = s.Flush(context.Background())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop stops the periodic writes and writes any statistics gathered since the last flush.
+func (s *SelfMetrics) Stop() error {
+	s.mu.Lock()
+	stop, stopped := s.stop, s.stopped
+	s.stop, s.stopped = nil, nil
+	s.mu.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	<-stopped
+	return s.Flush(context.Background())
+}
+
+// Flush writes the statistics gathered since the last flush, and resets them.
+// If the write fails, the statistics are kept, to be written by the next flush along with those gathered meanwhile.
+func (s *SelfMetrics) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	c := s.client
+	if c == nil {
+		s.mu.Unlock()
+		return errors.New("self metrics have not been started")
+	}
+	stats := s.stats
+	s.stats = make(map[selfMetricsKey]*requestStats, len(stats))
+	measurement := s.measurement
+	s.mu.Unlock()
+
+	if len(stats) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	metrics := make([]Metric, 0, len(stats))
+	for key, st := range stats {
+		metrics = append(metrics, NewRowMetric(
+			map[string]interface{}{
+				"requests":                st.requests,
+				"retries":                 st.retries,
+				"errors":                  st.errors,
+				"latency_sum_seconds":     st.latencySum.Seconds(),
+				"latency_max_seconds":     st.latencyMax.Seconds(),
+				"bytes_sent":              st.bytesSent,
+				"bytes_received":          st.bytesReceived,
+				"bytes_uncompressed":      st.bytesUncompressed,
+				"compression_sum_seconds": st.compressionSum.Seconds(),
+			},
+			measurement,
+			map[string]string{
+				"op":     key.op,
+				"method": key.method,
+				"status": key.status,
+			},
+			now,
+		))
+	}
+
+	if _, err := c.Write(ctx, s.bucket, s.org, metrics...); err != nil {
+		s.mu.Lock()
+		for key, st := range stats {
+			if current, ok := s.stats[key]; ok {
+				st.add(current)
+			}
+			s.stats[key] = st
+		}
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
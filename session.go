@@ -0,0 +1,117 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// sessionCookieName is the name of the cookie influxdb uses to identify a session.
+const sessionCookieName = "session"
+
+// sessionCookie returns the current session cookie, signing in if there isn't one.
+// Only one request signs in at a time, without holding c.l, and the others wait for it.
+func (c *Client) sessionCookie(ctx context.Context) (*http.Cookie, error) {
+	for {
+		c.l.Lock()
+		if c.session != nil {
+			cookie := c.session
+			c.l.Unlock()
+			return cookie, nil
+		}
+		if signingIn := c.signingIn; signingIn != nil {
+			c.l.Unlock()
+			select {
+			case <-signingIn:
+				// look again, and sign in ourselves if that sign in failed
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		c.signingIn = done
+		c.l.Unlock()
+
+		cookie, err := c.signin(ctx)
+		c.l.Lock()
+		if err == nil {
+			c.session = cookie
+		}
+		c.signingIn = nil
+		c.l.Unlock()
+		close(done)
+		return cookie, err
+	}
+}
+
+// signin creates a new session using the client's username and password.
+func (c *Client) signin(ctx context.Context) (*http.Cookie, error) {
+	req, err := http.NewRequest(http.MethodPost, c.url.String()+"/signin", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	c.l.Lock()
+	req.SetBasicAuth(c.username, c.password)
+	c.l.Unlock()
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.roundTrip("signin", req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := newErrorFromResponse(resp)
+		err.Code = EUnauthorized
+		err.Op = "signin"
+		err.Message = "could not sign in: " + resp.Status
+		return nil, err
+	}
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookieName {
+			return cookie, nil
+		}
+	}
+	return nil, errors.New("signin response did not contain a session cookie")
+}
+
+// signout expires the current session, if there is one.
+func (c *Client) signout(ctx context.Context) error {
+	c.l.Lock()
+	cookie := c.session
+	c.session = nil
+	c.l.Unlock()
+
+	if cookie == nil {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url.String()+"/signout", nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", c.userAgent)
+	req.AddCookie(cookie)
+	resp, err := c.roundTrip("signout", req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
@@ -1,76 +1,72 @@
 package influxdb
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
 )
 
 // Setup sets up a new influxdbv2 server.
 // It requires a client be set up with a username and password.
-// If successful will add a token to the client.
+// If successful will add a token to the client, unless it was built WithCredentials, whose tokens it keeps using.
 // RetentionPeriodHrs of zero will result in infinite retention.
 func (c *Client) Setup(ctx context.Context, username, password, bucket string, org string, retentionPeriodHrs int) (*SetupResult, error) {
 	if username == "" || password == "" {
 		return nil, errors.New("a username and password is required for a setup")
 	}
+	c.l.Lock()
 	c.username = username
 	c.password = password
-	log.Printf("[DEBUG] Setup a new influxdbv2 instance")
-	inputData, err := json.Marshal(SetupRequest{
-		Username:           c.username,
-		Password:           c.password,
-		Org:                org,
-		Bucket:             bucket,
-		RetentionPeriodHrs: retentionPeriodHrs,
-	})
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodPost, c.url.String()+"/setup", bytes.NewBuffer(inputData))
-	if err != nil {
-		return nil, err
+	c.l.Unlock()
+	c.log(LevelInfo, "setting up a new instance", "op", "Setup", "org", org, "bucket", bucket)
+	setupResult := &SetupResult{}
+	err := c.doAPI(ctx, apiRequest{
+		op:     "Setup",
+		method: http.MethodPost,
+		path:   c.apiPath("setup"),
+		body: SetupRequest{
+			Username:           username,
+			Password:           password,
+			Org:                org,
+			Bucket:             bucket,
+			RetentionPeriodHrs: retentionPeriodHrs,
+		},
+		status: []int{http.StatusCreated},
+		noAuth: true,
+	}, setupResult)
+	var e *Error
+	if errors.As(err, &e) && e.Code == EConflict {
+		// the server has been set up already, which isn't an error for the caller
+		return &SetupResult{Code: e.Code, Message: e.Message}, nil
 	}
-	req = req.WithContext(ctx)
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	setupResult := &SetupResult{}
-	if err := json.NewDecoder(resp.Body).Decode(setupResult); err != nil {
-		return nil, err
-	}
-	if setupResult.Code != "conflict" && resp.StatusCode == http.StatusCreated && setupResult.Auth.Token != "" {
-		c.l.Lock()
+	c.l.Lock()
+	if setupResult.Auth.Token != "" && c.credentials == nil {
 		c.authorization = "Token " + setupResult.Auth.Token
-
-		c.l.Unlock()
 	}
+	c.l.Unlock()
 	return setupResult, nil
 }
 
+// GetSetup is like GetSetupContext, with a background context.
 func (c *Client) GetSetup() (*Setup, error) {
-	log.Printf("[DEBUG] Getting setup status")
+	return c.GetSetupContext(context.Background())
+}
 
-	req, err := http.NewRequest(http.MethodGet, c.url.String()+"/setup", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// GetSetupContext returns whether the server still allows being set up.
+func (c *Client) GetSetupContext(ctx context.Context) (*Setup, error) {
+	c.log(LevelDebug, "getting setup status", "op", "GetSetup")
 
 	setup := &Setup{}
-	if err := json.NewDecoder(resp.Body).Decode(setup); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetSetup",
+		method: http.MethodGet,
+		path:   c.apiPath("setup"),
+		noAuth: true,
+	}, setup)
+	if err != nil {
 		return nil, err
 	}
 	return setup, nil
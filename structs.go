@@ -0,0 +1,316 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lp "github.com/influxdata/line-protocol"
+)
+
+// MetricFromStruct returns the metric described by the lp tags of the fields of v, a struct or a pointer to one.
+// It is the write side of QueryCSVResult.Unmarshal's flux tags:
+//
+//	type Cell struct {
+//		_       struct{}  `lp:"measurement,cells"` // or a string field tagged `lp:"measurement"`
+//		Site    string    `lp:"tag,site"`
+//		Voltage float64   `lp:"field,voltage"`
+//		Temp    *float64  `lp:"field,temp"`             // omitted if nil
+//		Status  string    `lp:"field,status,omitempty"` // omitted if ""
+//		Time    time.Time `lp:"time"`
+//	}
+//
+// The name after the kind defaults to the name of the struct field.
+// Tags and fields may be strings, bools, integers or floats, or pointers to them, which are omitted if nil.
+// Tags with empty values are always omitted, and omitempty omits fields with zero values.
+// Embedded structs without an lp tag are flattened.
+// What each struct type encodes to is worked out once, and cached.
+func MetricFromStruct(v interface{}) (*RowMetric, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, errors.New("cannot make a metric from a nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot make a metric from a %s, it must be a struct", rv.Type())
+	}
+	plan, err := structPlanFor(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	return plan.metric(rv)
+}
+
+// WriteStructs writes v, a struct, a pointer to one, or a slice of either, as metrics made with MetricFromStruct.
+func (c *Client) WriteStructs(ctx context.Context, bucket, org string, v interface{}, opts ...WriteOption) (int, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		m, err := MetricFromStruct(v)
+		if err != nil {
+			return 0, err
+		}
+		return c.WriteMetrics(ctx, bucket, org, []Metric{m}, opts...)
+	}
+	metrics := make([]Metric, rv.Len())
+	for i := range metrics {
+		m, err := MetricFromStruct(rv.Index(i).Interface())
+		if err != nil {
+			return 0, fmt.Errorf("element %d: %v", i, err)
+		}
+		metrics[i] = m
+	}
+	return c.WriteMetrics(ctx, bucket, org, metrics, opts...)
+}
+
+// structPlans caches the *structPlan, or the error, for each struct type.
+var structPlans sync.Map
+
+type structPlanResult struct {
+	plan *structPlan
+	err  error
+}
+
+// structPlan is how to make a metric from a struct type.
+type structPlan struct {
+	typ              reflect.Type
+	measurement      string // the measurement, unless it comes from measurementField
+	measurementField []int
+	tags             []structField // sorted by name
+	fields           []structField // sorted by name
+	time             []int
+}
+
+// structField is a struct field encoded as a tag or a field.
+type structField struct {
+	name      string
+	index     []int
+	omitempty bool
+	// value returns the value to encode, or false to omit it
+	value func(reflect.Value) (interface{}, bool)
+}
+
+func structPlanFor(t reflect.Type) (*structPlan, error) {
+	if r, ok := structPlans.Load(t); ok {
+		return r.(structPlanResult).plan, r.(structPlanResult).err
+	}
+	plan := &structPlan{typ: t}
+	err := plan.add(t, nil)
+	if err == nil {
+		err = plan.check()
+	}
+	if err != nil {
+		plan = nil
+		err = fmt.Errorf("cannot make metrics from %s: %v", t, err)
+	}
+	structPlans.Store(t, structPlanResult{plan: plan, err: err})
+	return plan, err
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// add adds the tagged fields of t, a struct embedded at index, to the plan.
+func (p *structPlan) add(t reflect.Type, index []int) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+
+		tag, ok := f.Tag.Lookup("lp")
+		if !ok || tag == "-" {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Type != timeType {
+				if err := p.add(f.Type, fieldIndex); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		kind, name, opts := parts[0], f.Name, []string(nil)
+		if len(parts) > 1 {
+			if parts[1] != "" {
+				name = parts[1]
+			}
+			opts = parts[2:]
+		}
+		omitempty := false
+		for _, opt := range opts {
+			if opt != "omitempty" {
+				return fmt.Errorf("field %s has an unknown option %q", f.Name, opt)
+			}
+			omitempty = true
+		}
+
+		if kind == "measurement" && len(parts) > 1 && parts[1] != "" {
+			// a constant measurement, the field itself isn't read
+			if p.measurement != "" || p.measurementField != nil {
+				return errors.New("it has more than one measurement")
+			}
+			p.measurement = parts[1]
+			continue
+		}
+		if f.PkgPath != "" {
+			return fmt.Errorf("field %s is unexported", f.Name)
+		}
+
+		switch kind {
+		case "measurement":
+			if p.measurement != "" || p.measurementField != nil {
+				return errors.New("it has more than one measurement")
+			}
+			if f.Type.Kind() != reflect.String {
+				return fmt.Errorf("measurement field %s must be a string", f.Name)
+			}
+			p.measurementField = fieldIndex
+		case "tag":
+			value, err := tagValue(f.Type)
+			if err != nil {
+				return fmt.Errorf("tag field %s: %v", f.Name, err)
+			}
+			p.tags = append(p.tags, structField{name: name, index: fieldIndex, omitempty: true, value: value})
+		case "field":
+			value, err := fieldValue(f.Type)
+			if err != nil {
+				return fmt.Errorf("field %s: %v", f.Name, err)
+			}
+			p.fields = append(p.fields, structField{name: name, index: fieldIndex, omitempty: omitempty, value: value})
+		case "time":
+			if p.time != nil {
+				return errors.New("it has more than one time")
+			}
+			if f.Type != timeType && !(f.Type.Kind() == reflect.Ptr && f.Type.Elem() == timeType) {
+				return fmt.Errorf("time field %s must be a time.Time", f.Name)
+			}
+			p.time = fieldIndex
+		default:
+			return fmt.Errorf("field %s has an unknown lp kind %q", f.Name, kind)
+		}
+	}
+	return nil
+}
+
+// check checks the plan is complete, and sorts its tags and fields.
+func (p *structPlan) check() error {
+	if p.measurement == "" && p.measurementField == nil {
+		return errors.New("it has no measurement")
+	}
+	if len(p.fields) == 0 {
+		return errors.New("it has no fields")
+	}
+	for _, list := range [][]structField{p.tags, p.fields} {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].name < list[j].name })
+		for i := 1; i < len(list); i++ {
+			if list[i].name == list[i-1].name {
+				return fmt.Errorf("%q is used twice", list[i].name)
+			}
+		}
+	}
+	return nil
+}
+
+// metric makes the metric for v, a struct of the plan's type.
+func (p *structPlan) metric(v reflect.Value) (*RowMetric, error) {
+	m := &RowMetric{NameStr: p.measurement}
+	if p.measurementField != nil {
+		m.NameStr = v.FieldByIndex(p.measurementField).String()
+		if m.NameStr == "" {
+			return nil, fmt.Errorf("%s has an empty measurement", p.typ)
+		}
+	}
+	if p.time != nil {
+		switch t := v.FieldByIndex(p.time).Interface().(type) {
+		case time.Time:
+			m.TS = t
+		case *time.Time:
+			if t != nil {
+				m.TS = *t
+			}
+		}
+	}
+	for _, f := range p.tags {
+		value, ok := f.value(v.FieldByIndex(f.index))
+		if ok && value != "" {
+			m.Tags = append(m.Tags, &lp.Tag{Key: f.name, Value: value.(string)})
+		}
+	}
+	m.Fields = make([]*lp.Field, 0, len(p.fields))
+	for _, f := range p.fields {
+		fv := v.FieldByIndex(f.index)
+		if f.omitempty && fv.IsZero() {
+			continue
+		}
+		if value, ok := f.value(fv); ok {
+			m.Fields = append(m.Fields, &lp.Field{Key: f.name, Value: value})
+		}
+	}
+	return m, nil
+}
+
+// tagValue returns a function formatting values of type t as tag values.
+func tagValue(t reflect.Type) (func(reflect.Value) (interface{}, bool), error) {
+	if t.Kind() == reflect.Ptr {
+		elem, err := tagValue(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) (interface{}, bool) {
+			if v.IsNil() {
+				return nil, false
+			}
+			return elem(v.Elem())
+		}, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value) (interface{}, bool) { return v.String(), true }, nil
+	case reflect.Bool:
+		return func(v reflect.Value) (interface{}, bool) { return strconv.FormatBool(v.Bool()), true }, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) (interface{}, bool) { return strconv.FormatInt(v.Int(), 10), true }, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) (interface{}, bool) { return strconv.FormatUint(v.Uint(), 10), true }, nil
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value) (interface{}, bool) { return strconv.FormatFloat(v.Float(), 'f', -1, 64), true }, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// fieldValue returns a function converting values of type t to the field values the encoder takes.
+func fieldValue(t reflect.Type) (func(reflect.Value) (interface{}, bool), error) {
+	if t.Kind() == reflect.Ptr {
+		elem, err := fieldValue(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) (interface{}, bool) {
+			if v.IsNil() {
+				return nil, false
+			}
+			return elem(v.Elem())
+		}, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value) (interface{}, bool) { return v.String(), true }, nil
+	case reflect.Bool:
+		return func(v reflect.Value) (interface{}, bool) { return v.Bool(), true }, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) (interface{}, bool) { return v.Int(), true }, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) (interface{}, bool) { return v.Uint(), true }, nil
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value) (interface{}, bool) { return v.Float(), true }, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return func(v reflect.Value) (interface{}, bool) { return string(v.Bytes()), true }, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
@@ -0,0 +1,159 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lancey-energy-storage/influxdb-client-go/influxdbtest"
+)
+
+type structsBase struct {
+	Site string `lp:"tag,site"`
+}
+
+type structsCell struct {
+	_ struct{} `lp:"measurement,cells"`
+	structsBase
+	Rack    int       `lp:"tag,rack"`
+	Voltage float64   `lp:"field,voltage"`
+	Temp    *float64  `lp:"field,temp"`
+	Status  string    `lp:"field,status,omitempty"`
+	Cycles  uint16    `lp:"field"`
+	Time    time.Time `lp:"time"`
+	Ignored string
+}
+
+func TestMetricFromStruct(t *testing.T) {
+	ts := time.Date(2019, 5, 2, 16, 12, 41, 0, time.UTC)
+	temp := 21.5
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{
+			name: "all fields",
+			v:    structsCell{structsBase: structsBase{Site: "a"}, Rack: 3, Voltage: 3.3, Temp: &temp, Status: "ok", Cycles: 7, Time: ts},
+			want: "cells,rack=3,site=a Cycles=7u,status=\"ok\",temp=21.5,voltage=3.3 1556813561000000000\n",
+		},
+		{
+			name: "omitted fields",
+			v:    &structsCell{Voltage: 3.3},
+			want: "cells,rack=0 Cycles=0u,voltage=3.3\n",
+		},
+		{
+			name: "measurement field",
+			v: struct {
+				Name  string     `lp:"measurement"`
+				Value bool       `lp:"field,value"`
+				Data  []byte     `lp:"field,data"`
+				Time  *time.Time `lp:"time"`
+			}{Name: "flags", Value: true, Data: []byte("x"), Time: &ts},
+			want: "flags data=\"x\",value=true 1556813561000000000\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := MetricFromStruct(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := &bytes.Buffer{}
+			if _, err := newEncoder(buf, time.Nanosecond, true).Encode(m); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("MetricFromStruct() encoded to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricFromStruct_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{name: "not a struct", v: 1, want: "must be a struct"},
+		{name: "nil pointer", v: (*structsCell)(nil), want: "nil pointer"},
+		{name: "no measurement", v: struct {
+			V int `lp:"field"`
+		}{}, want: "no measurement"},
+		{name: "no fields", v: struct {
+			_ struct{} `lp:"measurement,m"`
+		}{}, want: "no fields"},
+		{name: "empty measurement", v: struct {
+			M string `lp:"measurement"`
+			V int    `lp:"field"`
+		}{}, want: "empty measurement"},
+		{name: "unsupported type", v: struct {
+			_ struct{}   `lp:"measurement,m"`
+			V complex128 `lp:"field"`
+		}{}, want: "unsupported type"},
+		{name: "unknown kind", v: struct {
+			_ struct{} `lp:"measurement,m"`
+			V int      `lp:"value"`
+		}{}, want: "unknown lp kind"},
+		{name: "duplicate field", v: struct {
+			_ struct{} `lp:"measurement,m"`
+			A int      `lp:"field,v"`
+			B int      `lp:"field,v"`
+		}{}, want: "used twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MetricFromStruct(tt.v)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("MetricFromStruct() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_WriteStructs(t *testing.T) {
+	s := influxdbtest.NewServer()
+	defer s.Close()
+	setup := s.Setup("my-user", "my-password", "my-org", "my-bucket")
+	c, err := New(s.URL, setup.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	ts := time.Date(2019, 5, 2, 16, 12, 41, 0, time.UTC)
+	cells := []*structsCell{
+		{structsBase: structsBase{Site: "a"}, Voltage: 3.3, Time: ts},
+		{structsBase: structsBase{Site: "b"}, Voltage: 3.4, Time: ts},
+	}
+	n, err := c.WriteStructs(ctx, "my-bucket", "my-org", cells)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 metrics written, got %d", n)
+	}
+	if _, err := c.WriteStructs(ctx, "my-bucket", "my-org", structsCell{Voltage: 3.5, Time: ts}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.WriteStructs(ctx, "my-bucket", "my-org", []int{1}); err == nil {
+		t.Error("expected a slice of non-structs to fail")
+	}
+	if points := s.Points("my-org", "my-bucket"); len(points) != 3 {
+		t.Errorf("expected 3 points, got %d", len(points))
+	}
+}
+
+func BenchmarkMetricFromStruct(b *testing.B) {
+	temp := 21.5
+	cell := &structsCell{structsBase: structsBase{Site: "a"}, Rack: 3, Voltage: 3.3, Temp: &temp, Time: time.Now()}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := MetricFromStruct(cell); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,208 +1,171 @@
 package influxdb
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
-	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 )
 
+// GetAllUsers is like GetAllUsersContext, with a background context.
 func (c *Client) GetAllUsers() (*UserList, error) {
-	log.Printf("[DEBUG] Get all users")
-
-	req, err := http.NewRequest(http.MethodGet, c.url.String()+"/users", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	defer resp.Body.Close()
+	return c.GetAllUsersContext(context.Background())
+}
 
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+// GetAllUsersContext returns all the users.
+func (c *Client) GetAllUsersContext(ctx context.Context) (*UserList, error) {
+	c.log(LevelDebug, "get all users", "op", "GetAllUsers")
 
 	userList := &UserList{}
-	if err := json.NewDecoder(resp.Body).Decode(userList); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetAllUsers",
+		method: http.MethodGet,
+		path:   c.apiPath("users"),
+	}, userList)
+	if err != nil {
 		return nil, err
 	}
-
 	return userList, nil
 }
 
+// CreateUser is like CreateUserContext, with a background context.
 func (c *Client) CreateUser(name string, oauthID string, status string) (*User, error) {
+	return c.CreateUserContext(context.Background(), name, oauthID, status)
+}
+
+// CreateUserContext creates a user.
+func (c *Client) CreateUserContext(ctx context.Context, name string, oauthID string, status string) (*User, error) {
 	if name == "" {
 		return nil, errors.New("a name is requried")
 	}
-
-	log.Printf("[DEBUG] Creation of a user with name %s", name)
-
-	inputData, err := json.Marshal(NewUser{
-		Name:    name,
-		OauthID: oauthID,
-		Status:  status,
-	})
-	req, err := http.NewRequest(http.MethodPost, c.url.String()+"/users", bytes.NewBuffer(inputData))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 201 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "creating user", "op", "CreateUser", "name", name)
 
 	user := &User{}
-	if err := json.NewDecoder(resp.Body).Decode(user); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "CreateUser",
+		method: http.MethodPost,
+		path:   c.apiPath("users"),
+		body: NewUser{
+			Name:    name,
+			OauthID: oauthID,
+			Status:  status,
+		},
+		status: []int{http.StatusCreated},
+	}, user)
+	if err != nil {
 		return nil, err
 	}
-
 	return user, nil
 }
 
+// GetUserById is like GetUserByIdContext, with a background context.
 func (c *Client) GetUserById(userID string) (*User, error) {
+	return c.GetUserByIdContext(context.Background(), userID)
+}
+
+// GetUserByIdContext returns the user with the given id.
+func (c *Client) GetUserByIdContext(ctx context.Context, userID string) (*User, error) {
 	if userID == "" {
 		return nil, errors.New("a user id is required")
 	}
-
-	log.Printf("[DEBUG] Get user informations with id %s", userID)
-
-	req, err := http.NewRequest(http.MethodGet, c.url.String()+"/users/"+userID, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "getting user", "op", "GetUserById", "userID", userID)
 
 	user := &User{}
-	if err := json.NewDecoder(resp.Body).Decode(user); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetUserById",
+		method: http.MethodGet,
+		path:   c.apiPath("users", userID),
+	}, user)
+	if err != nil {
 		return nil, err
 	}
-
 	return user, nil
 }
 
+// UpdateUser is like UpdateUserContext, with a background context.
 func (c *Client) UpdateUser(userID string, name string, oauthID string, status string) (*User, error) {
+	return c.UpdateUserContext(context.Background(), userID, name, oauthID, status)
+}
+
+// UpdateUserContext updates the user with the given id.
+func (c *Client) UpdateUserContext(ctx context.Context, userID string, name string, oauthID string, status string) (*User, error) {
 	if userID == "" {
 		return nil, errors.New("a user id is required")
 	}
 	if name == "" {
 		return nil, errors.New("a name is required")
 	}
-	log.Printf("[DEBUG] Updating user informations with id %s", userID)
-
-	inputData, err := json.Marshal(NewUser{
-		Name:    name,
-		OauthID: oauthID,
-		Status:  status,
-	})
-
-	req, err := http.NewRequest(http.MethodPatch, c.url.String()+"/users/"+userID, bytes.NewBuffer(inputData))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	c.log(LevelDebug, "updating user", "op", "UpdateUser", "userID", userID)
 
 	user := &User{}
-	if err := json.NewDecoder(resp.Body).Decode(user); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "UpdateUser",
+		method: http.MethodPatch,
+		path:   c.apiPath("users", userID),
+		body: NewUser{
+			Name:    name,
+			OauthID: oauthID,
+			Status:  status,
+		},
+	}, user)
+	if err != nil {
 		return nil, err
 	}
-
 	return user, nil
 }
 
+// DeleteUser is like DeleteUserContext, with a background context.
 func (c *Client) DeleteUser(userID string) error {
+	return c.DeleteUserContext(context.Background(), userID)
+}
+
+// DeleteUserContext deletes the user with the given id.
+func (c *Client) DeleteUserContext(ctx context.Context, userID string) error {
 	if userID == "" {
 		return errors.New("a user id is required")
 	}
+	c.log(LevelDebug, "deleting user", "op", "DeleteUser", "userID", userID)
 
-	log.Printf("[DEBUG] Deleting the user with id %s", userID)
-
-	req, err := http.NewRequest(http.MethodDelete, c.url.String()+"/users/"+userID, nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 204 {
-		return errors.New(resp.Status)
-	}
-
-	return nil
+	return c.doAPI(ctx, apiRequest{
+		op:     "DeleteUser",
+		method: http.MethodDelete,
+		path:   c.apiPath("users", userID),
+		status: []int{http.StatusNoContent},
+	}, nil)
 }
 
+// UpdatePasswordOfUser is like UpdatePasswordOfUserContext, with a background context.
 func (c *Client) UpdatePasswordOfUser(userID string, password string) error {
+	return c.UpdatePasswordOfUserContext(context.Background(), userID, password)
+}
+
+// UpdatePasswordOfUserContext sets the password of the user with the given id.
+func (c *Client) UpdatePasswordOfUserContext(ctx context.Context, userID string, password string) error {
 	if userID == "" {
 		return errors.New("a user id is required")
 	}
 	if password == "" {
 		return errors.New("a password is required")
 	}
+	c.log(LevelDebug, "updating password of user", "op", "UpdatePasswordOfUser", "userID", userID)
 
-	log.Printf("[DEBUG] updating password for user with id %s", userID)
-
-	inputData, err := json.Marshal(Password{
-		Password: password,
-	})
-	req, err := http.NewRequest(http.MethodPut, c.url.String()+"/users/"+userID+"/password", bytes.NewBuffer(inputData))
-	if err != nil {
-		return err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 204 {
-		return errors.New(resp.Status)
-	}
-
-	return nil
+	return c.doAPI(ctx, apiRequest{
+		op:     "UpdatePasswordOfUser",
+		method: http.MethodPut,
+		path:   c.apiPath("users", userID, "password"),
+		body:   Password{Password: password},
+		status: []int{http.StatusNoContent},
+	}, nil)
 }
 
+// GetLogsOfUser is like GetLogsOfUserContext, with a background context.
 func (c *Client) GetLogsOfUser(userID string, limit int, offset int) (*UserLogs, error) {
+	return c.GetLogsOfUserContext(context.Background(), userID, limit, offset)
+}
+
+// GetLogsOfUserContext returns a page of at most limit log entries of the user with the given id, starting at offset.
+func (c *Client) GetLogsOfUserContext(ctx context.Context, userID string, limit int, offset int) (*UserLogs, error) {
 	if userID == "" {
 		return nil, errors.New("a user id is required")
 	}
@@ -212,37 +175,30 @@ func (c *Client) GetLogsOfUser(userID string, limit int, offset int) (*UserLogs,
 	if offset < 0 {
 		return nil, errors.New("offset needs to be granter or equal to 0")
 	}
+	c.log(LevelDebug, "getting logs of user", "op", "GetLogsOfUser", "userID", userID)
 
-	log.Printf("[DEBUG] Get logs of user with id: %s", userID)
-
-	params := "?limit=" + strconv.Itoa(limit) + "&offset=" + strconv.Itoa(offset)
-	req, err := http.NewRequest(http.MethodGet, c.url.String()+"/users/"+userID+"/logs"+params, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Authorization", c.authorization)
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, errors.New(resp.Status)
-	}
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
 
 	userLogs := &UserLogs{}
-	if err := json.NewDecoder(resp.Body).Decode(userLogs); err != nil {
+	err := c.doAPI(ctx, apiRequest{
+		op:     "GetLogsOfUser",
+		method: http.MethodGet,
+		path:   c.apiPath("users", userID, "logs"),
+		query:  query,
+	}, userLogs)
+	if err != nil {
 		return nil, err
 	}
-
 	return userLogs, nil
 }
 
 type UserList struct {
 	Links struct {
+		Next string `json:"next"`
 		Self string `json:"self"`
+		Prev string `json:"prev"`
 	} `json:"links"`
 	Users []User `json:"users"`
 }
@@ -0,0 +1,84 @@
+package influxdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// v1Precisions are the precision parameters of the v1 write endpoint.
+var v1Precisions = map[time.Duration]string{
+	time.Nanosecond:  "ns",
+	time.Microsecond: "u",
+	time.Millisecond: "ms",
+	time.Second:      "s",
+}
+
+// WriteV1 writes metrics to a database and retention policy through the /write endpoint of InfluxDB 1,
+// which InfluxDB 2 keeps for compatibility, writing to the bucket mapped to them.
+// An empty retentionPolicy writes to the default one of the database. The result n is the number of points written.
+// The metrics are sent in a single request, with the client's precision and default tags.
+// It fails with an error matching ErrUnsupported if the server's version doesn't have the endpoint.
+func (c *Client) WriteV1(ctx context.Context, database, retentionPolicy string, m ...Metric) (n int, err error) {
+	if database == "" {
+		return 0, errors.New("a database is required")
+	}
+	if err := c.requireFeature(ctx, "WriteV1", FeatureV1Compat); err != nil {
+		return 0, err
+	}
+	if c.knownUnsupported(FeatureUintFields) && hasUintField(m) {
+		return 0, c.unsupportedError("WriteV1", FeatureUintFields)
+	}
+	o, err := c.writeOptions(nil)
+	if err != nil {
+		return 0, err
+	}
+	c.log(LevelDebug, "writing metrics", "op", "WriteV1", "database", database, "rp", retentionPolicy, "count", len(m))
+
+	em, err := c.encodeMetrics(m, o.precision, c.defaultTags)
+	if err != nil {
+		return 0, err
+	}
+
+	u := *c.url
+	u.Path = "/write"
+	u.RawPath = ""
+	query := url.Values{}
+	query.Set("db", database)
+	setIfNotEmpty(query, "rp", retentionPolicy)
+	query.Set("precision", v1Precisions[o.precision])
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(em.data))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.do("WriteV1", req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		// discard body so connection can be reused
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	eerr, err := parseWriteError(resp)
+	if err != nil {
+		return 0, err
+	}
+	if eerr != nil {
+		eerr.Op = "WriteV1"
+		return 0, eerr
+	}
+	return len(m), nil
+}
@@ -0,0 +1,219 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnsupported is matched with errors.Is by the errors returned when the server's version doesn't support a feature.
+var ErrUnsupported = errors.New("unsupported by the server")
+
+// Feature is a server feature which not every version of InfluxDB supports.
+type Feature struct {
+	Name string
+	// Since are the first versions supporting the feature, one for each major version that has it.
+	Since []string
+}
+
+// Features checked by the client before using them.
+var (
+	FeatureDelete     = Feature{Name: "the delete API", Since: []string{"2.0.0-beta.1"}}
+	FeatureUintFields = Feature{Name: "unsigned integer fields", Since: []string{"2.0.0-alpha"}}
+	FeatureV1Compat   = Feature{Name: "the v1 compatibility endpoints", Since: []string{"1.0.0", "2.0.0"}}
+)
+
+// ServerInfo describes the server the client is connected to.
+type ServerInfo struct {
+	// Version is like "2.0.4". It is empty if the server didn't report one.
+	Version string
+	// Build is like "OSS" or "Cloud", and Commit is the commit the server was built from, if it reported them.
+	Build  string
+	Commit string
+	// Status is the overall health of the server, like "pass" or "fail".
+	Status string
+	// Started is when the server started, and Uptime for how long it has been up.
+	Started time.Time
+	Uptime  time.Duration
+}
+
+// serverVersion is the version and build last reported by the server.
+type serverVersion struct {
+	version string
+	build   string
+}
+
+// versionLookupBackoff is how long Supports waits after failing to get the server's version before asking again.
+const versionLookupBackoff = time.Minute
+
+// failedVersionLookup is the last failure to get the server's version.
+type failedVersionLookup struct {
+	at  time.Time
+	err error
+}
+
+// observeVersion records the version headers of a response.
+func (c *Client) observeVersion(h http.Header) {
+	v := serverVersion{version: h.Get("X-Influxdb-Version"), build: h.Get("X-Influxdb-Build")}
+	if v.version == "" {
+		return
+	}
+	if old, _ := c.serverVersion.Load().(serverVersion); old != v {
+		c.serverVersion.Store(v)
+	}
+}
+
+// ServerInfo returns the version, build and uptime of the server, combining Ready and GetHealth.
+func (c *Client) ServerInfo(ctx context.Context) (*ServerInfo, error) {
+	ready, err := c.Ready(ctx)
+	if err != nil {
+		return nil, err
+	}
+	health, err := c.GetHealth(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	observed, _ := c.serverVersion.Load().(serverVersion)
+	info := &ServerInfo{
+		Version: strings.TrimPrefix(health.Version, "v"),
+		Build:   observed.build,
+		Commit:  health.Commit,
+		Status:  health.Status,
+	}
+	if info.Version == "" {
+		info.Version = strings.TrimPrefix(observed.version, "v")
+	}
+	// stored even if empty, so Supports doesn't ask again
+	c.serverVersion.Store(serverVersion{version: info.Version, build: info.Build})
+	if ready.Started != "" {
+		info.Started, _ = time.Parse(time.RFC3339Nano, ready.Started)
+	}
+	if ready.Up != "" {
+		info.Uptime, _ = time.ParseDuration(ready.Up)
+	}
+	return info, nil
+}
+
+// Supports reports whether the server's version supports f, asking the server for its version if it isn't known yet.
+// Servers reporting a version that isn't like "2.0.4" are assumed to support everything.
+// If asking fails, Supports returns the error without asking again for a minute.
+func (c *Client) Supports(ctx context.Context, f Feature) (bool, error) {
+	if _, ok := c.serverVersion.Load().(serverVersion); !ok {
+		if failed, ok := c.versionLookup.Load().(failedVersionLookup); ok && time.Since(failed.at) < versionLookupBackoff {
+			return false, failed.err
+		}
+		if _, err := c.ServerInfo(ctx); err != nil {
+			c.versionLookup.Store(failedVersionLookup{at: time.Now(), err: err})
+			return false, err
+		}
+	}
+	return !c.knownUnsupported(f), nil
+}
+
+// requireFeature returns an error matching ErrUnsupported if the server doesn't support f.
+// If the server's version can't be found, it lets the caller go ahead, and fail as it would have.
+func (c *Client) requireFeature(ctx context.Context, op string, f Feature) error {
+	if ok, err := c.Supports(ctx, f); err == nil && !ok {
+		return c.unsupportedError(op, f)
+	}
+	return nil
+}
+
+// knownUnsupported reports whether the server's version is known not to support f.
+func (c *Client) knownUnsupported(f Feature) bool {
+	observed, _ := c.serverVersion.Load().(serverVersion)
+	v, ok := parseVersion(observed.version)
+	if !ok {
+		return false
+	}
+	return !f.supportedBy(v)
+}
+
+func (c *Client) unsupportedError(op string, f Feature) error {
+	observed, _ := c.serverVersion.Load().(serverVersion)
+	return fmt.Errorf("%s: %s requires InfluxDB %s or later, the server is %s: %w",
+		op, f.Name, strings.Join(f.Since, " or "), observed.version, ErrUnsupported)
+}
+
+func (f Feature) supportedBy(v version) bool {
+	for _, s := range f.Since {
+		since, ok := parseVersion(s)
+		if ok && since.major == v.major && !v.less(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// version is a semantic version, like 2.0.0-beta.16.
+type version struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// parseVersion parses versions like "2.0.4", "v2.0.0-beta.16" and "1.8.3".
+func parseVersion(s string) (version, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	var v version
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.pre = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return version{}, false
+	}
+	for i, dst := range []*int{&v.major, &v.minor, &v.patch} {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return version{}, false
+		}
+		*dst = n
+	}
+	return v, true
+}
+
+// less reports whether v precedes w, following semantic versioning's precedence rules.
+func (v version) less(w version) bool {
+	if v.major != w.major {
+		return v.major < w.major
+	}
+	if v.minor != w.minor {
+		return v.minor < w.minor
+	}
+	if v.patch != w.patch {
+		return v.patch < w.patch
+	}
+	// a pre-release precedes the release
+	if len(v.pre) == 0 || len(w.pre) == 0 {
+		return len(v.pre) > len(w.pre)
+	}
+	for i := 0; i < len(v.pre) && i < len(w.pre); i++ {
+		a, b := v.pre[i], w.pre[i]
+		if a == b {
+			continue
+		}
+		an, aerr := strconv.Atoi(a)
+		bn, berr := strconv.Atoi(b)
+		switch {
+		case aerr == nil && berr == nil:
+			return an < bn
+		case aerr == nil:
+			// numeric identifiers precede alphanumeric ones
+			return true
+		case berr == nil:
+			return false
+		default:
+			return a < b
+		}
+	}
+	return len(v.pre) < len(w.pre)
+}
@@ -0,0 +1,189 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVersion_less(t *testing.T) {
+	ordered := []string{"1.8.3", "2.0.0-alpha", "2.0.0-alpha.1", "2.0.0-beta.2", "2.0.0-beta.16", "2.0.0-rc.0", "v2.0.0", "2.0.4+OSS", "2.1.0"}
+	for i := range ordered {
+		for j := range ordered {
+			v, ok := parseVersion(ordered[i])
+			if !ok {
+				t.Fatalf("could not parse %q", ordered[i])
+			}
+			w, _ := parseVersion(ordered[j])
+			if got := v.less(w); got != (i < j) {
+				t.Errorf("%s < %s: expected %v, got %v", ordered[i], ordered[j], i < j, got)
+			}
+		}
+	}
+	for _, s := range []string{"", "OSS", "2.0", "2.x.1"} {
+		if _, ok := parseVersion(s); ok {
+			t.Errorf("expected %q not to parse", s)
+		}
+	}
+}
+
+// versionServer is a server reporting version, and counting the requests to /api/v2/delete.
+func versionServer(version string, deletes *int32) *httptest.Server {
+	return versionWriteServer(version, deletes, new(int32))
+}
+
+// versionWriteServer is versionServer, also counting the writes to the v1 write endpoint.
+func versionWriteServer(version string, deletes, v1Writes *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Influxdb-Version", version)
+		w.Header().Set("X-Influxdb-Build", "OSS")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/ready":
+			w.Write([]byte(`{"status":"ready","started":"2020-01-02T03:04:05.123456Z","up":"1h30m0s"}`))
+		case "/health":
+			w.Write([]byte(`{"name":"influxdb","status":"pass","version":"` + version + `","commit":"abc123"}`))
+		case "/api/v2/delete":
+			atomic.AddInt32(deletes, 1)
+			w.WriteHeader(http.StatusNoContent)
+		case "/write":
+			if r.URL.Query().Get("db") == "telegraf" && r.URL.Query().Get("precision") == "ns" {
+				atomic.AddInt32(v1Writes, 1)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func TestClient_ServerInfo(t *testing.T) {
+	var deletes int32
+	server := versionServer("v2.0.4", &deletes)
+	defer server.Close()
+	c, err := New(server.URL, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := c.ServerInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ServerInfo{
+		Version: "2.0.4",
+		Build:   "OSS",
+		Commit:  "abc123",
+		Status:  "pass",
+		Started: time.Date(2020, 1, 2, 3, 4, 5, 123456000, time.UTC),
+		Uptime:  90 * time.Minute,
+	}
+	if *info != want {
+		t.Errorf("expected %+v, got %+v", want, *info)
+	}
+
+	for _, f := range []Feature{FeatureDelete, FeatureUintFields, FeatureV1Compat} {
+		if ok, err := c.Supports(context.Background(), f); err != nil || !ok {
+			t.Errorf("expected %s to be supported, got %v, %v", f.Name, ok, err)
+		}
+	}
+	if err := c.Delete(context.Background(), "bucket", "org", time.Unix(0, 0), time.Now(), `_measurement="cpu"`); err != nil {
+		t.Fatal(err)
+	}
+	if deletes != 1 {
+		t.Errorf("expected 1 delete, got %d", deletes)
+	}
+}
+
+func TestClient_unsupported(t *testing.T) {
+	var deletes int32
+	server := versionServer("1.8.3", &deletes)
+	defer server.Close()
+	c, err := New(server.URL, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Delete(context.Background(), "bucket", "org", time.Unix(0, 0), time.Now(), "")
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if deletes != 0 {
+		t.Errorf("expected no deletes, got %d", deletes)
+	}
+
+	uintMetric := NewRowMetric(map[string]interface{}{"value": uint64(1)}, "cpu", nil, time.Now())
+	if _, err := c.Write(context.Background(), "bucket", "org", uintMetric); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	intMetric := NewRowMetric(map[string]interface{}{"value": int64(1)}, "cpu", nil, time.Now())
+	if _, err := c.Write(context.Background(), "bucket", "org", intMetric); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClient_WriteV1(t *testing.T) {
+	m := NewRowMetric(map[string]interface{}{"value": 0.5}, "cpu", nil, time.Unix(1, 0))
+	for _, test := range []struct {
+		version   string
+		supported bool
+	}{
+		{version: "1.8.3", supported: true},
+		{version: "2.0.4", supported: true},
+		{version: "2.0.0-beta.16", supported: false},
+	} {
+		var deletes, writes int32
+		server := versionWriteServer(test.version, &deletes, &writes)
+		c, err := New(server.URL, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := c.WriteV1(context.Background(), "telegraf", "", m)
+		if test.supported && (err != nil || n != 1 || writes != 1) {
+			t.Errorf("%s: expected the metric to be written, got %d, %v and %d writes", test.version, n, err, writes)
+		}
+		if !test.supported && (!errors.Is(err, ErrUnsupported) || writes != 0) {
+			t.Errorf("%s: expected ErrUnsupported, got %v and %d writes", test.version, err, writes)
+		}
+		server.Close()
+	}
+}
+
+func TestClient_Supports_failed(t *testing.T) {
+	var lookups, deletes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ready":
+			atomic.AddInt32(&lookups, 1)
+			w.WriteHeader(http.StatusForbidden)
+		case "/api/v2/delete":
+			atomic.AddInt32(&deletes, 1)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	c, err := New(server.URL, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the version can't be found, so the deletes go ahead, without asking for it each time
+	for i := 0; i < 3; i++ {
+		if err := c.Delete(context.Background(), "bucket", "org", time.Unix(0, 0), time.Now(), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if deletes != 3 || lookups != 1 {
+		t.Errorf("expected 3 deletes after 1 lookup, got %d and %d", deletes, lookups)
+	}
+	if _, err := c.Supports(context.Background(), FeatureDelete); err == nil {
+		t.Error("expected the failed lookup to be returned")
+	}
+}
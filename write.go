@@ -12,48 +12,254 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/lancey-energy-storage/influxdb-client-go/internal/gzip"
 	lp "github.com/influxdata/line-protocol"
 )
 
 // Write writes metrics to a bucket, and org. The result n is the number of points written.
 func (c *Client) Write(ctx context.Context, bucket, org string, m ...Metric) (n int, err error) {
-	var (
-		buf = &bytes.Buffer{}
-		e   = lp.NewEncoder(buf)
-	)
+	return c.WriteMetrics(ctx, bucket, org, m)
+}
+
+// WriteMetrics is like Write, with options for this write only, like WithWritePrecision.
+// The metrics are sent in several requests if they don't fit in the client's WithMaxBodyBytes or WithMaxCompressedBodyBytes.
+// The requests are sent in order, and the first failure stops the write, so n is the number of points in the requests that succeeded.
+// With WithSchema, the metrics are checked against their schemas first.
+func (c *Client) WriteMetrics(ctx context.Context, bucket, org string, m []Metric, opts ...WriteOption) (n int, err error) {
+	n, _, err = c.writeMetricsConsumed(ctx, bucket, org, m, opts)
+	return n, err
+}
+
+// writeMetricsConsumed is WriteMetrics, also returning how many of the leading metrics of m were consumed:
+// written, or dropped as rejected or conflicting with their schema. Retrying the write can resume after them.
+func (c *Client) writeMetricsConsumed(ctx context.Context, bucket, org string, m []Metric, opts []WriteOption) (n, consumed int, err error) {
+	o, err := c.writeOptions(opts)
+	if err != nil {
+		return 0, 0, err
+	}
 
-	e.SetFieldTypeSupport(lp.UintSupport)
-	e.FailOnFieldErr(c.errOnFieldErr)
+	c.log(LevelDebug, "writing metrics", "op", "Write", "bucket", bucket, "org", org, "count", len(m))
 
 	select {
 	case <-ctx.Done():
-		return 0, ctx.Err()
+		return 0, 0, ctx.Err()
 	default:
 	}
 
+	if c.schema == nil {
+		return c.writeMetrics(ctx, bucket, org, m, o)
+	}
+	checked, indexes, serr := c.checkSchema(m, o)
+	if serr != nil && len(checked) == 0 {
+		if isDropped(serr) {
+			return 0, len(m), serr
+		}
+		return 0, 0, serr
+	}
+	n, consumed, err = c.writeMetrics(ctx, bucket, org, checked, o)
+	if indexes != nil {
+		// back to the indexes of m
+		if werr, ok := err.(*WriteError); ok {
+			for i := range werr.Rejected {
+				werr.Rejected[i].Index = indexes[werr.Rejected[i].Index]
+			}
+		}
+		if consumed < len(indexes) {
+			consumed = indexes[consumed]
+		} else {
+			consumed = len(m)
+		}
+	}
+	return n, consumed, joinWriteErrors(serr, err)
+}
+
+// writeMetrics writes metrics with the options of a write, in as many requests as they need.
+// consumed is the number of leading metrics written or dropped.
+func (c *Client) writeMetrics(ctx context.Context, bucket, org string, m []Metric, o writeOptions) (n, consumed int, err error) {
+	if c.knownUnsupported(FeatureUintFields) && hasUintField(m) {
+		return 0, 0, c.unsupportedError("Write", FeatureUintFields)
+	}
+
+	em, err := c.encodeMetrics(m, o.precision, overlayTags(c.defaultTags, o.defaultTags))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(m) == 0 {
+		return c.writeBatch(ctx, bucket, org, o, em, nil)
+	}
+	// split the body greedily at metric boundaries, so each request fits in maxBodyBytes
+	var werr error
+	for i := 0; i < len(m); {
+		j := len(m)
+		if c.maxBodyBytes > 0 {
+			for j = i + 1; j < len(m) && em.ends[j]-em.start(i) <= c.maxBodyBytes; j++ {
+			}
+		}
+		idx := make([]int, j-i)
+		for k := range idx {
+			idx[k] = i + k
+		}
+		written, done, err := c.writeBatch(ctx, bucket, org, o, em, idx)
+		n += written
+		if werr = joinWriteErrors(werr, err); werr != nil && !isDropped(werr) {
+			return n, i + done, werr
+		}
+		i = j
+	}
+	return n, len(m), werr
+}
+
+// encodedMetrics is line protocol for metrics, with the offset each metric ends at.
+type encodedMetrics struct {
+	data []byte
+	ends []int
+}
+
+// start returns the offset metric i starts at.
+func (em *encodedMetrics) start(i int) int {
+	if i == 0 {
+		return 0
+	}
+	return em.ends[i-1]
+}
+
+// metric returns the line protocol for metric i.
+func (em *encodedMetrics) metric(i int) []byte {
+	return em.data[em.start(i):em.ends[i]]
+}
+
+// body returns the line protocol for the metrics at indexes idx, in order.
+func (em *encodedMetrics) body(idx []int) []byte {
+	if len(idx) == 0 {
+		return nil
+	}
+	first, last := idx[0], idx[len(idx)-1]
+	if last-first == len(idx)-1 {
+		// contiguous, as all are but resent ones
+		return em.data[em.start(first):em.ends[last]]
+	}
+	var body []byte
+	for _, i := range idx {
+		body = append(body, em.metric(i)...)
+	}
+	return body
+}
+
+// rejected returns the metrics at indexes idx that the server rejected the body of idx for,
+// found by the line number in err, or by the lines quoted in its message, like "unable to parse 'cpu value=': missing field value".
+func (em *encodedMetrics) rejected(idx []int, err *Error) []RejectedMetric {
+	const quote = "unable to parse '"
+	var (
+		rejected []RejectedMetric
+		line     int
+	)
+	for _, i := range idx {
+		found := false
+		for _, text := range strings.SplitAfter(string(em.metric(i)), "\n") {
+			if text == "" {
+				continue
+			}
+			line++
+			if found {
+				continue
+			}
+			text = strings.TrimSuffix(text, "\n")
+			if k := strings.Index(err.Message, quote+text+"': "); k >= 0 {
+				reason := err.Message[k+len(quote)+len(text)+3:]
+				if end := strings.IndexByte(reason, '\n'); end >= 0 {
+					reason = reason[:end]
+				}
+				rejected = append(rejected, RejectedMetric{Index: i, Line: line, Message: reason})
+				found = true
+			} else if err.Line != nil && int(*err.Line) == line {
+				rejected = append(rejected, RejectedMetric{Index: i, Line: line, Message: err.Message})
+				found = true
+			}
+		}
+	}
+	return rejected
+}
+
+// encodeMetrics encodes m, checking each line against maxLineBytes, and each metric against maxBodyBytes.
+// Metrics are appended straight to the body, unless they need splitting into lines of at most maxLineBytes.
+// The defaults are merged into the tags of each metric, without changing it.
+func (c *Client) encodeMetrics(m []Metric, precision time.Duration, defaults []lp.Tag) (*encodedMetrics, error) {
+	var (
+		buf = &bytes.Buffer{}
+		e   *encoder
+		em  = &encodedMetrics{ends: make([]int, len(m))}
+		err error
+	)
+	if c.maxLineBytes > 0 {
+		e = newEncoder(buf, precision, c.errOnFieldErr)
+		e.maxLineBytes = c.maxLineBytes
+	}
 	for i := range m {
-		if _, err := e.Encode(m[i]); err != nil {
-			return 0, err
+		if e != nil && len(defaults) > 0 {
+			_, err = e.Encode(&defaultTagged{Metric: m[i], tags: mergeTags(m[i].TagList(), defaults)})
+			em.data = buf.Bytes()
+		} else if e != nil {
+			_, err = e.Encode(m[i])
+			em.data = buf.Bytes()
+		} else {
+			em.data, err = appendMetric(em.data, m[i], precision, c.errOnFieldErr, defaults)
+		}
+		if err != nil {
+			if err == lp.ErrNeedMoreSpace {
+				max := int32(c.maxLineBytes)
+				return nil, &Error{Code: EInvalid, Op: "Write", Message: fmt.Sprintf("metric %d has a field which doesn't fit in a line", i), MaxLength: &max}
+			}
+			return nil, err
+		}
+		em.ends[i] = len(em.data)
+		if size := em.ends[i] - em.start(i); c.maxBodyBytes > 0 && size > c.maxBodyBytes {
+			max := int32(c.maxBodyBytes)
+			return nil, &Error{Code: EInvalid, Op: "Write", Message: fmt.Sprintf("metric %d is %d bytes, larger than a request body may be", i, size), MaxLength: &max}
 		}
 	}
+	return em, nil
+}
 
-	var req *http.Request
+// writeBatch writes the metrics at indexes idx in one request,
+// or in halves if the body is larger than maxCompressedBodyBytes once compressed.
+// If the server rejects some of them, it returns a *WriteError, after writing the rest if o.dropRejected is set.
+// consumed is the number of leading indexes in idx written or dropped.
+func (c *Client) writeBatch(ctx context.Context, bucket, org string, o writeOptions, em *encodedMetrics, idx []int) (n, consumed int, err error) {
+	body := em.body(idx)
 
-	if c.contentEncoding == "gzip" {
-		req, err = NewWriteGzipRequest(c.url, c.userAgent, c.authorization, bucket, org, c.compressionLevel, buf)
-	} else {
-		req, err = NewWriteRequest(c.url, c.userAgent, c.authorization, bucket, org, buf)
+	// the body is in memory already, so compress it there rather than through a pipe
+	compressed := &compressionStats{}
+	sent, err := c.compressBody(body, compressed)
+	if err != nil {
+		return 0, 0, err
+	}
+	// an empty write has nothing to split, however large its compressed body
+	if c.maxCompressedBodyBytes > 0 && len(idx) > 0 && len(sent) > c.maxCompressedBodyBytes {
+		if len(idx) == 1 {
+			max := int32(c.maxCompressedBodyBytes)
+			return 0, 0, &Error{Code: EInvalid, Op: "Write", Message: fmt.Sprintf("metric %d is %d bytes compressed, larger than a request body may be", idx[0], len(sent)), MaxLength: &max}
+		}
+		mid := len(idx) / 2
+		n, consumed, err := c.writeBatch(ctx, bucket, org, o, em, idx[:mid])
+		if err != nil && !isDropped(err) {
+			return n, consumed, err
+		}
+		n1, consumed1, err1 := c.writeBatch(ctx, bucket, org, o, em, idx[mid:])
+		return n + n1, mid + consumed1, joinWriteErrors(err, err1)
 	}
+	req, err := c.newWriteRequest(ctx, bucket, org, o.precision, bytes.NewReader(sent), compressed)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	resp, err := c.do("Write", req)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	defer func() {
@@ -64,14 +270,184 @@ func (c *Client) Write(ctx context.Context, bucket, org string, m ...Metric) (n
 
 	eerr, err := parseWriteError(resp)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	if eerr != nil {
-		return 0, eerr
+		rejected := em.rejected(idx, eerr)
+		if len(rejected) == 0 {
+			return 0, 0, eerr
+		}
+		werr := &WriteError{Err: eerr, Rejected: rejected}
+		if !o.dropRejected {
+			return 0, 0, werr
+		}
+
+		werr.Dropped = true
+		rest := make([]int, 0, len(idx)-len(rejected))
+		for _, i := range idx {
+			if !werr.rejects(i) {
+				rest = append(rest, i)
+			}
+		}
+		if len(rest) == 0 {
+			return 0, len(idx), werr
+		}
+		c.log(LevelWarn, "dropping rejected metrics", "op", "Write", "bucket", bucket, "org", org, "count", len(rejected), "error", eerr)
+		n, consumed, err := c.writeBatch(ctx, bucket, org, o, em, rest)
+		if consumed < len(rest) {
+			// up to the first of the rest not written, past the dropped metrics before it
+			next := rest[consumed]
+			for consumed = 0; idx[consumed] != next; consumed++ {
+			}
+		} else {
+			consumed = len(idx)
+		}
+		return n, consumed, joinWriteErrors(werr, err)
+	}
+
+	return len(idx), len(idx), nil
+}
+
+// isDropped reports whether err is a *WriteError for rejected metrics which were dropped to write the rest.
+func isDropped(err error) bool {
+	werr, ok := err.(*WriteError)
+	return ok && werr.Dropped
+}
+
+// joinWriteErrors returns the error of a write made of two parts, given the error of each.
+// The second part is only written when the first succeeded, or dropped rejected metrics.
+func joinWriteErrors(first, second error) error {
+	switch {
+	case first == nil:
+		return second
+	case second == nil:
+		return first
+	case !isDropped(second):
+		return second
+	}
+	a, b := first.(*WriteError), second.(*WriteError)
+	rejected := append(append([]RejectedMetric{}, a.Rejected...), b.Rejected...)
+	sort.Slice(rejected, func(i, j int) bool { return rejected[i].Index < rejected[j].Index })
+	return &WriteError{Err: a.Err, Rejected: rejected, Dropped: true}
+}
+
+// compressBody returns body as it is sent, compressed if the client compresses writes, recording how in stats.
+func (c *Client) compressBody(body []byte, stats *compressionStats) ([]byte, error) {
+	compressor := c.compressor()
+	if compressor == nil {
+		return body, nil
+	}
+	stats.encoding = compressor.Encoding()
+	return compress(compressor, body, stats)
+}
+
+// WriteOption is an option for a single write.
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	precision    time.Duration
+	validate     bool
+	dropRejected bool
+	defaultTags  []lp.Tag // sorted by key
+}
+
+// WithWritePrecision returns an option for writing timestamps in units of precision, overriding the client's WithPrecision.
+// It must be time.Nanosecond, time.Microsecond, time.Millisecond or time.Second.
+func WithWritePrecision(precision time.Duration) WriteOption {
+	return func(o *writeOptions) {
+		o.precision = precision
+	}
+}
+
+// WithValidation makes WriteLineProtocol check each line as it is sent.
+// The first invalid line aborts the request, so the server does not store any of the points,
+// and is reported as an *Error matching ErrInvalid with its line number.
+func WithValidation() WriteOption {
+	return func(o *writeOptions) {
+		o.validate = true
+	}
+}
+
+// WithDropRejected makes WriteMetrics drop the metrics the server rejects, and resend the rest.
+// It still returns a *WriteError listing the dropped metrics, with Dropped set, and n counts the metrics written.
+func WithDropRejected() WriteOption {
+	return func(o *writeOptions) {
+		o.dropRejected = true
+	}
+}
+
+// WithWriteDefaultTags returns an option adding tags to each metric which doesn't have them, like WithDefaultTags.
+// They take precedence over the client's default tags. Make the option once, and reuse it.
+func WithWriteDefaultTags(tags map[string]string) WriteOption {
+	sorted := sortedTags(tags)
+	return func(o *writeOptions) {
+		o.defaultTags = sorted
+	}
+}
+
+// writeOptions returns the options for a write, starting from the client's.
+func (c *Client) writeOptions(opts []WriteOption) (writeOptions, error) {
+	o := writeOptions{precision: c.precision}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.precision == 0 {
+		o.precision = time.Nanosecond
+	}
+	if _, ok := precisions[o.precision]; !ok {
+		return o, fmt.Errorf("unsupported write precision %s", o.precision)
+	}
+	return o, nil
+}
+
+// newWriteRequest returns a request writing body to a bucket, and org.
+// If compressed is nil, body is compressed as it is sent when the client compresses writes,
+// otherwise it is in the encoding of compressed already, if it has one.
+// The Authorization header is set by c.do.
+func (c *Client) newWriteRequest(ctx context.Context, bucket, org string, precision time.Duration, body io.Reader, compressed *compressionStats) (*http.Request, error) {
+	if compressor := c.compressor(); compressed == nil && compressor != nil {
+		compressed = &compressionStats{encoding: compressor.Encoding()}
+		cr, err := newCompressingReader(compressor, body, compressed)
+		if err != nil {
+			return nil, err
+		}
+		body = cr
+	}
+	req, err := NewWriteRequest(c.url, c.userAgent, "", bucket, org, body)
+	if err != nil {
+		if cr, ok := body.(*compressingReader); ok {
+			_ = cr.Close()
+		}
+		return nil, err
+	}
+	if compressed != nil && compressed.encoding != "" {
+		req.Header.Set("Content-Encoding", compressed.encoding)
+		ctx = withCompressionStats(ctx, compressed)
 	}
+	q := req.URL.Query()
+	q.Set("precision", precisions[precision])
+	req.URL.RawQuery = q.Encode()
+	return req.WithContext(ctx), nil
+}
 
-	return len(m), nil
+// hasUintField reports whether any of the metrics has an unsigned integer field.
+func hasUintField(m []Metric) bool {
+	for i := range m {
+		if p, ok := m[i].(*Point); ok {
+			if p.hasUint() {
+				return true
+			}
+			continue
+		}
+		for _, f := range m[i].FieldList() {
+			switch f.Value.(type) {
+			case uint, uint8, uint16, uint32, uint64:
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func parseInt32(v string) (int32, error) {
@@ -84,7 +460,7 @@ func parseInt32(v string) (int32, error) {
 }
 
 func NewWriteGzipRequest(url *url.URL, userAgent, token, bucket, org string, compressionLevel int, body io.Reader) (*http.Request, error) {
-	body, err := gzip.CompressWithGzip(body, compressionLevel)
+	body, err := newCompressingReader(GzipCompressor(compressionLevel), body, &compressionStats{})
 	if err != nil {
 		return nil, err
 	}
@@ -155,13 +531,7 @@ func parseWriteError(r *http.Response) (err *Error, perr error) {
 		return nil, nil
 	}
 
-	err = &Error{}
-	if v := r.Header.Get("Retry-After"); v != "" {
-		if retry, perr := parseInt32(v); perr == nil {
-			err.RetryAfter = &retry
-		}
-	}
-	err.StatusCode = r.StatusCode
+	err = newErrorFromResponse(r)
 	switch r.StatusCode {
 	case http.StatusRequestEntityTooLarge:
 		err.Code = ETooLarge
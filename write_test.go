@@ -1,11 +1,18 @@
 package influxdb
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -118,6 +125,22 @@ some_measurement,some_tag=some_value some_int=2i,some_uint=2u 154630080000000000
 				Message:    "payload is bad",
 			},
 		},
+		{
+			name:       "request id",
+			metrics:    createTestRowMetrics(t, 10),
+			statusCode: http.StatusNotFound,
+			body:       []byte(`{"code": "not found", "message": "bucket not found"}`),
+			headers: http.Header{
+				"Content-Type": []string{"application/json; charset=utf-8"},
+				"Request-Id":   []string{"0a1b2c"},
+			},
+			err: &Error{
+				StatusCode: 404,
+				Code:       ENotFound,
+				Message:    "bucket not found",
+				RequestID:  "0a1b2c",
+			},
+		},
 		{
 			name:       "size limited",
 			metrics:    createTestRowMetrics(t, 10),
@@ -168,6 +191,11 @@ some_measurement,some_tag=some_value some_int=2i,some_uint=2u 154630080000000000
 			}()
 
 			count, err := client.Write(context.TODO(), "bucket", "org", test.metrics...)
+			if ierr, ok := err.(*Error); ok {
+				// the response headers are attached for support, and vary between runs
+				assert.NotNil(t, ierr.Header)
+				ierr.Header = nil
+			}
 			assert.Equal(t, test.err, err)
 			assert.Equal(t, test.count, count)
 		})
@@ -193,3 +221,284 @@ func createTestRowMetrics(t *testing.T, count int) (metrics []Metric) {
 
 	return
 }
+
+func TestClient_Write_maxBodyBytes(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []string
+		sizes  []int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		size := len(data)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gr, err := gzip.NewReader(bytes.NewReader(data))
+			require.NoError(t, err)
+			data, err = ioutil.ReadAll(gr)
+			require.NoError(t, err)
+		}
+		mu.Lock()
+		bodies = append(bodies, string(data))
+		sizes = append(sizes, size)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	metrics := make([]Metric, 10)
+	for i := range metrics {
+		metrics[i] = NewRowMetric(map[string]interface{}{"value": strings.Repeat(strconv.Itoa(i), 20)}, "m", nil, time.Unix(int64(i), 0))
+	}
+	for _, test := range []struct {
+		name     string
+		options  []Option
+		requests int // if zero, more than one
+		max      int // the largest body size
+		wantErr  bool
+	}{
+		{
+			name:     "unlimited",
+			options:  []Option{WithNoCompression()},
+			requests: 1,
+		},
+		{
+			name:     "max body bytes",
+			options:  []Option{WithNoCompression(), WithMaxBodyBytes(200)},
+			requests: 3,
+			max:      200,
+		},
+		{
+			name:    "max compressed body bytes",
+			options: []Option{WithGZIP(9), WithMaxCompressedBodyBytes(90)},
+			max:     90,
+		},
+		{
+			name:    "metric larger than body",
+			options: []Option{WithMaxBodyBytes(20)},
+			wantErr: true,
+		},
+		{
+			name:    "metric larger than compressed body",
+			options: []Option{WithMaxCompressedBodyBytes(10)},
+			wantErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			bodies, sizes = nil, nil
+			client, err := New(server.URL, "foo", append(test.options, WithHTTPClient(server.Client()))...)
+			require.NoError(t, err)
+
+			n, err := client.Write(context.Background(), "bucket", "org", metrics...)
+			if test.wantErr {
+				var ierr *Error
+				require.True(t, errors.As(err, &ierr) && errors.Is(err, ErrInvalid), "unexpected error %v", err)
+				assert.NotNil(t, ierr.MaxLength)
+				assert.Equal(t, 0, n)
+				assert.Empty(t, bodies)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, len(metrics), n)
+			if test.requests != 0 {
+				assert.Len(t, bodies, test.requests)
+			} else {
+				assert.True(t, len(bodies) > 1, "expected several requests, got %d", len(bodies))
+			}
+
+			// the requests hold all the metrics, in order
+			var want strings.Builder
+			e := newEncoder(&want, time.Nanosecond, true)
+			for _, m := range metrics {
+				_, err := e.Encode(m)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, want.String(), strings.Join(bodies, ""))
+			for _, size := range sizes {
+				if test.max != 0 {
+					assert.True(t, size <= test.max, "body of %d bytes", size)
+				}
+			}
+		})
+	}
+
+	t.Run("empty write", func(t *testing.T) {
+		bodies, sizes = nil, nil
+		// the compressed empty body is larger than the limit
+		client, err := New(server.URL, "foo", WithMaxCompressedBodyBytes(10), WithHTTPClient(server.Client()))
+		require.NoError(t, err)
+
+		n, err := client.Write(context.Background(), "bucket", "org")
+		require.NoError(t, err)
+		assert.Equal(t, 0, n)
+		assert.Equal(t, []string{""}, bodies)
+	})
+}
+
+func TestClient_Write_maxLineBytes(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		body = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	m := NewRowMetric(map[string]interface{}{"a": 1.5, "b": 2.5, "c": 3.5}, "cpu", nil, time.Unix(1, 0))
+	for _, test := range []struct {
+		name      string
+		precision time.Duration
+		max       int
+		want      string
+		err       string
+	}{
+		{
+			name:      "nanoseconds",
+			precision: time.Nanosecond,
+			max:       30,
+			want:      "cpu a=1.5,b=2.5 1000000000\ncpu c=3.5 1000000000\n",
+		},
+		{
+			name:      "seconds",
+			precision: time.Second,
+			max:       20,
+			want:      "cpu a=1.5,b=2.5 1\ncpu c=3.5 1\n",
+		},
+		{
+			name:      "field too long",
+			precision: time.Nanosecond,
+			max:       20,
+			err:       "invalid (Write): metric 0 has a field which doesn't fit in a line - maxlen[20]",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			body = ""
+			client, err := New(server.URL, "foo", WithNoCompression(), WithPrecision(test.precision), WithMaxLineBytes(test.max), WithHTTPClient(server.Client()))
+			require.NoError(t, err)
+
+			_, err = client.Write(context.Background(), "bucket", "org", m)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, body)
+			for _, line := range strings.SplitAfter(body, "\n") {
+				assert.True(t, len(line) <= test.max, "line %q is longer than %d bytes", line, test.max)
+			}
+		})
+	}
+}
+
+func TestClient_Write_rejected(t *testing.T) {
+	var (
+		written []string
+		quote   bool // quote the rejected lines in the message, rather than sending the line number of the first
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		var (
+			messages []string
+			first    int
+		)
+		for i, line := range lines {
+			if strings.Contains(line, "bad") {
+				messages = append(messages, fmt.Sprintf("unable to parse '%s': bad value %d", line, i+1))
+				if first == 0 {
+					first = i + 1
+				}
+			}
+		}
+		if len(messages) == 0 {
+			written = append(written, lines...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		if quote {
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": "invalid", "message": strings.Join(messages, "\n")})
+		} else {
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": "invalid", "message": "bad value", "line": first})
+		}
+	}))
+	defer server.Close()
+
+	metrics := make([]Metric, 6)
+	for i := range metrics {
+		value := "good"
+		if i == 1 || i == 4 {
+			value = "bad"
+		}
+		metrics[i] = NewRowMetric(map[string]interface{}{"value": value}, "m", nil, time.Unix(int64(i), 0))
+	}
+
+	for _, test := range []struct {
+		name     string
+		quote    bool
+		opts     []WriteOption
+		n        int
+		rejected []RejectedMetric
+		written  int
+	}{
+		{
+			name:     "line number",
+			rejected: []RejectedMetric{{Index: 1, Line: 2, Message: "bad value"}},
+		},
+		{
+			name:  "quoted lines",
+			quote: true,
+			rejected: []RejectedMetric{
+				{Index: 1, Line: 2, Message: "bad value 2"},
+				{Index: 4, Line: 5, Message: "bad value 5"},
+			},
+		},
+		{
+			name: "drop by line number",
+			opts: []WriteOption{WithDropRejected()},
+			n:    4,
+			rejected: []RejectedMetric{
+				{Index: 1, Line: 2, Message: "bad value"},
+				{Index: 4, Line: 4, Message: "bad value"},
+			},
+			written: 4,
+		},
+		{
+			name:  "drop quoted lines",
+			quote: true,
+			opts:  []WriteOption{WithDropRejected()},
+			n:     4,
+			rejected: []RejectedMetric{
+				{Index: 1, Line: 2, Message: "bad value 2"},
+				{Index: 4, Line: 5, Message: "bad value 5"},
+			},
+			written: 4,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			written, quote = nil, test.quote
+			client, err := New(server.URL, "foo", WithNoCompression(), WithHTTPClient(server.Client()))
+			require.NoError(t, err)
+
+			n, err := client.WriteMetrics(context.Background(), "bucket", "org", metrics, test.opts...)
+			var werr *WriteError
+			require.True(t, errors.As(err, &werr), "unexpected error %v", err)
+			assert.True(t, errors.Is(err, ErrInvalid))
+			assert.Equal(t, test.n, n)
+			assert.Equal(t, test.rejected, werr.Rejected)
+			assert.Equal(t, len(test.opts) > 0, werr.Dropped)
+			if werr.Dropped {
+				assert.Contains(t, err.Error(), " - dropped metrics[1,4]")
+			} else {
+				assert.Contains(t, err.Error(), " - rejected metrics[1")
+			}
+			assert.Len(t, written, test.written)
+			for _, line := range written {
+				assert.NotContains(t, line, "bad")
+			}
+		})
+	}
+}
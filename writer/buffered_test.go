@@ -3,7 +3,9 @@ package writer
 import (
 	"io"
 	"testing"
+	"time"
 
+	"github.com/lancey-energy-storage/influxdb-client-go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -70,3 +72,38 @@ func TestBufferedWriter_ShortWrite(t *testing.T) {
 	require.Equal(t, io.ErrShortWrite, err)
 	require.Equal(t, 9, n)
 }
+
+// encodingWriter encodes the metrics it is given, as the client does.
+type encodingWriter struct {
+	buf []byte
+}
+
+func (e *encodingWriter) Write(m ...influxdb.Metric) (int, error) {
+	e.buf = e.buf[:0]
+	for i := range m {
+		var err error
+		if e.buf, err = influxdb.AppendMetric(e.buf, m[i], time.Nanosecond); err != nil {
+			return i, err
+		}
+	}
+	return len(m), nil
+}
+
+func BenchmarkBufferedWriter_points(b *testing.B) {
+	var (
+		w  = NewBufferedWriterSize(&encodingWriter{}, 1000)
+		pb = influxdb.NewPointBuilder()
+		ts = time.Now()
+	)
+	defer pb.Release()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p, err := pb.Measurement("cells").Tag("site", "a").Float("voltage", 3.3).Int("soc", 87).Time(ts).Point()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := w.Write(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -62,6 +62,22 @@
 // Finally, it wraps the buffered writer in a *PointsWriter which takes care of ensuring Flush is called
 // automatically when it hasn't been called for a configured duration. This final type is safe for concurrent use.
 //
+// Points built with an influxdb.PointBuilder are metrics too, and can be written in place of NewRowMetric's.
+// They are cheaper to build, and encoding them when the buffer is flushed doesn't allocate.
+//
+// With WithSchema, metrics are checked against the schemas of an influxdb.SchemaRegistry as they are written,
+// before they are buffered. A metric conflicting with its schema, like a field written as an integer after a float,
+// would fail the flush of the whole buffer, so it is dropped instead, and reported by the Write which wrote it.
+//
+// NewRoutingWriter returns a *RoutingWriter for writing metrics to several buckets, picking the bucket and org
+// of each metric with a RouteFunc, like RouteByMeasurement or RouteByTag. Each destination gets its own writer,
+// as returned by New, so metrics are batched per destination, and each is flushed on its own:
+//
+// 	wr := writer.NewRoutingWriter(cli, "default", "influx", writer.Routes(
+// 		writer.RouteByTag("severity", map[string]writer.Destination{"critical": {Bucket: "alarms", Org: "influx"}}),
+// 		writer.RouteByMeasurement(map[string]writer.Destination{"summary": {Bucket: "hourly", Org: "influx"}}),
+// 	))
+//
 // Automatic Retries
 //
 // The writer package offers automatic retry capabilities during known transient failures
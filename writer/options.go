@@ -3,6 +3,8 @@ package writer
 import (
 	"context"
 	"time"
+
+	"github.com/lancey-energy-storage/influxdb-client-go"
 )
 
 // Config is a structure used to configure a point writer
@@ -12,6 +14,9 @@ type Config struct {
 	flushInterval time.Duration
 	retry         bool
 	retryOptions  []RetryOption
+	defaultTags   map[string]string
+	schema        *influxdb.SchemaRegistry
+	schemaPolicy  influxdb.SchemaPolicy
 }
 
 // Option is a functional option for Configuring point writers
@@ -75,3 +80,26 @@ func WithRetries(options ...RetryOption) Option {
 		c.retryOptions = options
 	}
 }
+
+// WithDefaultTags sets tags added to each metric written which doesn't have them
+// The metrics themselves are not changed, and tags is copied, so changing it afterwards doesn't change the writer
+func WithDefaultTags(tags map[string]string) Option {
+	copied := make(map[string]string, len(tags))
+	for k, v := range tags {
+		copied[k] = v
+	}
+	return func(c *Config) {
+		c.defaultTags = copied
+	}
+}
+
+// WithSchema checks metrics against the schemas of a registry as they are written to the writer,
+// before they are buffered, so a conflicting metric doesn't fail the flush of the rest
+// Conflicting metrics are dropped, unless the policy is influxdb.SchemaReport, and Write
+// returns an *influxdb.WriteError listing them, while the writer goes on working
+func WithSchema(registry *influxdb.SchemaRegistry, policy influxdb.SchemaPolicy) Option {
+	return func(c *Config) {
+		c.schema = registry
+		c.schemaPolicy = policy
+	}
+}
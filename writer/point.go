@@ -30,6 +30,10 @@ type PointWriter struct {
 	stopped       chan struct{}
 	err           error
 	mu            sync.Mutex
+
+	// schema checks metrics before they are buffered, if set
+	schema       *influxdb.SchemaRegistry
+	schemaPolicy influxdb.SchemaPolicy
 }
 
 // NewPointWriter configures and returns a *PointWriter writer type
@@ -104,6 +108,14 @@ func (p *PointWriter) Write(m ...influxdb.Metric) (int, error) {
 		return 0, p.err
 	}
 
+	// conflicting metrics are dropped without setting p.err, so the writer goes on working
+	var serr error
+	if p.schema != nil {
+		if m, serr = p.schema.Check(m, p.schemaPolicy); serr != nil {
+			serr.(*influxdb.WriteError).Dropped = true
+		}
+	}
+
 	// check if the underlying flush will flush
 	if len(m) > p.w.Available() {
 		// tell the ticker to reset flush interval
@@ -114,8 +126,10 @@ func (p *PointWriter) Write(m ...influxdb.Metric) (int, error) {
 	}
 
 	var n int
-	n, p.err = p.w.Write(m...)
-	return n, p.err
+	if n, p.err = p.w.Write(m...); p.err != nil {
+		return n, p.err
+	}
+	return n, serr
 }
 
 // Close signals to stop flushing metrics and causes subsequent
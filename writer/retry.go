@@ -1,6 +1,7 @@
 package writer
 
 import (
+	"errors"
 	"time"
 
 	"github.com/lancey-energy-storage/influxdb-client-go"
@@ -52,31 +53,12 @@ func (r *RetryWriter) Write(m ...influxdb.Metric) (n int, err error) {
 			return
 		}
 
-		ierr, ok := err.(*influxdb.Error)
-		if !ok {
-			return
-		}
-
-		switch ierr.Code {
-		// retriable errors
-		case influxdb.EUnavailable, influxdb.ETooManyRequests:
-			if ierr.RetryAfter != nil {
-				// given retry-after is configured attempt to sleep
-				// for retry-after seconds
-				r.sleep(time.Duration(*ierr.RetryAfter) * time.Second)
-				continue
-			}
-
-			// given a backoff duration > 0
-			if duration := r.backoff(i + 1); duration > 0 {
-				// call sleep with backoff duration
-				r.sleep(duration)
-			}
-		case influxdb.ETooLarge:
+		switch {
+		case errors.Is(err, influxdb.ErrTooLarge):
 			// given retry-after is configured attempt to sleep
 			// for retry-after seconds
-			if ierr.RetryAfter != nil {
-				r.sleep(time.Duration(*ierr.RetryAfter) * time.Second)
+			if retryAfter, ok := influxdb.RetryAfter(err); ok {
+				r.sleep(retryAfter)
 			}
 			n0, err := r.Write(m[:(len(m) / 2)]...)
 			if err != nil {
@@ -84,7 +66,19 @@ func (r *RetryWriter) Write(m ...influxdb.Metric) (n int, err error) {
 			}
 			n1, err := r.Write(m[(len(m) / 2):]...)
 			return n0 + n1, err
+		case influxdb.IsRetryable(err):
+			if retryAfter, ok := influxdb.RetryAfter(err); ok {
+				// given retry-after is configured attempt to sleep
+				// for retry-after seconds
+				r.sleep(retryAfter)
+				continue
+			}
 
+			// given a backoff duration > 0
+			if duration := r.backoff(i + 1); duration > 0 {
+				// call sleep with backoff duration
+				r.sleep(duration)
+			}
 		default:
 			return
 		}
@@ -0,0 +1,212 @@
+package writer
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/lancey-energy-storage/influxdb-client-go"
+)
+
+// Destination is a bucket within an organisation which metrics are written to
+type Destination struct {
+	Bucket string
+	Org    string
+}
+
+// RouteFunc picks the destination of a metric
+// It returns false to leave the metric to the default destination of the RoutingWriter
+type RouteFunc func(m influxdb.Metric) (Destination, bool)
+
+// RouteByMeasurement returns a RouteFunc routing metrics by their measurement name
+func RouteByMeasurement(routes map[string]Destination) RouteFunc {
+	routes = copyRoutes(routes)
+	return func(m influxdb.Metric) (Destination, bool) {
+		d, ok := routes[m.Name()]
+		return d, ok
+	}
+}
+
+// RouteByTag returns a RouteFunc routing metrics by the value of their tag with the provided key
+func RouteByTag(key string, routes map[string]Destination) RouteFunc {
+	routes = copyRoutes(routes)
+	return func(m influxdb.Metric) (Destination, bool) {
+		for _, tag := range m.TagList() {
+			if tag.Key == key {
+				d, ok := routes[tag.Value]
+				return d, ok
+			}
+		}
+		return Destination{}, false
+	}
+}
+
+// Routes returns a RouteFunc routing each metric by the first of the provided routes
+// which picks a destination for it
+func Routes(routes ...RouteFunc) RouteFunc {
+	return func(m influxdb.Metric) (Destination, bool) {
+		for _, route := range routes {
+			if d, ok := route(m); ok {
+				return d, true
+			}
+		}
+		return Destination{}, false
+	}
+}
+
+func copyRoutes(routes map[string]Destination) map[string]Destination {
+	copied := make(map[string]Destination, len(routes))
+	for k, d := range routes {
+		copied[k] = d
+	}
+	return copied
+}
+
+// RoutingWriter writes metrics to different buckets and organisations, picking the destination
+// of each metric with a RouteFunc
+// Each destination is written through its own writer, constructed with New and the options
+// of the RoutingWriter the first time a metric is routed to it, so metrics are batched per destination,
+// and each destination is flushed and retried independently of the others
+// RoutingWriter is safe for concurrent use
+type RoutingWriter struct {
+	w     BucketMetricWriter
+	route RouteFunc
+	def   Destination
+	opts  []Option
+
+	// schema checks metrics before they are routed, if set with WithSchema
+	schema       *influxdb.SchemaRegistry
+	schemaPolicy influxdb.SchemaPolicy
+
+	mu      sync.Mutex
+	writers map[Destination]*PointWriter
+	closed  bool
+}
+
+// NewRoutingWriter returns a *RoutingWriter writing metrics to the underlying BucketMetricWriter,
+// in the destination route picks, or else in the provided bucket and org
+// A nil route writes every metric to the provided bucket and org
+func NewRoutingWriter(w BucketMetricWriter, bucket, org string, route RouteFunc, opts ...Option) *RoutingWriter {
+	if route == nil {
+		route = Routes()
+	}
+	config := Options(opts).Config()
+	return &RoutingWriter{
+		w:     w,
+		route: route,
+		def:   Destination{Bucket: bucket, Org: org},
+		// metrics are checked against their schema once, before they are routed,
+		// so the indexes of conflicting metrics are those passed to Write
+		opts:         append(opts[:len(opts):len(opts)], WithSchema(nil, 0)),
+		schema:       config.schema,
+		schemaPolicy: config.schemaPolicy,
+		writers:      map[Destination]*PointWriter{},
+	}
+}
+
+// DestinationError is an error writing metrics to one of the destinations of a RoutingWriter
+type DestinationError struct {
+	Destination Destination
+	Err         error
+}
+
+func (e *DestinationError) Error() string {
+	return fmt.Sprintf("writing to bucket %q of org %q: %v", e.Destination.Bucket, e.Destination.Org, e.Err)
+}
+
+// Unwrap returns the error of the destination
+func (e *DestinationError) Unwrap() error {
+	return e.Err
+}
+
+// Write routes each metric to its destination and writes them to the writer of each
+// A destination failing doesn't stop the metrics of the others from being written,
+// and the error returned is a *DestinationError for the first destination which failed
+// With WithSchema, conflicting metrics are dropped before routing, as with New
+func (r *RoutingWriter) Write(m ...influxdb.Metric) (int, error) {
+	var serr error
+	if r.schema != nil {
+		if m, serr = r.schema.Check(m, r.schemaPolicy); serr != nil {
+			serr.(*influxdb.WriteError).Dropped = true
+		}
+	}
+
+	// group the metrics by destination, in the order each destination first appears
+	var (
+		destinations []Destination
+		indexes      = map[Destination][]int{}
+	)
+	for i := range m {
+		d, ok := r.route(m[i])
+		if !ok {
+			d = r.def
+		}
+		if _, ok := indexes[d]; !ok {
+			destinations = append(destinations, d)
+		}
+		indexes[d] = append(indexes[d], i)
+	}
+
+	var (
+		n   int
+		err error
+	)
+	for _, d := range destinations {
+		wr, werr := r.writer(d)
+		if werr != nil {
+			return n, werr
+		}
+
+		idx := indexes[d]
+		group := m
+		if len(destinations) > 1 {
+			group = make([]influxdb.Metric, len(idx))
+			for i, j := range idx {
+				group[i] = m[j]
+			}
+		}
+
+		gn, gerr := wr.Write(group...)
+		n += gn
+		if gerr != nil && err == nil {
+			err = &DestinationError{Destination: d, Err: gerr}
+		}
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, serr
+}
+
+// writer returns the writer for a destination, constructing it the first time
+func (r *RoutingWriter) writer(d Destination) (*PointWriter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, io.ErrClosedPipe
+	}
+	wr, ok := r.writers[d]
+	if !ok {
+		wr = New(r.w, d.Bucket, d.Org, r.opts...)
+		r.writers[d] = wr
+	}
+	return wr, nil
+}
+
+// Close flushes and closes the writers of every destination
+// It returns the first error encountered
+func (r *RoutingWriter) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	writers := r.writers
+	r.mu.Unlock()
+
+	var err error
+	for d, wr := range writers {
+		if cerr := wr.Close(); cerr != nil && err == nil {
+			err = &DestinationError{Destination: d, Err: cerr}
+		}
+	}
+	return err
+}
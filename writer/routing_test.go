@@ -0,0 +1,143 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lancey-energy-storage/influxdb-client-go"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	rawDestination     = Destination{Bucket: "raw", Org: "influx"}
+	hourlyDestination  = Destination{Bucket: "hourly", Org: "influx"}
+	alarmsDestination  = Destination{Bucket: "alarms", Org: "ops"}
+	defaultDestination = Destination{Bucket: "default", Org: "influx"}
+
+	testRoutes = Routes(
+		RouteByTag("severity", map[string]Destination{"critical": alarmsDestination}),
+		RouteByMeasurement(map[string]Destination{"cell": rawDestination, "summary": hourlyDestination}),
+	)
+)
+
+// destinationWriter is a BucketMetricWriter recording the metrics written to each destination,
+// and failing writes to the destinations in errs
+type destinationWriter struct {
+	mu     sync.Mutex
+	writes map[Destination][][]influxdb.Metric
+	errs   map[Destination]error
+}
+
+func (w *destinationWriter) Write(_ context.Context, bucket, org string, m ...influxdb.Metric) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	d := Destination{Bucket: bucket, Org: org}
+	if err := w.errs[d]; err != nil {
+		return 0, err
+	}
+	if w.writes == nil {
+		w.writes = map[Destination][][]influxdb.Metric{}
+	}
+	w.writes[d] = append(w.writes[d], m)
+	return len(m), nil
+}
+
+func routedMetric(name string, tags map[string]string) influxdb.Metric {
+	return influxdb.NewRowMetric(map[string]interface{}{"value": 1.5}, name, tags, time.Date(2019, 5, 2, 16, 12, 41, 0, time.UTC))
+}
+
+func Test_RoutingWriter(t *testing.T) {
+	var (
+		underlying = &destinationWriter{}
+		wr         = NewRoutingWriter(underlying, "default", "influx", testRoutes, WithBufferSize(2), WithFlushInterval(time.Hour))
+		cells      = []influxdb.Metric{routedMetric("cell", nil), routedMetric("cell", nil), routedMetric("cell", nil)}
+		summary    = routedMetric("summary", nil)
+		alarm      = routedMetric("cell", map[string]string{"severity": "critical"})
+		other      = routedMetric("inverter", nil)
+	)
+
+	n, err := wr.Write(cells[0], summary, cells[1], alarm, cells[2], other)
+	require.Nil(t, err)
+	require.Equal(t, 6, n)
+
+	// only the cells overflowed their buffer, and were written straight away
+	underlying.mu.Lock()
+	require.Equal(t, map[Destination][][]influxdb.Metric{rawDestination: {cells}}, underlying.writes)
+	underlying.mu.Unlock()
+
+	require.Nil(t, wr.Close())
+	require.Equal(t, map[Destination][][]influxdb.Metric{
+		rawDestination:     {cells},
+		hourlyDestination:  {{summary}},
+		alarmsDestination:  {{alarm}},
+		defaultDestination: {{other}},
+	}, underlying.writes)
+
+	_, err = wr.Write(summary)
+	require.Equal(t, io.ErrClosedPipe, err)
+}
+
+func Test_RoutingWriter_NilRoute(t *testing.T) {
+	var (
+		underlying = &destinationWriter{}
+		wr         = NewRoutingWriter(underlying, "default", "influx", nil, WithFlushInterval(time.Hour))
+		summary    = routedMetric("summary", nil)
+	)
+
+	n, err := wr.Write(summary)
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+
+	require.Nil(t, wr.Close())
+	require.Equal(t, map[Destination][][]influxdb.Metric{defaultDestination: {{summary}}}, underlying.writes)
+}
+
+func Test_RoutingWriter_Error(t *testing.T) {
+	var (
+		failure    = errors.New("bucket not found")
+		underlying = &destinationWriter{errs: map[Destination]error{alarmsDestination: failure}}
+		wr         = NewRoutingWriter(underlying, "default", "influx", testRoutes, WithBufferSize(1), WithFlushInterval(time.Hour))
+		alarm      = routedMetric("cell", map[string]string{"severity": "critical"})
+	)
+
+	// the alarms fail to flush, while the cells are written
+	n, err := wr.Write(alarm, alarm, routedMetric("cell", nil), routedMetric("cell", nil))
+	var derr *DestinationError
+	require.True(t, errors.As(err, &derr))
+	require.Equal(t, alarmsDestination, derr.Destination)
+	require.True(t, errors.Is(err, failure))
+	require.Equal(t, 2, n)
+
+	underlying.mu.Lock()
+	require.Len(t, underlying.writes[rawDestination], 1)
+	underlying.mu.Unlock()
+
+	// the alarms writer has failed, and the error is reported on close too
+	require.True(t, errors.Is(wr.Close(), failure))
+}
+
+func Test_RoutingWriter_Schema(t *testing.T) {
+	var (
+		underlying = &destinationWriter{}
+		registry   = influxdb.NewSchemaRegistry()
+		wr         = NewRoutingWriter(underlying, "default", "influx", testRoutes, WithSchema(registry, influxdb.SchemaReject), WithFlushInterval(time.Hour))
+		conflict   = influxdb.NewRowMetric(map[string]interface{}{"value": "high"}, "summary", nil, time.Time{})
+	)
+
+	n, err := wr.Write(routedMetric("cell", nil), routedMetric("summary", nil), conflict)
+	var werr *influxdb.WriteError
+	require.True(t, errors.As(err, &werr))
+	require.True(t, werr.Dropped)
+	require.Len(t, werr.Rejected, 1)
+	// the index within the metrics written, rather than within those routed to the destination
+	require.Equal(t, 2, werr.Rejected[0].Index)
+	require.Equal(t, 2, n)
+
+	require.Nil(t, wr.Close())
+	require.Len(t, underlying.writes[hourlyDestination], 1)
+	require.Len(t, underlying.writes[hourlyDestination][0], 1)
+}
@@ -25,13 +25,19 @@ func New(writer BucketMetricWriter, bkt, org string, opts ...Option) *PointWrite
 	// set bucket write context to provided context
 	bucket.ctxt = config.ctxt
 
+	if len(config.defaultTags) > 0 {
+		bucket.setDefaultTags(config.defaultTags)
+	}
+
 	if config.retry {
 		// configure automatic retries for transient errors
 		retry := NewRetryWriter(bucket, config.retryOptions...)
 		buffered = NewBufferedWriterSize(retry, config.size)
 	}
 
-	return NewPointWriter(buffered, config.flushInterval)
+	point := NewPointWriter(buffered, config.flushInterval)
+	point.schema, point.schemaPolicy = config.schema, config.schemaPolicy
+	return point
 }
 
 // BucketWriter writes metrics to a particular bucket
@@ -43,16 +49,45 @@ type BucketWriter struct {
 
 	bucket string
 	org    string
+
+	// defaultTags are merged into each metric, through writeOptions if w is a client, or else by tag
+	defaultTags  map[string]string
+	writeOptions []influxdb.WriteOption
+	tag          func(influxdb.Metric) influxdb.Metric
+}
+
+// bucketMetricOptionsWriter is a BucketMetricWriter taking options for each write, like an *influxdb.Client
+type bucketMetricOptionsWriter interface {
+	WriteMetrics(ctx context.Context, bucket, org string, m []influxdb.Metric, opts ...influxdb.WriteOption) (int, error)
 }
 
 // NewBucketWriter allocates, configures and returned a new BucketWriter for writing
 // metrics to a specific organisations bucket
 func NewBucketWriter(w BucketMetricWriter, bucket, org string) *BucketWriter {
-	return &BucketWriter{w, context.Background(), bucket, org}
+	return &BucketWriter{w: w, ctxt: context.Background(), bucket: bucket, org: org}
+}
+
+// setDefaultTags sets the tags merged into each metric, sorting them once
+func (b *BucketWriter) setDefaultTags(tags map[string]string) {
+	b.defaultTags = tags
+	b.writeOptions = []influxdb.WriteOption{influxdb.WithWriteDefaultTags(tags)}
+	b.tag = influxdb.DefaultTagger(tags)
 }
 
 // Write writes the provided metrics to the underlying metrics writer
 // using the org and bucket configured on the bucket writer
 func (b *BucketWriter) Write(m ...influxdb.Metric) (int, error) {
-	return b.w.Write(b.ctxt, b.bucket, b.org, m...)
+	if b.defaultTags == nil {
+		return b.w.Write(b.ctxt, b.bucket, b.org, m...)
+	}
+
+	if w, ok := b.w.(bucketMetricOptionsWriter); ok {
+		return w.WriteMetrics(b.ctxt, b.bucket, b.org, m, b.writeOptions...)
+	}
+
+	tagged := make([]influxdb.Metric, len(m))
+	for i := range m {
+		tagged[i] = b.tag(m[i])
+	}
+	return b.w.Write(b.ctxt, b.bucket, b.org, tagged...)
 }
@@ -1,6 +1,8 @@
 package writer
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -47,3 +49,85 @@ func Test_BucketWriter(t *testing.T) {
 	// ensure underlying "client" is called as expected
 	require.Equal(t, expected, spy.calls)
 }
+
+type bucketOptionsWriter struct {
+	bucketWriter
+	opts []influxdb.WriteOption
+}
+
+func (b *bucketOptionsWriter) WriteMetrics(ctx context.Context, bucket, org string, m []influxdb.Metric, opts ...influxdb.WriteOption) (int, error) {
+	b.opts = opts
+	return b.Write(ctx, bucket, org, m...)
+}
+
+func Test_BucketWriter_DefaultTags(t *testing.T) {
+	var (
+		tags    = map[string]string{"site": "a", "rack": "1"}
+		metric  = influxdb.NewRowMetric(map[string]interface{}{"v": 1}, "m", map[string]string{"rack": "2"}, time.Time{})
+		spy     = &bucketWriter{}
+		wr      = NewBucketWriter(spy, "default", "influx")
+		options = &bucketOptionsWriter{}
+		owr     = NewBucketWriter(options, "default", "influx")
+	)
+
+	// metrics are tagged for writers which don't take options
+	wr.setDefaultTags(tags)
+	_, err := wr.Write(metric)
+	require.Nil(t, err)
+	require.Len(t, spy.calls, 1)
+	require.Equal(t, []*influxdb.Tag{{Key: "rack", Value: "2"}, {Key: "site", Value: "a"}}, spy.calls[0].data[0].TagList())
+	require.Len(t, metric.TagList(), 1)
+
+	// a tag set to an empty value keeps the default out
+	untagged := influxdb.NewRowMetric(map[string]interface{}{"v": 1}, "m", map[string]string{"site": ""}, time.Time{})
+	_, err = wr.Write(untagged)
+	require.Nil(t, err)
+	require.Equal(t, []*influxdb.Tag{{Key: "rack", Value: "1"}}, spy.calls[1].data[0].TagList())
+
+	// clients are left to merge the tags as they encode the metrics
+	owr.setDefaultTags(tags)
+	_, err = owr.Write(metric)
+	require.Nil(t, err)
+	require.Len(t, options.opts, 1)
+	require.Equal(t, []influxdb.Metric{metric}, options.calls[0].data)
+}
+
+func Test_New_DefaultTags(t *testing.T) {
+	tags := map[string]string{"site": "a"}
+	wr := New(&influxdb.Client{}, "default", "influx", WithDefaultTags(tags))
+	// the writer keeps its own copy
+	tags["site"] = "b"
+	require.Equal(t, map[string]string{"site": "a"}, wr.w.(*BufferedWriter).wr.(*RetryWriter).MetricsWriter.(*BucketWriter).defaultTags)
+	require.Nil(t, wr.Close())
+}
+
+func Test_New_Schema(t *testing.T) {
+	var (
+		bucket   = &bucketWriter{}
+		registry = influxdb.NewSchemaRegistry()
+		wr       = New(bucket, "default", "influx", WithSchema(registry, influxdb.SchemaReject), WithFlushInterval(time.Hour))
+		ts       = time.Date(2019, 5, 2, 16, 12, 41, 0, time.UTC)
+		soc      = func(v interface{}) influxdb.Metric {
+			return influxdb.NewRowMetric(map[string]interface{}{"soc": v}, "battery", nil, ts)
+		}
+	)
+
+	// the conflicting metric is dropped, and the rest buffered
+	n, err := wr.Write(soc(0.5), soc(1), soc(0.7))
+	var werr *influxdb.WriteError
+	require.True(t, errors.As(err, &werr))
+	require.True(t, errors.Is(err, influxdb.ErrConflict))
+	require.True(t, werr.Dropped)
+	require.Len(t, werr.Rejected, 1)
+	require.Equal(t, 1, werr.Rejected[0].Index)
+	require.Equal(t, 2, n)
+
+	// and the writer goes on working
+	n, err = wr.Write(soc(0.9))
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+
+	require.Nil(t, wr.Close())
+	require.Len(t, bucket.calls, 1)
+	require.Len(t, bucket.calls[0].data, 3)
+}